@@ -0,0 +1,244 @@
+package replify
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/sivaosorg/replify/pkg/strutil"
+)
+
+// WithArchiveStreaming creates a streaming wrapper whose source is an
+// archive of the given entries, built on the fly.
+//
+// The archive is written through a pipe by a goroutine started on the first
+// read, entry by entry: each entry is opened when the archive reaches it and
+// closed right after, so nothing is staged to disk and only one entry is
+// open at a time. StreamProgress.Archive reports the entries written and the
+// content bytes read, and StreamProgress.Percentage is measured on the
+// content of the entries when all their sizes are known. The Content-Type
+// transport header is set to the media type of the format, unless already
+// set. A failing entry fails the stream with an error naming it; closing
+// the stream stops the archive.
+//
+// Parameters:
+//   - `format`: The archive format: ArchiveTar, ArchiveTarGzip or ArchiveZip.
+//   - `entries`: The entries, in archive order; tar entries need a known size.
+//   - `config`: The streaming configuration; nil selects the default one.
+//
+// Returns:
+//   - A pointer to a new StreamingWrapper reading the archive.
+//   - The wrapper of an unknown format carries a 400 status, and its stream fails.
+//
+// Example:
+//
+//	report, _ := replify.NewArchiveFileEntry("report.pdf", "/data/report.pdf")
+//	d, _ := replify.WrapOk("export", rows).Dump()
+//	defer d.Close()
+//
+//	streaming := replify.New().WithArchiveStreaming(replify.ArchiveZip, []replify.ArchiveEntry{
+//	    report,
+//	    replify.NewArchiveDumpEntry("rows.json", d),
+//	    replify.NewArchiveReaderEntry("README.txt", strings.NewReader(readme), int64(len(readme))),
+//	}, nil)
+//	streaming.WithWriter(rw)
+//	streaming.Start(r.Context())
+func (w *wrapper) WithArchiveStreaming(format ArchiveFormat, entries []ArchiveEntry, config *StreamConfig) *StreamingWrapper {
+	src := newArchiveSource(format, entries)
+	sw := w.WithStreaming(src, config)
+	sw.archive = src
+
+	var mediaType MediaType
+	switch format {
+	case ArchiveTar:
+		mediaType = MediaTypeApplicationTar
+	case ArchiveTarGzip:
+		mediaType = MediaTypeApplicationGzip
+	case ArchiveZip:
+		mediaType = MediaTypeApplicationZip
+	default:
+		sw.wrapper.
+			WithStatusCode(http.StatusBadRequest).
+			WithMessagef("Unknown archive format: %s", format).
+			BindCause()
+		return sw
+	}
+	if strutil.IsEmpty(sw.wrapper.HTTPHeader(HeaderContentType)) {
+		sw.wrapper.WithHTTPHeader(HeaderContentType, mediaType.String())
+	}
+	sw.wrapper.
+		WithDebuggingKV("archive_format", string(format)).
+		WithDebuggingKV("archive_entries", len(entries))
+	return sw
+}
+
+// Read reads the archive, starting to write it on the first call.
+func (a *archiveSource) Read(p []byte) (int, error) {
+	a.once.Do(func() { go a.run() })
+	return a.pr.Read(p)
+}
+
+// Close stops the archive; the goroutine writing it exits on its next write.
+func (a *archiveSource) Close() error {
+	return a.pr.Close()
+}
+
+// progress returns a snapshot of the progress of the archive.
+func (a *archiveSource) progress() *ArchiveProgress {
+	p := &ArchiveProgress{
+		Entries:     len(a.entries),
+		EntriesDone: int(a.done.Load()),
+		Bytes:       a.bytes.Load(),
+		TotalBytes:  a.total,
+	}
+	if name, ok := a.current.Load().(string); ok && p.EntriesDone < p.Entries {
+		p.CurrentEntry = name
+	}
+	return p
+}
+
+// run writes the archive into the pipe, then closes it with the outcome.
+func (a *archiveSource) run() {
+	a.pw.CloseWithError(a.write(a.pw))
+}
+
+// write writes the archive to w.
+func (a *archiveSource) write(w io.Writer) error {
+	switch a.format {
+	case ArchiveTar:
+		return a.writeTar(w)
+	case ArchiveTarGzip:
+		gz := gzip.NewWriter(w)
+		if err := a.writeTar(gz); err != nil {
+			return err
+		}
+		return gz.Close()
+	case ArchiveZip:
+		return a.writeZip(w)
+	}
+	return NewErrorf("unknown archive format: %s", a.format)
+}
+
+// writeTar writes the entries as a tar archive.
+func (a *archiveSource) writeTar(w io.Writer) error {
+	tw := tar.NewWriter(w)
+	now := time.Now()
+	for _, e := range a.entries {
+		name, err := archiveName(e.Name)
+		if err != nil {
+			return err
+		}
+		if e.Size < 0 {
+			return NewErrorf("archive entry %q: tar entries need a known size", name)
+		}
+		hdr := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Size:     e.Size,
+			Mode:     int64(archiveMode(e)),
+			ModTime:  archiveTime(e, now),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("archive entry %q: %w", name, err)
+		}
+		if err := a.copyEntry(tw, e, name); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// writeZip writes the entries as a zip archive.
+func (a *archiveSource) writeZip(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	now := time.Now()
+	for _, e := range a.entries {
+		name, err := archiveName(e.Name)
+		if err != nil {
+			return err
+		}
+		fh := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: archiveTime(e, now)}
+		fh.SetMode(archiveMode(e))
+		ew, err := zw.CreateHeader(fh)
+		if err != nil {
+			return fmt.Errorf("archive entry %q: %w", name, err)
+		}
+		if err := a.copyEntry(ew, e, name); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// copyEntry copies the content of an entry to w; an entry of known size
+// must hold exactly that many bytes.
+func (a *archiveSource) copyEntry(w io.Writer, e ArchiveEntry, name string) error {
+	a.current.Store(name)
+	if e.Open == nil {
+		return NewErrorf("archive entry %q: no content", name)
+	}
+	rc, err := e.Open()
+	if err != nil {
+		return fmt.Errorf("archive entry %q: %w", name, err)
+	}
+	defer rc.Close()
+
+	r := io.TeeReader(rc, archiveCounter{n: &a.bytes})
+	if e.Size < 0 {
+		_, err = io.Copy(w, r)
+	} else if n, cerr := io.CopyN(w, r, e.Size); cerr == io.EOF {
+		err = fmt.Errorf("%d of %d bytes: %w", n, e.Size, io.ErrUnexpectedEOF)
+	} else {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("archive entry %q: %w", name, err)
+	}
+	a.done.Add(1)
+	return nil
+}
+
+// Write counts the bytes of p.
+func (c archiveCounter) Write(p []byte) (int, error) {
+	c.n.Add(int64(len(p)))
+	return len(p), nil
+}
+
+// Close releases the reader.
+func (r *releaseReader) Close() error {
+	r.release()
+	return nil
+}
+
+// archiveName returns the clean slash-separated name of an entry, rejecting
+// names that are empty, absolute or escape the archive root.
+func archiveName(name string) (string, error) {
+	clean := path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	if strutil.IsEmpty(name) || clean == "." || strings.HasPrefix(clean, "/") || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", NewErrorf("invalid archive entry name %q", name)
+	}
+	return clean, nil
+}
+
+// archiveMode returns the file mode of an entry, 0644 by default.
+func archiveMode(e ArchiveEntry) fs.FileMode {
+	if e.Mode.Perm() == 0 {
+		return 0o644
+	}
+	return e.Mode.Perm()
+}
+
+// archiveTime returns the modification time of an entry, now by default.
+func archiveTime(e ArchiveEntry, now time.Time) time.Time {
+	if e.ModTime.IsZero() {
+		return now
+	}
+	return e.ModTime
+}
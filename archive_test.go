@@ -0,0 +1,175 @@
+package replify_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sivaosorg/replify"
+	"github.com/sivaosorg/replify/pkg/sysx"
+)
+
+// archiveEntries returns fresh entries of every kind, and their expected contents.
+func archiveEntries(t *testing.T, d *replify.Dump) ([]replify.ArchiveEntry, map[string][]byte) {
+	t.Helper()
+	fileData := checkpointSource(70 * 1024)
+	path := filepath.Join(t.TempDir(), "data.bin")
+	if err := os.WriteFile(path, fileData, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	file, err := replify.NewArchiveFileEntry("", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resourceData := []byte("name,total\nacme,42\n")
+	d.Rewind()
+	dumpData, _ := io.ReadAll(d.Resource().Content())
+	readme := "bundle generated on the fly"
+
+	entries := []replify.ArchiveEntry{
+		file,
+		replify.NewArchiveResourceEntry("reports/summary.csv", sysx.NewResource().FromBytes(resourceData)),
+		replify.NewArchiveDumpEntry("export.json", d),
+		replify.NewArchiveReaderEntry("README.txt", strings.NewReader(readme), int64(len(readme))),
+	}
+	return entries, map[string][]byte{
+		"data.bin":            fileData,
+		"reports/summary.csv": resourceData,
+		"export.json":         dumpData,
+		"README.txt":          []byte(readme),
+	}
+}
+
+func runArchive(t *testing.T, format replify.ArchiveFormat, entries []replify.ArchiveEntry) (*replify.StreamingWrapper, []byte) {
+	t.Helper()
+	var out bytes.Buffer
+	sw := replify.New().WithArchiveStreaming(format, entries, nil)
+	sw.WithChunkSize(8 * 1024)
+	sw.WithStreamingStrategy(replify.StrategyDirect)
+	sw.WithWriter(&out)
+	if w := sw.Start(context.Background()); w.IsError() {
+		t.Fatalf("%s: Start: %v", format, w.Error())
+	}
+	return sw, out.Bytes()
+}
+
+func readTar(t *testing.T, r io.Reader) map[string][]byte {
+	t.Helper()
+	files := make(map[string][]byte)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatalf("tar: %v", err)
+		}
+		files[hdr.Name], _ = io.ReadAll(tr)
+	}
+}
+
+func TestArchiveStreaming(t *testing.T) {
+	t.Parallel()
+
+	d, _ := replify.WrapOk("export", map[string]any{"rows": []int{1, 2, 3}}).Dump()
+	defer d.Close()
+
+	for _, format := range []replify.ArchiveFormat{replify.ArchiveTar, replify.ArchiveTarGzip, replify.ArchiveZip} {
+		entries, want := archiveEntries(t, d)
+		sw, archive := runArchive(t, format, entries)
+
+		var got map[string][]byte
+		switch format {
+		case replify.ArchiveTar:
+			got = readTar(t, bytes.NewReader(archive))
+		case replify.ArchiveTarGzip:
+			gz, err := gzip.NewReader(bytes.NewReader(archive))
+			if err != nil {
+				t.Fatalf("gzip: %v", err)
+			}
+			got = readTar(t, gz)
+		case replify.ArchiveZip:
+			zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+			if err != nil {
+				t.Fatalf("zip: %v", err)
+			}
+			got = make(map[string][]byte)
+			for _, f := range zr.File {
+				rc, _ := f.Open()
+				got[f.Name], _ = io.ReadAll(rc)
+				rc.Close()
+			}
+		}
+		if len(got) != len(want) {
+			t.Errorf("%s: %d entries, want %d", format, len(got), len(want))
+		}
+		for name, data := range want {
+			if !bytes.Equal(got[name], data) {
+				t.Errorf("%s: entry %s differs", format, name)
+			}
+		}
+
+		var total int64
+		for _, data := range want {
+			total += int64(len(data))
+		}
+		progress := sw.GetProgress()
+		if a := progress.Archive; a == nil || a.Entries != 4 || a.EntriesDone != 4 || a.Bytes != total || a.TotalBytes != total {
+			t.Errorf("%s: archive progress = %+v", format, progress.Archive)
+		}
+		if progress.Percentage != 100 {
+			t.Errorf("%s: percentage = %d", format, progress.Percentage)
+		}
+	}
+}
+
+func TestArchiveStreamingHeaders(t *testing.T) {
+	t.Parallel()
+
+	sw := replify.New().WithArchiveStreaming(replify.ArchiveZip, nil, nil)
+	if ct := sw.GetWrapper().HTTPHeader(replify.HeaderContentType); ct != "application/zip" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if w := replify.New().WithArchiveStreaming("rar", nil, nil).GetWrapper(); !w.IsError() {
+		t.Error("expected an unknown format to be rejected")
+	}
+}
+
+func TestArchiveStreamingErrors(t *testing.T) {
+	t.Parallel()
+
+	start := func(format replify.ArchiveFormat, entries ...replify.ArchiveEntry) *replify.StreamingWrapper {
+		sw := replify.New().WithArchiveStreaming(format, entries, nil)
+		sw.WithStreamingStrategy(replify.StrategyDirect)
+		sw.WithWriter(io.Discard)
+		sw.Start(context.Background())
+		return sw
+	}
+	unsized := replify.NewArchiveReaderEntry("log.txt", strings.NewReader("lines"), -1)
+	if sw := start(replify.ArchiveTar, unsized); !sw.GetWrapper().IsError() {
+		t.Error("tar: expected an entry of unknown size to fail")
+	}
+	unsized = replify.NewArchiveReaderEntry("log.txt", strings.NewReader("lines"), -1)
+	if sw := start(replify.ArchiveZip, unsized); sw.GetWrapper().IsError() || sw.GetProgress().Archive.TotalBytes != -1 {
+		t.Errorf("zip: entry of unknown size: %v", sw.GetWrapper().Error())
+	}
+	short := replify.NewArchiveReaderEntry("short.txt", strings.NewReader("abc"), 10)
+	if sw := start(replify.ArchiveTar, short); !sw.GetWrapper().IsError() {
+		t.Error("tar: expected a short entry to fail")
+	}
+	escaping := replify.NewArchiveReaderEntry("../etc/passwd", strings.NewReader("x"), 1)
+	if sw := start(replify.ArchiveZip, escaping); !sw.GetWrapper().IsError() {
+		t.Error("zip: expected an escaping name to fail")
+	}
+	if _, err := replify.NewArchiveFileEntry("", t.TempDir()); err == nil {
+		t.Error("expected a directory to be rejected")
+	}
+}
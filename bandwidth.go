@@ -0,0 +1,294 @@
+package replify
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// NewChild creates a limiter nested in l, e.g. the limit of a tenant within
+// a global limit. Bytes granted by the child are also granted by l and its
+// ancestors; when the children of l compete for its bandwidth, each gets a
+// share proportional to its weight.
+//
+// Parameters:
+//   - `name`: The name of the child, reported in its statistics.
+//   - `rate`: The rate of the child in bytes per second; 0 or less means
+//     limited by its ancestors only.
+//   - `burst`: The bucket capacity in bytes; 0 or less selects one second of rate.
+//   - `weight`: The share of the child in l; values below 1 are treated as 1.
+//
+// Returns:
+//   - A pointer to the new child limiter.
+//
+// Example:
+//
+//	nic := replify.NewBandwidthLimiter(100<<20, 0)
+//	gold := nic.NewChild("gold", 0, 0, 3)
+//	free := nic.NewChild("free", 10<<20, 0, 1)
+func (l *BandwidthLimiter) NewChild(name string, rate, burst int64, weight int) *BandwidthLimiter {
+	return newBandwidthLimiter(name, l, rate, burst, weight)
+}
+
+// WaitN blocks until n bytes are granted by l and its ancestors, or until
+// the context is done. Callers of WaitN share a single flow of weight 1.
+//
+// Parameters:
+//   - `ctx`: The context bounding the wait.
+//   - `n`: The number of bytes.
+//
+// Returns:
+//   - The error of the context if it is done before the bytes are granted.
+func (l *BandwidthLimiter) WaitN(ctx context.Context, n int64) error {
+	return l.wait(ctx, nil, 1, n)
+}
+
+// SetRate changes the rate of the limiter, effective for the waiting
+// requests too.
+//
+// Parameters:
+//   - `rate`: The rate in bytes per second; 0 or less means unlimited.
+func (l *BandwidthLimiter) SetRate(rate int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.refill(now)
+	l.rate = max(rate, 0)
+	l.tokens = min(l.tokens, float64(l.capacity()))
+	l.schedule()
+}
+
+// SetBurst changes the bucket capacity of the limiter.
+//
+// Parameters:
+//   - `burst`: The capacity in bytes; 0 or less selects one second of rate.
+func (l *BandwidthLimiter) SetBurst(burst int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(time.Now())
+	l.burst = max(burst, 0)
+	l.tokens = min(l.tokens, float64(l.capacity()))
+	l.schedule()
+}
+
+// SetWeight changes the share of the limiter in its parent.
+//
+// Parameters:
+//   - `weight`: The weight; values below 1 are treated as 1.
+func (l *BandwidthLimiter) SetWeight(weight int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.weight = max(weight, 1)
+}
+
+// Rate returns the rate of the limiter in bytes per second; 0 means unlimited.
+func (l *BandwidthLimiter) Rate() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// Stats returns a snapshot of the statistics of the limiter.
+func (l *BandwidthLimiter) Stats() BandwidthLimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := l.stats
+	stats.Name = l.name
+	stats.Rate = l.rate
+	stats.Burst = l.capacity()
+	stats.Weight = l.weight
+	stats.Queued = len(l.queue)
+	stats.Flows = len(l.finish)
+	return stats
+}
+
+// WithBandwidthLimiter attaches the stream to a shared bandwidth limiter.
+//
+// Every write of the stream, framing and fan-out included, waits for its
+// bytes to be granted by the limiter and its ancestors, so that all the
+// streams attached to a limiter share its rate, in proportion to their
+// weights. Rate changes made with [BandwidthLimiter.SetRate] apply to
+// running streams. The limiter adds to the rate set with
+// [StreamingWrapper.WithThrottleRate].
+//
+// Parameters:
+//   - `limiter`: The limiter; nil detaches the stream.
+//   - `weight`: The share of the stream in the limiter; values below 1 are treated as 1.
+//
+// Returns:
+//   - A pointer to the underlying [wrapper] instance, allowing for method chaining.
+//   - If the streaming wrapper is nil, returns a new wrapper with an error message.
+//
+// Example:
+//
+//	nic := replify.NewBandwidthLimiter(100<<20, 0)
+//	tenant := nic.NewChild(tenantID, 20<<20, 0, 1)
+//
+//	streaming := replify.New().WithStreaming(export, nil)
+//	streaming.WithBandwidthLimiter(tenant, 1)
+//	streaming.WithWriter(rw)
+//	streaming.Start(r.Context())
+func (sw *StreamingWrapper) WithBandwidthLimiter(limiter *BandwidthLimiter, weight int) *wrapper {
+	if sw == nil {
+		return respondStreamBadRequestDefault()
+	}
+	if sw.IsStreaming() {
+		return sw.wrapper.
+			WithStatusCode(http.StatusConflict).
+			WithMessage("cannot change the bandwidth limiter while streaming").
+			BindCause()
+	}
+	sw.limiter = limiter
+	sw.limiterWeight = max(weight, 1)
+	if limiter != nil {
+		sw.wrapper.WithDebuggingKV("bandwidth_limiter", limiter.name)
+	}
+	return sw.wrapper
+}
+
+// waitBandwidth waits for the shared limiter of the stream, if any, to
+// grant n bytes.
+func (sw *StreamingWrapper) waitBandwidth(ctx context.Context, n int) error {
+	if sw.limiter == nil || n == 0 {
+		return nil
+	}
+	return sw.limiter.wait(ctx, sw, sw.limiterWeight, int64(n))
+}
+
+// releaseBandwidth forgets the fair-queuing state of the stream once it
+// completes.
+func (sw *StreamingWrapper) releaseBandwidth() {
+	if l := sw.limiter; l != nil {
+		l.mu.Lock()
+		delete(l.finish, sw)
+		l.mu.Unlock()
+	}
+}
+
+// wait acquires n bytes from l for the flow identified by key, then from
+// each ancestor of l for l itself.
+func (l *BandwidthLimiter) wait(ctx context.Context, key any, weight int, n int64) error {
+	for level := l; level != nil; level = level.parent {
+		if err := level.acquire(ctx, key, weight, n); err != nil {
+			return err
+		}
+		level.mu.Lock()
+		key, weight = level, level.weight
+		level.mu.Unlock()
+	}
+	return nil
+}
+
+// acquire waits until the limiter grants n bytes to a flow.
+//
+// Requests are tagged as in self-clocked fair queuing: a request starts at
+// the later of the virtual time and the finish tag of the previous request
+// of its flow, and finishes n/weight later. Waiting requests are granted in
+// order of finish tags, so that busy flows share the rate in proportion to
+// their weights.
+func (l *BandwidthLimiter) acquire(ctx context.Context, key any, weight int, n int64) error {
+	l.mu.Lock()
+	begin := time.Now()
+	l.refill(begin)
+	l.stats.Requests++
+	l.seq++
+	w := &bandwidthWaiter{
+		n:      n,
+		finish: max(l.virtual, l.finish[key]) + float64(n)/float64(max(weight, 1)),
+		seq:    l.seq,
+		ready:  make(chan struct{}),
+	}
+	l.finish[key] = w.finish
+	if len(l.queue) == 0 && l.admits(n) {
+		l.grant(w)
+		l.mu.Unlock()
+		return nil
+	}
+	l.stats.Waits++
+	l.queue = append(l.queue, w)
+	l.schedule()
+	l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+	case <-ctx.Done():
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stats.WaitTime += time.Since(begin)
+	if w.granted {
+		return nil
+	}
+	l.queue = slices.DeleteFunc(l.queue, func(q *bandwidthWaiter) bool { return q == w })
+	l.schedule()
+	return ctx.Err()
+}
+
+// schedule grants the waiting requests in order of finish tags while tokens
+// are available, then arms the timer for the next one. The caller holds mu.
+func (l *BandwidthLimiter) schedule() {
+	l.refill(time.Now())
+	for len(l.queue) > 0 {
+		i := 0
+		for j, q := range l.queue {
+			if head := l.queue[i]; q.finish < head.finish || (q.finish == head.finish && q.seq < head.seq) {
+				i = j
+			}
+		}
+		w := l.queue[i]
+		if !l.admits(w.n) {
+			need := float64(min(w.n, l.capacity())) - l.tokens
+			delay := time.Duration(need / float64(l.rate) * float64(time.Second))
+			if l.timer == nil {
+				l.timer = time.AfterFunc(delay, l.wake)
+			} else {
+				l.timer.Reset(delay)
+			}
+			return
+		}
+		l.queue = slices.Delete(l.queue, i, i+1)
+		l.grant(w)
+	}
+}
+
+// wake runs the queue once the timer fires.
+func (l *BandwidthLimiter) wake() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.schedule()
+}
+
+// admits reports whether a request of n bytes can be granted now. Requests
+// larger than the burst are granted on a full bucket, leaving it in debt.
+// The caller holds mu.
+func (l *BandwidthLimiter) admits(n int64) bool {
+	return l.rate == 0 || l.tokens >= float64(min(n, l.capacity()))
+}
+
+// grant grants a request. The caller holds mu.
+func (l *BandwidthLimiter) grant(w *bandwidthWaiter) {
+	if l.rate > 0 {
+		l.tokens -= float64(w.n)
+	}
+	l.virtual = max(l.virtual, w.finish)
+	l.stats.BytesGranted += w.n
+	w.granted = true
+	close(w.ready)
+}
+
+// refill adds the tokens accrued since the last refill. The caller holds mu.
+func (l *BandwidthLimiter) refill(now time.Time) {
+	if l.rate > 0 {
+		l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*float64(l.rate), float64(l.capacity()))
+	}
+	l.last = now
+}
+
+// capacity returns the bucket capacity in bytes. The caller holds mu.
+func (l *BandwidthLimiter) capacity() int64 {
+	if l.burst > 0 {
+		return l.burst
+	}
+	return max(l.rate, 1)
+}
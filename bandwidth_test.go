@@ -0,0 +1,130 @@
+package replify_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sivaosorg/replify"
+)
+
+func TestBandwidthLimiterRate(t *testing.T) {
+	t.Parallel()
+
+	l := replify.NewBandwidthLimiter(100*1024, 10*1024)
+	begin := time.Now()
+	for range 6 {
+		if err := l.WaitN(context.Background(), 10*1024); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The first 10 KiB are the burst; 50 KiB more take 500ms.
+	if elapsed := time.Since(begin); elapsed < 400*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("elapsed = %s, want about 500ms", elapsed)
+	}
+	stats := l.Stats()
+	if stats.BytesGranted != 60*1024 || stats.Requests != 6 || stats.Waits == 0 || stats.Burst != 10*1024 || stats.Name != "global" {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestBandwidthLimiterSharedByStreams(t *testing.T) {
+	t.Parallel()
+
+	l := replify.NewBandwidthLimiter(128*1024, 16*1024)
+	data := checkpointSource(32 * 1024)
+	var wg sync.WaitGroup
+	outs := make([]bytes.Buffer, 2)
+	begin := time.Now()
+	for i := range outs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sw := newCheckpointStream(data, replify.StrategyDirect)
+			sw.WithBandwidthLimiter(l, 1)
+			sw.WithWriter(&outs[i])
+			if w := sw.Start(context.Background()); w.IsError() {
+				t.Errorf("stream %d: %v", i, w.Error())
+			}
+		}()
+	}
+	wg.Wait()
+	// 64 KiB at 128 KiB/s with a 16 KiB burst take 375ms.
+	if elapsed := time.Since(begin); elapsed < 300*time.Millisecond {
+		t.Errorf("elapsed = %s, want about 375ms", elapsed)
+	}
+	for i := range outs {
+		if !bytes.Equal(outs[i].Bytes(), data) {
+			t.Errorf("stream %d: output differs from the source", i)
+		}
+	}
+	if stats := l.Stats(); stats.BytesGranted != 64*1024 || stats.Flows != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestBandwidthLimiterWeightedChildren(t *testing.T) {
+	t.Parallel()
+
+	root := replify.NewBandwidthLimiter(256*1024, 4*1024)
+	gold := root.NewChild("gold", 0, 0, 3)
+	free := root.NewChild("free", 0, 0, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, l := range []*replify.BandwidthLimiter{gold, free} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for l.WaitN(ctx, 1024) == nil {
+			}
+		}()
+	}
+	wg.Wait()
+	g, f := gold.Stats().BytesGranted, free.Stats().BytesGranted
+	if ratio := float64(g) / float64(f); f == 0 || ratio < 2 || ratio > 4.5 {
+		t.Errorf("gold %d bytes, free %d bytes: ratio %.2f, want about 3", g, f, float64(g)/float64(max(f, 1)))
+	}
+}
+
+func TestBandwidthLimiterSetRate(t *testing.T) {
+	t.Parallel()
+
+	l := replify.NewBandwidthLimiter(1024, 1024)
+	if err := l.WaitN(context.Background(), 1024); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan time.Time)
+	begin := time.Now()
+	go func() {
+		l.WaitN(context.Background(), 1024)
+		done <- time.Now()
+	}()
+	time.Sleep(50 * time.Millisecond)
+	l.SetRate(1 << 20)
+	if at := <-done; at.Sub(begin) > 500*time.Millisecond {
+		t.Errorf("waited %s after raising the rate", at.Sub(begin))
+	}
+	if l.Rate() != 1<<20 {
+		t.Errorf("Rate = %d", l.Rate())
+	}
+}
+
+func TestBandwidthLimiterCancel(t *testing.T) {
+	t.Parallel()
+
+	l := replify.NewBandwidthLimiter(1, 1)
+	l.WaitN(context.Background(), 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := l.WaitN(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitN = %v, want DeadlineExceeded", err)
+	}
+	if stats := l.Stats(); stats.Queued != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
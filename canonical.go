@@ -0,0 +1,61 @@
+package replify
+
+import (
+	"github.com/sivaosorg/replify/pkg/encoding"
+	"github.com/sivaosorg/replify/pkg/strutil"
+)
+
+// WithCanonicalJSON enables or disables RFC 8785 canonical output for the [wrapper] instance.
+//
+// When enabled, [wrapper.JSON] and [wrapper.JSONBytes] emit the canonical form
+// (sorted keys, ECMAScript number formatting, minimal escaping) and both
+// [wrapper.Hash256] and the internal response cache key are computed over it.
+// The same logical response therefore serializes and hashes identically in
+// every process, which makes the output safe to sign or compare across services.
+//
+// Parameters:
+//   - `enabled`: Whether canonical output is used.
+//
+// Returns:
+//   - A pointer to the modified [wrapper] instance (enabling method chaining).
+//
+// Example:
+//
+//	w := replify.WrapOk("ok", map[string]any{"b": 1.0, "a": 2}).WithCanonicalJSON(true)
+//	w.JSON()    // {"data":{"a":2,"b":1},...}
+//	w.Hash256() // identical for equal responses built anywhere
+func (w *wrapper) WithCanonicalJSON(enabled bool) *wrapper {
+	if !w.Available() {
+		return w
+	}
+	w.canonical = enabled
+	return w
+}
+
+// IsCanonicalJSON reports whether RFC 8785 canonical output is enabled.
+//
+// Returns:
+//   - `true` if the [wrapper] is available and canonical output is enabled.
+func (w *wrapper) IsCanonicalJSON() bool {
+	return w.Available() && w.canonical
+}
+
+// canonicalJSON renders the envelope in canonical form, falling back to the
+// regular encoding if the payload cannot be canonicalized (e.g. it holds a
+// number that does not fit an IEEE 754 double).
+func (w *wrapper) canonicalJSON() string {
+	s, err := encoding.CanonicalJSONString(w.Respond())
+	if err != nil {
+		return jsonpass(w.Respond())
+	}
+	return s
+}
+
+// canonicalHash256 returns the hex SHA-256 of the canonical JSON of values.
+func canonicalHash256(values ...any) (string, error) {
+	s, err := encoding.CanonicalJSONString(values)
+	if err != nil {
+		return "", err
+	}
+	return strutil.Hash256(s), nil
+}
@@ -0,0 +1,37 @@
+package replify_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sivaosorg/replify"
+)
+
+func TestWithCanonicalJSON(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	build := func(data any) interface {
+		JSON() string
+		Hash256() string
+	} {
+		return replify.WrapOk("ok", data).
+			WithRequestID("req-1").
+			WithRequestedTime(at).
+			WithCanonicalJSON(true)
+	}
+
+	a := build(map[string]any{"b": 1.0, "a": "<x>"})
+	b := build(map[string]any{"a": "<x>", "b": 1})
+
+	if a.JSON() != b.JSON() {
+		t.Fatalf("canonical output differs:\n%s\n%s", a.JSON(), b.JSON())
+	}
+	if !strings.Contains(a.JSON(), `"data":{"a":"<x>","b":1}`) {
+		t.Errorf("unexpected canonical output: %s", a.JSON())
+	}
+	if a.Hash256() == "" || a.Hash256() != b.Hash256() {
+		t.Errorf("canonical hashes differ: %q vs %q", a.Hash256(), b.Hash256())
+	}
+}
@@ -0,0 +1,97 @@
+package replify
+
+import "io"
+
+// cdcGear is the gear table of the content-defined chunker: 256 fixed
+// pseudo-random values drawn with SplitMix64 from cdcGearSeed.
+var cdcGear = func() (gear [256]uint64) {
+	state := cdcGearSeed
+	for i := range gear {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		gear[i] = z ^ (z >> 31)
+	}
+	return gear
+}()
+
+// Next returns the next chunk of the source.
+//
+// The chunk is only valid until the next call. The same data always yields
+// the same chunks, whatever the way it is read, so chunks can be matched
+// across transfers by their digests.
+//
+// Returns:
+//   - The next chunk.
+//   - io.EOF once the source is drained, or the error reading it.
+func (c *CDCChunker) Next() ([]byte, error) {
+	if err := c.fill(); err != nil {
+		return nil, err
+	}
+	if c.start == c.end {
+		if c.err != nil {
+			return nil, c.err
+		}
+		return nil, io.EOF
+	}
+	n := c.cut(c.buf[c.start:c.end])
+	chunk := c.buf[c.start : c.start+n]
+	c.start += n
+	return chunk, nil
+}
+
+// AverageSize returns the average chunk size; chunks are between a quarter
+// and four times this size.
+func (c *CDCChunker) AverageSize() int {
+	return c.avg
+}
+
+// fill reads ahead until a whole chunk of the largest size is buffered or
+// the source is drained.
+func (c *CDCChunker) fill() error {
+	if c.end-c.start >= c.max || c.eof {
+		return nil
+	}
+	c.end = copy(c.buf, c.buf[c.start:c.end])
+	c.start = 0
+	for c.end < c.max && !c.eof {
+		n, err := c.r.Read(c.buf[c.end:c.max])
+		c.end += n
+		switch {
+		case err == io.EOF:
+			c.eof = true
+		case err != nil:
+			// Chunk what was read, then report the error.
+			c.eof, c.err = true, err
+		}
+	}
+	return nil
+}
+
+// cut returns the length of the chunk starting data, using the normalized
+// chunking of FastCDC: a harder mask below the average size and an easier
+// one above, which narrows the spread of chunk sizes.
+func (c *CDCChunker) cut(data []byte) int {
+	n := len(data)
+	if n <= c.min {
+		return n
+	}
+	n = min(n, c.max)
+	normal := min(c.avg, n)
+	var fp uint64
+	i := c.min
+	for ; i < normal; i++ {
+		fp = (fp << 1) + cdcGear[data[i]]
+		if fp&c.maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		fp = (fp << 1) + cdcGear[data[i]]
+		if fp&c.maskL == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
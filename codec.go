@@ -0,0 +1,364 @@
+package replify
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sivaosorg/replify/pkg/strutil"
+)
+
+// codecs holds the codecs registered with [RegisterCodec].
+var codecs = newCodecRegistry()
+
+// RegisterCodec registers a compression codec, or replaces the registered
+// codec of the same name, together with the options it is used with.
+//
+// Registered codecs are available everywhere the package compresses: as the
+// CompressionType of a [StreamingWrapper], in [wrapper.CompressSafe] and
+// [wrapper.DecompressSafe], and in the Content-Encoding negotiation of
+// [wrapper.ServeHTTP]. The built-in codecs are "gzip", "deflate" (the zlib
+// format, as HTTP defines it) and "flate" (raw DEFLATE); re-registering one
+// of them changes its options.
+//
+// Parameters:
+//   - `codec`: The codec; its name must not be empty.
+//   - `options`: The compression level and dictionary the codec is used with.
+//
+// Returns:
+//   - An error if the codec is nil or has no name.
+//
+// Example:
+//
+//	// Favor ratio over speed for every gzip encoding.
+//	codec, _, _ := replify.LookupCodec("gzip")
+//	replify.RegisterCodec(codec, replify.CodecOptions{Level: gzip.BestCompression})
+//
+//	// Add an in-house codec, usable as a streaming compression type.
+//	replify.RegisterCodec(snappyCodec{}, replify.CodecOptions{})
+//	cfg.Compression = replify.CompressionType("snappy")
+func RegisterCodec(codec Codec, options CodecOptions) error {
+	if codec == nil {
+		return NewError("RegisterCodec: codec is nil")
+	}
+	name := strings.ToLower(codec.Name())
+	if strutil.IsEmpty(name) || name == encodingIdentity || name == string(CompressNone) {
+		return NewErrorf("RegisterCodec: invalid codec name %q", codec.Name())
+	}
+	codecs.mu.Lock()
+	defer codecs.mu.Unlock()
+	entry := codecEntry{codec: codec, options: options}
+	for i, e := range codecs.entries {
+		if strings.EqualFold(e.codec.Name(), name) {
+			codecs.entries[i] = entry
+			return nil
+		}
+	}
+	codecs.entries = append(codecs.entries, entry)
+	return nil
+}
+
+// UnregisterCodec removes a codec registered with [RegisterCodec]. The
+// built-in codecs cannot be removed; re-register them to change their
+// options instead.
+//
+// Parameters:
+//   - `name`: The codec name, case-insensitive.
+//
+// Returns:
+//   - `true` if a codec was removed.
+func UnregisterCodec(name string) bool {
+	switch CompressionType(strings.ToLower(name)) {
+	case CompressGzip, CompressDeflate, CompressFlate:
+		return false
+	}
+	codecs.mu.Lock()
+	defer codecs.mu.Unlock()
+	n := len(codecs.entries)
+	codecs.entries = slices.DeleteFunc(codecs.entries, func(e codecEntry) bool {
+		return strings.EqualFold(e.codec.Name(), name)
+	})
+	return len(codecs.entries) < n
+}
+
+// LookupCodec returns the codec registered under a name.
+//
+// Parameters:
+//   - `name`: The codec name, case-insensitive.
+//
+// Returns:
+//   - The codec and the options it is used with.
+//   - `false` if no codec is registered under the name.
+func LookupCodec(name string) (Codec, CodecOptions, bool) {
+	codecs.mu.RLock()
+	defer codecs.mu.RUnlock()
+	for _, e := range codecs.entries {
+		if strings.EqualFold(e.codec.Name(), name) {
+			return e.codec, e.options, true
+		}
+	}
+	return nil, CodecOptions{}, false
+}
+
+// RegisteredCodecs returns the names of the registered codecs in
+// registration order, which is also their order of preference when
+// negotiating a Content-Encoding.
+func RegisteredCodecs() []string {
+	codecs.mu.RLock()
+	defer codecs.mu.RUnlock()
+	names := make([]string, len(codecs.entries))
+	for i, e := range codecs.entries {
+		names[i] = strings.ToLower(e.codec.Name())
+	}
+	return names
+}
+
+// CompressBytes compresses data with a registered codec.
+//
+// Parameters:
+//   - `name`: The codec name.
+//   - `data`: The data to compress.
+//
+// Returns:
+//   - The compressed data.
+//   - An error if the codec is unknown or fails.
+//
+// Example:
+//
+//	packed, err := replify.CompressBytes("gzip", payload)
+func CompressBytes(name string, data []byte) ([]byte, error) {
+	codec, options, ok := LookupCodec(name)
+	if !ok {
+		return nil, NewErrorf("unknown compression codec %q", name)
+	}
+	var buf bytes.Buffer
+	zw, err := codec.NewWriter(&buf, options)
+	if err != nil {
+		return nil, NewErrorf("%s compression failed: %v", name, err)
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, NewErrorf("%s compression failed: %v", name, err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, NewErrorf("%s compression failed: %v", name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// DecompressBytes decompresses data with a registered codec.
+//
+// Parameters:
+//   - `name`: The codec name.
+//   - `data`: The compressed data.
+//
+// Returns:
+//   - The decompressed data.
+//   - An error if the codec is unknown or the data is corrupt.
+func DecompressBytes(name string, data []byte) ([]byte, error) {
+	codec, options, ok := LookupCodec(name)
+	if !ok {
+		return nil, NewErrorf("unknown compression codec %q", name)
+	}
+	zr, err := codec.NewReader(bytes.NewReader(data), options)
+	if err != nil {
+		return nil, NewErrorf("%s decompression failed: %v", name, err)
+	}
+	defer zr.Close()
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, NewErrorf("%s decompression failed: %v", name, err)
+	}
+	return out, nil
+}
+
+// NegotiateEncoding selects the registered codec preferred by an
+// Accept-Encoding request header (RFC 9110 §12.5.3).
+//
+// Codings are ranked by quality value; ties go to the codec registered
+// first. "*" stands for every registered codec not listed explicitly, and a
+// quality of 0 rules a coding out.
+//
+// Parameters:
+//   - `accept`: The Accept-Encoding header value.
+//
+// Returns:
+//   - The selected codec name, or "" when the response should not be encoded.
+//
+// Example:
+//
+//	replify.NegotiateEncoding("br;q=1.0, gzip;q=0.8, *;q=0.1") // "gzip"
+func NegotiateEncoding(accept string) string {
+	if strutil.IsEmpty(accept) {
+		return ""
+	}
+	quality := make(map[string]float64)
+	wildcard := -1.0
+	for part := range strings.SplitSeq(accept, ",") {
+		token, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		token = strings.ToLower(strings.TrimSpace(token))
+		if token == "" {
+			continue
+		}
+		q := 1.0
+		for param := range strings.SplitSeq(params, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(strings.TrimSpace(key), "q") {
+				if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if token == "*" {
+			wildcard = q
+			continue
+		}
+		quality[token] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, name := range RegisteredCodecs() {
+		q, listed := quality[name]
+		if !listed {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// WithCompressionCodec sets the codec used by [wrapper.CompressSafe].
+//
+// Parameters:
+//   - `name`: A registered codec name; empty selects gzip.
+//
+// Returns:
+//   - A pointer to the modified [wrapper] instance (enabling method chaining).
+func (w *wrapper) WithCompressionCodec(name string) *wrapper {
+	if !w.Available() {
+		return w
+	}
+	w.codec = strings.ToLower(name)
+	return w
+}
+
+// CompressionCodec returns the codec used by [wrapper.CompressSafe].
+func (w *wrapper) CompressionCodec() string {
+	if !w.Available() || strutil.IsEmpty(w.codec) {
+		return defaultCodec
+	}
+	return w.codec
+}
+
+// WithContentEncoding makes [wrapper.ServeHTTP] compress the response body
+// with the registered codec the client prefers according to its
+// Accept-Encoding header, and set Content-Encoding and Vary accordingly.
+// Bodies smaller than minSize, and responses that already carry a
+// Content-Encoding header, are sent as is.
+//
+// Parameters:
+//   - `minSize`: The smallest body, in bytes, worth encoding; values below 0 disable encoding.
+//
+// Returns:
+//   - A pointer to the modified [wrapper] instance (enabling method chaining).
+//
+// Example:
+//
+//	replify.WrapOk("ok", report).WithContentEncoding(1024).ServeHTTP(rw, r)
+func (w *wrapper) WithContentEncoding(minSize int) *wrapper {
+	if !w.Available() {
+		return w
+	}
+	w.contentEncoding = minSize >= 0
+	w.encodingMinSize = max(minSize, 0)
+	return w
+}
+
+// encodeBody compresses an HTTP response body with the coding negotiated
+// from the Accept-Encoding header, updating the response headers.
+func (w *wrapper) encodeBody(header http.Header, body string, accept string) string {
+	if !w.contentEncoding {
+		return body
+	}
+	header.Add(HeaderVary.String(), HeaderAcceptEncoding.String())
+	if len(body) < w.encodingMinSize || header.Get(HeaderContentEncoding.String()) != "" {
+		return body
+	}
+	name := NegotiateEncoding(accept)
+	if strutil.IsEmpty(name) {
+		return body
+	}
+	encoded, err := CompressBytes(name, []byte(body))
+	if err != nil {
+		return body
+	}
+	header.Set(HeaderContentEncoding.String(), name)
+	header.Del("Content-Length")
+	return string(encoded)
+}
+
+// Name returns "gzip".
+func (gzipCodec) Name() string { return string(CompressGzip) }
+
+// NewWriter returns a gzip writer; gzip has no preset dictionary.
+func (gzipCodec) NewWriter(w io.Writer, options CodecOptions) (io.WriteCloser, error) {
+	if len(options.Dictionary) > 0 {
+		return nil, NewError("gzip does not support preset dictionaries")
+	}
+	return gzip.NewWriterLevel(w, codecLevel(options.Level))
+}
+
+// NewReader returns a gzip reader.
+func (gzipCodec) NewReader(r io.Reader, _ CodecOptions) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// Name returns "deflate".
+func (zlibCodec) Name() string { return string(CompressDeflate) }
+
+// NewWriter returns a zlib writer.
+func (zlibCodec) NewWriter(w io.Writer, options CodecOptions) (io.WriteCloser, error) {
+	return zlib.NewWriterLevelDict(w, codecLevel(options.Level), options.Dictionary)
+}
+
+// NewReader returns a zlib reader.
+func (zlibCodec) NewReader(r io.Reader, options CodecOptions) (io.ReadCloser, error) {
+	return zlib.NewReaderDict(r, options.Dictionary)
+}
+
+// Name returns "flate".
+func (flateCodec) Name() string { return string(CompressFlate) }
+
+// NewWriter returns a raw DEFLATE writer.
+func (flateCodec) NewWriter(w io.Writer, options CodecOptions) (io.WriteCloser, error) {
+	return flate.NewWriterDict(w, codecLevel(options.Level), options.Dictionary)
+}
+
+// NewReader returns a raw DEFLATE reader.
+func (flateCodec) NewReader(r io.Reader, options CodecOptions) (io.ReadCloser, error) {
+	return flate.NewReaderDict(r, options.Dictionary), nil
+}
+
+// codecLevel maps the 0 "codec default" level to the DEFLATE default.
+func codecLevel(level int) int {
+	if level == 0 {
+		return flate.DefaultCompression
+	}
+	return level
+}
+
+// isCompressionSupported reports whether a compression type is CompressNone
+// or a registered codec.
+func isCompressionSupported(comp CompressionType) bool {
+	if comp == CompressNone {
+		return true
+	}
+	return slices.Contains(RegisteredCodecs(), strings.ToLower(string(comp)))
+}
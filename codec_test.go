@@ -0,0 +1,168 @@
+package replify_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sivaosorg/replify"
+)
+
+// xorCodec is a toy codec that flips every bit.
+type xorCodec struct{}
+
+type xorWriter struct{ w io.Writer }
+
+func (x xorWriter) Write(p []byte) (int, error) { return x.w.Write(invert(p)) }
+func (x xorWriter) Close() error                { return nil }
+
+type xorReader struct{ r io.Reader }
+
+func (x xorReader) Read(p []byte) (int, error) {
+	n, err := x.r.Read(p)
+	copy(p[:n], invert(p[:n]))
+	return n, err
+}
+func (x xorReader) Close() error { return nil }
+
+func (xorCodec) Name() string { return "x-xor" }
+func (xorCodec) NewWriter(w io.Writer, _ replify.CodecOptions) (io.WriteCloser, error) {
+	return xorWriter{w}, nil
+}
+func (xorCodec) NewReader(r io.Reader, _ replify.CodecOptions) (io.ReadCloser, error) {
+	return xorReader{r}, nil
+}
+
+func TestCodecBuiltinRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	data := bytes.Repeat([]byte("replify codec "), 200)
+	for _, name := range []string{"gzip", "deflate", "flate"} {
+		packed, err := replify.CompressBytes(name, data)
+		if err != nil {
+			t.Fatalf("%s: CompressBytes: %v", name, err)
+		}
+		if len(packed) >= len(data) {
+			t.Errorf("%s: %d bytes compressed to %d", name, len(data), len(packed))
+		}
+		unpacked, err := replify.DecompressBytes(name, packed)
+		if err != nil || !bytes.Equal(unpacked, data) {
+			t.Errorf("%s: round trip failed: %v", name, err)
+		}
+	}
+	if _, err := replify.CompressBytes("unknown", data); err == nil {
+		t.Error("expected an error for an unknown codec")
+	}
+	if err := replify.RegisterCodec(nil, replify.CodecOptions{}); err == nil {
+		t.Error("expected an error for a nil codec")
+	}
+	if replify.UnregisterCodec("GZIP") {
+		t.Error("a built-in codec must not be unregistered")
+	}
+}
+
+func TestCodecCustomRegistration(t *testing.T) {
+	t.Parallel()
+
+	if err := replify.RegisterCodec(xorCodec{}, replify.CodecOptions{}); err != nil {
+		t.Fatalf("RegisterCodec: %v", err)
+	}
+	t.Cleanup(func() { replify.UnregisterCodec("x-xor") })
+	if !slices.Contains(replify.RegisteredCodecs(), "x-xor") {
+		t.Fatalf("RegisteredCodecs() = %v", replify.RegisteredCodecs())
+	}
+
+	// Streaming compression.
+	data := checkpointSource(4 * 1024)
+	var out bytes.Buffer
+	config := replify.NewStreamConfig()
+	config.ChunkSize = 8 * 1024
+	sw := replify.New().WithStreaming(bytes.NewReader(data), config)
+	sw.WithCompressionType("x-xor")
+	sw.WithWriter(&out)
+	if w := sw.Start(context.Background()); w.IsError() {
+		t.Fatalf("Start: %v", w.Error())
+	}
+	if !bytes.Equal(out.Bytes(), invert(data)) {
+		t.Error("stream was not encoded with the registered codec")
+	}
+	if w := replify.New().WithStreaming(bytes.NewReader(data), nil).WithCompressionType("x-missing"); !w.IsError() {
+		t.Error("expected an unregistered compression type to be rejected")
+	}
+
+	// CompressSafe / DecompressSafe.
+	body := map[string]any{"text": strings.Repeat("a", 2048)}
+	w := replify.New().WithBody(body).WithCompressionCodec("x-xor").CompressSafe(16)
+	if w.Debugging()["compression"] != "x-xor" {
+		t.Fatalf("compression = %v, want x-xor", w.Debugging()["compression"])
+	}
+	restored, ok := w.DecompressSafe().Body().(map[string]any)
+	if !ok || restored["text"] != body["text"] {
+		t.Errorf("DecompressSafe() = %v", w.Body())
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"br, deflate", "deflate"},
+		{"gzip;q=0.5, deflate;q=0.8", "deflate"},
+		{"gzip, deflate", "gzip"},
+		{"br;q=1.0, *;q=0.1", "gzip"},
+		{"*, gzip;q=0", "deflate"},
+		{"identity", ""},
+		{"gzip;q=0", ""},
+	}
+	for _, tc := range cases {
+		if got := replify.NegotiateEncoding(tc.accept); got != tc.want {
+			t.Errorf("NegotiateEncoding(%q) = %q, want %q", tc.accept, got, tc.want)
+		}
+	}
+}
+
+func TestServeHTTPContentEncoding(t *testing.T) {
+	t.Parallel()
+
+	w := replify.WrapOk("ok", strings.Repeat("payload ", 256)).WithContentEncoding(64)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip;q=1.0, deflate;q=0.5")
+	rec := httptest.NewRecorder()
+	w.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+		t.Errorf("Vary = %q, want Accept-Encoding", got)
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	decoded, _ := io.ReadAll(zr)
+	if !bytes.Contains(decoded, []byte("payload payload")) {
+		t.Errorf("decoded body = %.80s", decoded)
+	}
+
+	// Clients without Accept-Encoding receive the identity body.
+	rec = httptest.NewRecorder()
+	w.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := rec.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("Content-Encoding = %q, want none", got)
+	}
+	if !strings.Contains(rec.Body.String(), "payload payload") {
+		t.Error("identity body expected")
+	}
+}
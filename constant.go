@@ -230,6 +230,14 @@ const (
 	// 	Example: "bytes 200-1000/5000"
 	HeaderContentRange HeaderType = "Content-Range"
 
+	// Range requests only part of a resource.
+	// 	Example: "bytes=0-1023"
+	HeaderRange HeaderType = "Range"
+
+	// IfRange makes a range request conditional on the entity tag or date of the resource.
+	// 	Example: "\"33a64df551425fcc\""
+	HeaderIfRange HeaderType = "If-Range"
+
 	// Allow specifies the allowed methods for a resource.
 	// 	Example: "GET, HEAD, PUT"
 	HeaderAllow HeaderType = "Allow"
@@ -265,6 +273,46 @@ const (
 	// UpgradeInsecureRequests requests the browser to upgrade any insecure requests to secure HTTPS requests.
 	// 	Example: "1"
 	HeaderUpgradeInsecureRequests HeaderType = "Upgrade-Insecure-Requests"
+
+	// RateLimitLimit advertises the request quota associated with the client in the current window (IETF RateLimit fields draft).
+	// 	Example: "100"
+	HeaderRateLimitLimit HeaderType = "RateLimit-Limit"
+
+	// RateLimitRemaining advertises the remaining quota units in the current window (IETF RateLimit fields draft).
+	// 	Example: "42"
+	HeaderRateLimitRemaining HeaderType = "RateLimit-Remaining"
+
+	// RateLimitReset advertises the number of seconds until the quota resets (IETF RateLimit fields draft).
+	// 	Example: "30"
+	HeaderRateLimitReset HeaderType = "RateLimit-Reset"
+
+	// Deprecation signals that the resource is (or will be) deprecated, expressed as a structured date (RFC 9745).
+	// 	Example: "@1688169599"
+	HeaderDeprecation HeaderType = "Deprecation"
+
+	// Sunset indicates the date after which the resource is expected to become unresponsive (RFC 8594).
+	// 	Example: "Sat, 31 Dec 2025 23:59:59 GMT"
+	HeaderSunset HeaderType = "Sunset"
+
+	// Vary lists the request headers that selected the representation of the response.
+	// 	Example: "Accept-Encoding"
+	HeaderVary HeaderType = "Vary"
+
+	// WebhookID carries the unique identifier of a webhook event; receivers use it to deduplicate.
+	// 	Example: "d0m1u2gbl2v0i8lmcfa0"
+	HeaderWebhookID HeaderType = "Webhook-Id"
+
+	// WebhookEvent carries the type of a webhook event.
+	// 	Example: "order.created"
+	HeaderWebhookEvent HeaderType = "Webhook-Event"
+
+	// WebhookTimestamp carries the Unix time at which a webhook delivery was signed.
+	// 	Example: "1767225600"
+	HeaderWebhookTimestamp HeaderType = "Webhook-Timestamp"
+
+	// WebhookSignature carries the HMAC-SHA256 signature of a webhook delivery.
+	// 	Example: "t=1767225600,v1=5257a869e7ecebeda32affa62cdca3fa51cad7e77a0e56ff536d0ce8e108d8bd"
+	HeaderWebhookSignature HeaderType = "Webhook-Signature"
 )
 
 // Media Type constants define commonly used MIME types for different content types in HTTP requests and responses.
@@ -349,10 +397,18 @@ const (
 	//  Example: "application/gzip"
 	MediaTypeApplicationGzip MediaType = "application/gzip"
 
+	// ApplicationTar specifies that the content is a tar archive.
+	//  Example: "application/x-tar"
+	MediaTypeApplicationTar MediaType = "application/x-tar"
+
 	// MultipartFormData specifies that the content is a multipart form, typically used for file uploads.
 	//  Example: "multipart/form-data; boundary=----WebKitFormBoundary7MA4YWxkTrZu0gW"
 	MediaTypeMultipartFormData MediaType = "multipart/form-data"
 
+	// MultipartByteRanges specifies a 206 Partial Content response carrying several ranges of a resource.
+	//  Example: "multipart/byteranges; boundary=3d6b6a416f9b5"
+	MediaTypeMultipartByteRanges MediaType = "multipart/byteranges"
+
 	// ImageBMP specifies that the content is a BMP image.
 	//  Example: "image/bmp"
 	MediaTypeImageBMP MediaType = "image/bmp"
@@ -369,6 +425,10 @@ const (
 	//  Example: "text/javascript"
 	MediaTypeTextJavaScript MediaType = "text/javascript"
 
+	// TextEventStream specifies a stream of server-sent events.
+	//  Example: "text/event-stream"
+	MediaTypeTextEventStream MediaType = "text/event-stream"
+
 	// ApplicationJSONLD specifies that the content is a JSON-LD (JSON for Linked Data) document.
 	//  Example: "application/ld+json"
 	MediaTypeApplicationJSONLD MediaType = "application/ld+json"
@@ -902,6 +962,10 @@ const (
 	// Chunked streaming with explicit chunk handling
 	// Data is divided into chunks of a specified size and sent sequentially.
 	StrategyChunked StreamingStrategy = "chunked"
+
+	// Chunked streaming with content-defined chunk boundaries (FastCDC)
+	// Chunks average the chunk size and survive insertions and deletions, which suits deduplication.
+	StrategyContentDefined StreamingStrategy = "content_defined"
 )
 
 // CompressionType defines the type of compression applied to data.
@@ -920,6 +984,15 @@ const (
 	CompressFlate CompressionType = "flate"
 )
 
+// Codec settings.
+const (
+	// encodingIdentity is the content coding meaning "no encoding".
+	encodingIdentity string = "identity"
+
+	// defaultCodec is the codec used by [wrapper.CompressSafe] when none is set.
+	defaultCodec string = "gzip"
+)
+
 // Common constants used across the package.
 const (
 	// ErrUnknown is a constant string used to represent an unknown or unspecified value in the context of XC (cross-cutting) concerns.
@@ -929,6 +1002,235 @@ const (
 	// defaultChunkSize defines the maximum number of bytes in each chunk.
 	// defaultChunkSize is used to limit the size of data chunks when processing large responses or requests.
 	defaultChunkSize int = 1024
+
+	// maxErrorTreeDepth bounds the depth of a serialized error tree so that
+	// self-referencing or pathologically deep chains cannot recurse forever.
+	maxErrorTreeDepth int = 32
+)
+
+// Envelope layout versions.
+const (
+	// EnvelopeV1 is the legacy layout, which carried the structured header
+	// under the singular "header" key.
+	EnvelopeV1 string = "1"
+
+	// EnvelopeV2 is the layout rendered by [wrapper.JSON], with the
+	// structured header under the "headers" key.
+	EnvelopeV2 string = "2"
+
+	// EnvelopeVersionCurrent is the layout version produced by this package.
+	EnvelopeVersionCurrent = EnvelopeV2
+
+	// envelopeVersionParam is the media-type parameter of the Accept header
+	// that selects an envelope layout, e.g. "application/json; version=1".
+	envelopeVersionParam string = "version"
+)
+
+// Response policy settings.
+const (
+	// EnvPolicyEnvironment is the environment variable naming the deployment
+	// environment (e.g. "production") when a [PolicyConfig] does not set one.
+	EnvPolicyEnvironment string = "REPLIFY_ENV"
+
+	// defaultAPIVersion is the meta.apiVersion of a new [wrapper]; a policy
+	// APIVersion replaces it.
+	defaultAPIVersion string = "v0.0.1"
+
+	// defaultLocale is the meta.locale of a new [wrapper]; a policy Locale
+	// replaces it.
+	defaultLocale string = "en_US"
+
+	// defaultRedactedValue replaces redacted values when a [ResponsePolicy]
+	// does not set RedactWith.
+	defaultRedactedValue string = "[REDACTED]"
+)
+
+// HealthStatus values.
+const (
+	// HealthUp reports a healthy check or a report whose checks all passed.
+	HealthUp HealthStatus = "up"
+
+	// HealthDegraded reports a report where only non-critical checks failed.
+	HealthDegraded HealthStatus = "degraded"
+
+	// HealthDown reports a failed check or a report with a failed critical check.
+	HealthDown HealthStatus = "down"
+)
+
+// HealthCheckKind values.
+const (
+	// HealthKindLiveness selects the checks telling whether the process must be restarted.
+	HealthKindLiveness HealthCheckKind = "liveness"
+
+	// HealthKindReadiness selects the checks telling whether the process can receive traffic.
+	HealthKindReadiness HealthCheckKind = "readiness"
+)
+
+// ChunkOp values, naming the stage of a failed chunk.
+const (
+	// ChunkOpRead marks a failure reading a chunk from the source.
+	ChunkOpRead ChunkOp = "read"
+
+	// ChunkOpProcess marks a failure transforming, compressing or decompressing a chunk.
+	ChunkOpProcess ChunkOp = "process"
+
+	// ChunkOpWrite marks a failure writing a chunk to the destination.
+	ChunkOpWrite ChunkOp = "write"
+)
+
+// DestinationPolicy values, selecting how a failed destination is handled.
+const (
+	// DestinationFailAll fails the whole stream when the destination fails (default).
+	DestinationFailAll DestinationPolicy = "fail_all"
+
+	// DestinationDrop drops the failed destination and streams on to the others.
+	DestinationDrop DestinationPolicy = "drop"
+)
+
+// ArchiveFormat values.
+const (
+	// ArchiveTar selects a tar archive (POSIX pax format where needed).
+	ArchiveTar ArchiveFormat = "tar"
+
+	// ArchiveTarGzip selects a gzip-compressed tar archive.
+	ArchiveTarGzip ArchiveFormat = "tar.gz"
+
+	// ArchiveZip selects a zip archive with DEFLATE-compressed entries.
+	ArchiveZip ArchiveFormat = "zip"
+)
+
+// Content-defined chunking settings; see [StrategyContentDefined].
+const (
+	// cdcMinAverage is the smallest average chunk size of the content-defined chunker.
+	cdcMinAverage int = 256
+
+	// cdcGearSeed seeds the gear table of the content-defined chunker. Changing
+	// it moves every chunk boundary, which defeats deduplication against chunks
+	// cut before the change.
+	cdcGearSeed uint64 = 0x7265706c69667963
+)
+
+// progressBarWidth is the number of cells of the bar drawn by a [ProgressBar].
+const progressBarWidth int = 30
+
+// bodySeqBufferSize is the size of the buffer through which [wrapper.EncodeTo]
+// writes a streamed body.
+const bodySeqBufferSize int = 32 << 10
+
+// bandwidthRootName names the limiter created by [NewBandwidthLimiter].
+const bandwidthRootName string = "global"
+
+// fanoutWriterName names the destination of the writer set with
+// [StreamingWrapper.WithWriter] in a fan-out stream.
+const fanoutWriterName string = "writer"
+
+// Stream frame format; see [StreamingWrapper.WithFraming].
+const (
+	// frameMagic opens every frame header.
+	frameMagic string = "RPF1"
+
+	// frameHeaderSize is the size of the fixed part of a frame header.
+	frameHeaderSize int = 24
+
+	// frameKindData marks a frame carrying a chunk.
+	frameKindData byte = 0x01
+
+	// frameKindTrailer marks the frame carrying the [StreamTrailer].
+	frameKindTrailer byte = 0x02
+
+	// frameKindSeal marks the frame opening a sealed stream, carrying the key salt and identifier.
+	frameKindSeal byte = 0x03
+
+	// frameKindRef marks a frame standing for a chunk the receiver holds, carrying its SHA-256 digest.
+	frameKindRef byte = 0x04
+
+	// frameFlagCompressed marks a payload compressed with the frame codec.
+	frameFlagCompressed byte = 0x01
+
+	// frameFlagSealed marks a payload sealed with AES-256-GCM.
+	frameFlagSealed byte = 0x02
+
+	// frameTrailerSize is the payload size of a trailer frame.
+	frameTrailerSize int = 12
+
+	// frameMaxPayload bounds the payload size accepted from a frame header.
+	frameMaxPayload int64 = 64 << 20
+)
+
+// Stream sealing settings; see [StreamingWrapper.WithEncryption].
+const (
+	// sealKeySize is the AES-256 key size, of both the provided and the stream keys.
+	sealKeySize int = 32
+
+	// sealSaltSize is the size of the random salt deriving the key of a stream.
+	sealSaltSize int = 16
+
+	// sealInfo is the HKDF context of the stream keys.
+	sealInfo string = "replify stream seal v1"
+)
+
+// Stream manifest settings; see [StreamingWrapper.WithManifest].
+const (
+	// manifestAlgorithm names the chunk digest algorithm of a [StreamManifest].
+	manifestAlgorithm string = "sha256"
+
+	// manifestMetaKey is the wrapper meta field the manifest is attached to.
+	manifestMetaKey string = "manifest"
+
+	// merkleLeafPrefix is prepended to a chunk digest when hashing a Merkle leaf.
+	merkleLeafPrefix byte = 0x00
+
+	// merkleNodePrefix is prepended to two child digests when hashing a Merkle node.
+	merkleNodePrefix byte = 0x01
+)
+
+// Range request settings; see [ServeRange].
+const (
+	// rangeUnit is the only range unit supported.
+	rangeUnit string = "bytes"
+
+	// maxRanges bounds the ranges honored in a single request; longer lists are ignored.
+	maxRanges int = 64
+)
+
+// Webhook outbox layout.
+const (
+	// webhookPendingDir is the outbox subdirectory of events awaiting delivery.
+	webhookPendingDir string = "pending"
+
+	// webhookDeadDir is the outbox subdirectory of dead-lettered events.
+	webhookDeadDir string = "dead"
+
+	// webhookEventExt is the file extension of persisted events.
+	webhookEventExt string = ".json"
+)
+
+// DiffOp values, named after the JSON Patch (RFC 6902) operations.
+const (
+	// DiffAdd marks a value present only in the second envelope.
+	DiffAdd DiffOp = "add"
+
+	// DiffRemove marks a value present only in the first envelope.
+	DiffRemove DiffOp = "remove"
+
+	// DiffReplace marks a value present in both envelopes with different content.
+	DiffReplace DiffOp = "replace"
+)
+
+// Meta custom field keys used to mirror HTTP response metadata (rate limits,
+// retry hints, deprecation notices) into the [meta] section of the envelope.
+const (
+	// metaKeyRateLimit holds the rate-limit quota object (limit, remaining, reset).
+	metaKeyRateLimit string = "rate_limit"
+
+	// metaKeyRetryAfter holds the retry delay in whole seconds.
+	metaKeyRetryAfter string = "retry_after"
+
+	// metaKeyDeprecation holds the deprecation date formatted as RFC 3339.
+	metaKeyDeprecation string = "deprecation"
+
+	// metaKeySunset holds the sunset date formatted as RFC 3339.
+	metaKeySunset string = "sunset"
 )
 
 // Locale defines the language and regional settings for content localization.
@@ -1,11 +1,20 @@
 package replify
 
 import (
+	"bytes"
 	"context"
+	"hash/crc32"
 	"io"
+	"math/bits"
 	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
 	"sync"
 	"time"
+
+	"github.com/sivaosorg/replify/pkg/strutil"
+	"github.com/sivaosorg/replify/pkg/sysx"
 )
 
 // New creates a new instance of the [wrapper] struct.
@@ -162,6 +171,521 @@ func NewStreaming(reader io.Reader, config *StreamConfig) *StreamingWrapper {
 	return sw
 }
 
+// NewTokenBucketLimiter creates a per-key token-bucket [RateLimiter].
+//
+// Every key starts with a full bucket of `limit` tokens that refills at a
+// constant rate of limit/period, so short bursts up to `limit` are admitted
+// while the sustained throughput is capped at `limit` requests per `period`.
+//
+// Parameters:
+//   - limit: The bucket capacity; values below 1 are treated as 1.
+//   - period: The time it takes to refill an empty bucket; defaults to one second if not positive.
+//
+// Returns:
+//   - A pointer to a newly created `TokenBucketLimiter` instance.
+func NewTokenBucketLimiter(limit int, period time.Duration) *TokenBucketLimiter {
+	if limit < 1 {
+		limit = 1
+	}
+	if period <= 0 {
+		period = time.Second
+	}
+	return &TokenBucketLimiter{
+		limit:   limit,
+		period:  period,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// NewSlidingWindowLimiter creates a per-key sliding-window [RateLimiter].
+//
+// At most `limit` requests are admitted for a key within any rolling `window`.
+// Unlike the token bucket, the sliding window does not allow a burst to be
+// followed immediately by another full burst at a window boundary.
+//
+// Parameters:
+//   - limit: The maximum number of requests per window; values below 1 are treated as 1.
+//   - window: The rolling window length; defaults to one second if not positive.
+//
+// Returns:
+//   - A pointer to a newly created `SlidingWindowLimiter` instance.
+func NewSlidingWindowLimiter(limit int, window time.Duration) *SlidingWindowLimiter {
+	if limit < 1 {
+		limit = 1
+	}
+	if window <= 0 {
+		window = time.Second
+	}
+	return &SlidingWindowLimiter{
+		limit:   limit,
+		window:  window,
+		windows: make(map[string]*slidingWindow),
+	}
+}
+
+// NewStackOptions creates the default stack capture options.
+//
+// The defaults keep up to 32 frames, drop Go runtime and testing frames,
+// trim GOROOT/GOPATH prefixes, fold repeated frames and disable source snippets.
+//
+// Returns:
+//   - A pointer to a newly created `StackOptions` instance with default settings.
+func NewStackOptions() *StackOptions {
+	return &StackOptions{
+		Depth:           32,
+		Exclude:         []string{"runtime", "testing"},
+		TrimGOPATH:      true,
+		CollapseRepeats: true,
+		SourceLines:     0,
+	}
+}
+
+// NewPolicySet creates a [PolicySet] for the given deployment environment.
+//
+// Parameters:
+//   - `environment`: The deployment environment; when empty, the value of the
+//     REPLIFY_ENV environment variable is used.
+//   - `policies`: The policies, evaluated in order.
+//
+// Returns:
+//   - A pointer to a new [PolicySet].
+//
+// Example:
+//
+//	set := replify.NewPolicySet("production", replify.ResponsePolicy{
+//	    Route:  "/api/*",
+//	    Redact: []string{"password", "token"},
+//	})
+func NewPolicySet(environment string, policies ...ResponsePolicy) *PolicySet {
+	if strutil.IsEmpty(environment) {
+		environment = sysx.Getenv(EnvPolicyEnvironment, "")
+	}
+	return &PolicySet{
+		environment: environment,
+		policies:    append([]ResponsePolicy(nil), policies...),
+	}
+}
+
+// NewHealthRegistry creates an empty [HealthRegistry].
+//
+// Checks that set no timeout are bounded by 5 seconds.
+//
+// Returns:
+//   - A pointer to a newly created `HealthRegistry` instance.
+func NewHealthRegistry() *HealthRegistry {
+	return &HealthRegistry{defaultTimeout: 5 * time.Second}
+}
+
+// NewBackoff creates the default [Backoff]: 500ms doubling up to one minute
+// with 20% jitter.
+//
+// Returns:
+//   - A `Backoff` value with default settings.
+func NewBackoff() Backoff {
+	return Backoff{
+		Initial:    500 * time.Millisecond,
+		Max:        time.Minute,
+		Multiplier: 2,
+		Jitter:     0.2,
+	}
+}
+
+// NewWebhookDispatcher creates a [WebhookDispatcher], restoring the events
+// persisted in the outbox directory, if any.
+//
+// Parameters:
+//   - `config`: The dispatcher settings; zero values take the documented defaults.
+//
+// Returns:
+//   - A pointer to a newly created `WebhookDispatcher` instance.
+//   - An error if the outbox cannot be read.
+func NewWebhookDispatcher(config WebhookConfig) (*WebhookDispatcher, error) {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 5
+	}
+	if config.Backoff == (Backoff{}) {
+		config.Backoff = NewBackoff()
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	client := config.Client
+	if client == nil {
+		client = &http.Client{Timeout: config.Timeout}
+	}
+	d := &WebhookDispatcher{
+		config:   config,
+		client:   client,
+		pending:  make(map[string]*WebhookEvent),
+		dead:     make(map[string]*WebhookEvent),
+		inflight: make(map[string]struct{}),
+		now:      time.Now,
+	}
+	if err := d.restore(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// NewChunkRetryPolicy creates the default [ChunkRetryPolicy]: 3 attempts
+// per chunk, waiting 100ms doubling up to 5 seconds with 20% jitter.
+//
+// Returns:
+//   - A pointer to a newly created `ChunkRetryPolicy` instance.
+func NewChunkRetryPolicy() *ChunkRetryPolicy {
+	return &ChunkRetryPolicy{
+		MaxAttempts: 3,
+		Backoff: Backoff{
+			Initial:    100 * time.Millisecond,
+			Max:        5 * time.Second,
+			Multiplier: 2,
+			Jitter:     0.2,
+		},
+	}
+}
+
+// newEnvelopeRegistry creates an envelope registry preloaded with the
+// built-in migration between [EnvelopeV1] and [EnvelopeV2] and the detector
+// recognizing legacy [EnvelopeV1] documents.
+func newEnvelopeRegistry() *envelopeRegistry {
+	r := &envelopeRegistry{}
+	r.migrations = append(r.migrations, EnvelopeMigration{
+		From: EnvelopeV1,
+		To:   EnvelopeV2,
+		Up:   renameEnvelopeKey("header", "headers"),
+		Down: renameEnvelopeKey("headers", "header"),
+	})
+	r.detectors = append(r.detectors, detectEnvelopeV1)
+	return r
+}
+
+// newCodecRegistry creates a codec registry preloaded with the built-in
+// "gzip", "deflate" (zlib) and "flate" (raw DEFLATE) codecs.
+func newCodecRegistry() *codecRegistry {
+	return &codecRegistry{
+		entries: []codecEntry{
+			{codec: gzipCodec{}},
+			{codec: zlibCodec{}},
+			{codec: flateCodec{}},
+		},
+	}
+}
+
+// newBandwidthMeter creates a [bandwidthMeter] measuring 250ms windows
+// from the given start time.
+func newBandwidthMeter(start time.Time) *bandwidthMeter {
+	return &bandwidthMeter{window: 250 * time.Millisecond, start: start}
+}
+
+// NewStaticKeyProvider creates a [KeyProvider] holding a single AES-256 key.
+//
+// Parameters:
+//   - `id`: The key identifier recorded in sealed streams.
+//   - `key`: The 32-byte key; it is copied.
+//
+// Returns:
+//   - A key provider sealing with, and opening only, the given key.
+//
+// Example:
+//
+//	keys := replify.NewStaticKeyProvider("2026-10", key)
+//	streaming.WithEncryption(keys)
+func NewStaticKeyProvider(id string, key []byte) KeyProvider {
+	return &staticKeyProvider{id: id, key: bytes.Clone(key)}
+}
+
+// newStreamFramer creates an empty [streamFramer].
+func newStreamFramer() *streamFramer {
+	return &streamFramer{digest: crc32.NewIEEE()}
+}
+
+// newManifestBuilder creates an empty [manifestBuilder] whose first chunk
+// starts at the given offset.
+func newManifestBuilder(first, base int64) *manifestBuilder {
+	return &manifestBuilder{first: first, base: base, chunks: make(map[int64]ManifestChunk)}
+}
+
+// NewArchiveFileEntry creates an [ArchiveEntry] of a regular file, opened
+// when the archive reaches it.
+//
+// Parameters:
+//   - `name`: The path of the entry in the archive; empty selects the base name of the file.
+//   - `path`: The path of the file.
+//
+// Returns:
+//   - The entry, with the size, mode and modification time of the file.
+//   - An error if the file cannot be stat-ed or is not a regular file.
+func NewArchiveFileEntry(name, path string) (ArchiveEntry, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ArchiveEntry{}, err
+	}
+	if !info.Mode().IsRegular() {
+		return ArchiveEntry{}, NewErrorf("archive entry %s: not a regular file", path)
+	}
+	if strutil.IsEmpty(name) {
+		name = filepath.Base(path)
+	}
+	return ArchiveEntry{
+		Name:    name,
+		Size:    info.Size(),
+		Mode:    info.Mode().Perm(),
+		ModTime: info.ModTime(),
+		Open:    func() (io.ReadCloser, error) { return os.Open(path) },
+	}, nil
+}
+
+// NewArchiveResourceEntry creates an [ArchiveEntry] of a [sysx.Resource],
+// read from its start. The resource is not closed by the archive.
+//
+// Parameters:
+//   - `name`: The path of the entry in the archive; empty selects the name of the resource.
+//   - `r`: The resource.
+//
+// Returns:
+//   - The entry, with the size of the resource.
+func NewArchiveResourceEntry(name string, r *sysx.Resource) ArchiveEntry {
+	if strutil.IsEmpty(name) {
+		name = r.Name()
+	}
+	return ArchiveEntry{
+		Name: name,
+		Size: r.Size(),
+		Open: func() (io.ReadCloser, error) {
+			if r.Content() == nil {
+				return nil, sysx.ErrNilResource
+			}
+			if err := r.Rewind(); err != nil {
+				return nil, err
+			}
+			return io.NopCloser(r.Content()), nil
+		},
+	}
+}
+
+// NewArchiveDumpEntry creates an [ArchiveEntry] of a [Dump], read through
+// its own handle like [Dump.ServeHTTP] does. The Dump is not closed by the
+// archive.
+//
+// Parameters:
+//   - `name`: The path of the entry in the archive; empty selects the name of the dump.
+//   - `d`: The dump.
+//
+// Returns:
+//   - The entry, with the size of the dump.
+func NewArchiveDumpEntry(name string, d *Dump) ArchiveEntry {
+	if strutil.IsEmpty(name) {
+		name = d.Resource().Name()
+	}
+	return ArchiveEntry{
+		Name: name,
+		Size: d.Size(),
+		Open: func() (io.ReadCloser, error) {
+			if d == nil || d.syr == nil || d.syr.Content() == nil {
+				return nil, sysx.ErrNilResource
+			}
+			content, release, err := d.openContent()
+			if err != nil {
+				return nil, err
+			}
+			return &releaseReader{Reader: content, release: release}, nil
+		},
+	}
+}
+
+// NewArchiveReaderEntry creates an [ArchiveEntry] of a reader, which can
+// be read once. A reader that is an io.ReadCloser is closed by the archive.
+//
+// Parameters:
+//   - `name`: The path of the entry in the archive.
+//   - `r`: The reader.
+//   - `size`: The number of bytes of the reader, -1 if unknown; tar archives need it.
+//
+// Returns:
+//   - The entry.
+func NewArchiveReaderEntry(name string, r io.Reader, size int64) ArchiveEntry {
+	return ArchiveEntry{
+		Name: name,
+		Size: size,
+		Open: func() (io.ReadCloser, error) {
+			if rc, ok := r.(io.ReadCloser); ok {
+				return rc, nil
+			}
+			return io.NopCloser(r), nil
+		},
+	}
+}
+
+// newArchiveSource creates the [archiveSource] of the given entries.
+func newArchiveSource(format ArchiveFormat, entries []ArchiveEntry) *archiveSource {
+	a := &archiveSource{format: format, entries: slices.Clone(entries)}
+	for _, e := range entries {
+		if e.Size < 0 || a.total < 0 {
+			a.total = -1
+			continue
+		}
+		a.total += e.Size
+	}
+	a.pr, a.pw = io.Pipe()
+	return a
+}
+
+// NewCDCChunker creates a [CDCChunker] reading r.
+//
+// Parameters:
+//   - `r`: The source.
+//   - `avgSize`: The average chunk size, rounded down to a power of two; at least 256 bytes.
+//
+// Returns:
+//   - A pointer to a newly created `CDCChunker` instance.
+//
+// Example:
+//
+//	chunker := replify.NewCDCChunker(file, 64*1024)
+//	for {
+//	    chunk, err := chunker.Next()
+//	    if err == io.EOF {
+//	        break
+//	    }
+//	    ...
+//	}
+func NewCDCChunker(r io.Reader, avgSize int) *CDCChunker {
+	shift := bits.Len(uint(max(avgSize, cdcMinAverage))) - 1
+	avg := 1 << shift
+	return &CDCChunker{
+		r:     r,
+		min:   avg / 4,
+		avg:   avg,
+		max:   avg * 4,
+		maskS: ^uint64(0) << (64 - (shift + 2)),
+		maskL: ^uint64(0) << (64 - (shift - 2)),
+		buf:   make([]byte, avg*4),
+	}
+}
+
+// NewChunkIndex creates an in-memory [ChunkIndex] holding the given digests.
+//
+// Parameters:
+//   - `digests`: The hex-encoded SHA-256 digests of the chunks the receiver holds.
+//
+// Returns:
+//   - The index.
+func NewChunkIndex(digests ...string) ChunkIndex {
+	idx := &chunkIndexSet{digests: make(map[string]struct{}, len(digests))}
+	for _, d := range digests {
+		idx.digests[d] = struct{}{}
+	}
+	return idx
+}
+
+// NewChunkIndexFromManifest creates an in-memory [ChunkIndex] of the chunks
+// of a manifest, e.g. the manifest of the previous transfer of a file,
+// built by a receiver with [StrategyContentDefined] and no transforms.
+//
+// Parameters:
+//   - `m`: The manifest; nil yields an empty index.
+//
+// Returns:
+//   - The index.
+func NewChunkIndexFromManifest(m *StreamManifest) ChunkIndex {
+	if m == nil {
+		return NewChunkIndex()
+	}
+	digests := make([]string, 0, len(m.Chunks))
+	for _, c := range m.Chunks {
+		digests = append(digests, c.SHA256)
+	}
+	return NewChunkIndex(digests...)
+}
+
+// NewMemoryChunkStore creates an empty [MemoryChunkStore].
+//
+// Returns:
+//   - A pointer to a newly created `MemoryChunkStore` instance.
+func NewMemoryChunkStore() *MemoryChunkStore {
+	return &MemoryChunkStore{chunks: make(map[string][]byte)}
+}
+
+// NewProgressBar creates a [ProgressBar] writing to out.
+//
+// On a terminal (see [sysx.IsTTY]), the bar is redrawn in place up to ten
+// times a second. Elsewhere, e.g. in a CI log, a plain line is written at
+// most every five seconds.
+//
+// Parameters:
+//   - `out`: The destination, e.g. os.Stderr; nil selects os.Stderr.
+//   - `label`: The label printed before the bar, e.g. the file name.
+//
+// Returns:
+//   - A pointer to a newly created `ProgressBar` instance.
+func NewProgressBar(out io.Writer, label string) *ProgressBar {
+	if out == nil {
+		out = os.Stderr
+	}
+	b := &ProgressBar{out: out, label: label, tty: sysx.IsTTY(out), interval: 5 * time.Second}
+	if b.tty {
+		b.interval = 100 * time.Millisecond
+	}
+	return b
+}
+
+// NewStreamRegistry creates an empty [StreamRegistry], which sends a
+// progress event every 500 milliseconds.
+//
+// Returns:
+//   - A pointer to a newly created `StreamRegistry` instance.
+func NewStreamRegistry() *StreamRegistry {
+	return &StreamRegistry{streams: make(map[string]*registeredStream), interval: 500 * time.Millisecond}
+}
+
+// NewBandwidthLimiter creates a root [BandwidthLimiter], e.g. the limit of
+// a network interface shared by all the streams of a process.
+//
+// Parameters:
+//   - `rate`: The rate in bytes per second; 0 or less means unlimited.
+//   - `burst`: The bucket capacity in bytes; 0 or less selects one second of rate.
+//
+// Returns:
+//   - A pointer to a newly created `BandwidthLimiter` instance, starting with a full bucket.
+//
+// Example:
+//
+//	nic := replify.NewBandwidthLimiter(100<<20, 0)
+//	acme := nic.NewChild("acme", 20<<20, 0, 2)
+func NewBandwidthLimiter(rate, burst int64) *BandwidthLimiter {
+	return newBandwidthLimiter(bandwidthRootName, nil, rate, burst, 1)
+}
+
+// newBandwidthLimiter creates a [BandwidthLimiter] with a full bucket.
+func newBandwidthLimiter(name string, parent *BandwidthLimiter, rate, burst int64, weight int) *BandwidthLimiter {
+	l := &BandwidthLimiter{
+		name:   name,
+		parent: parent,
+		rate:   max(rate, 0),
+		burst:  max(burst, 0),
+		weight: max(weight, 1),
+		last:   time.Now(),
+		finish: make(map[any]float64),
+	}
+	l.tokens = float64(l.capacity())
+	return l
+}
+
+// newStreamFanout creates the [streamFanout] of a run writing to the given
+// destinations, which replaces writer for the run.
+func newStreamFanout(sw *StreamingWrapper, ctx context.Context, cancel context.CancelCauseFunc, writer io.Writer, dests []StreamDestination) *streamFanout {
+	f := &streamFanout{sw: sw, ctx: ctx, cancel: cancel, writer: writer}
+	start := time.Now()
+	for _, dest := range dests {
+		f.targets = append(f.targets, &fanoutTarget{
+			dest:  dest,
+			start: start,
+			stats: DestinationStats{Name: dest.Name, Policy: dest.Policy},
+		})
+	}
+	return f
+}
+
 // defaultMetaValues returns the default values for the [meta] struct.
 //
 // This function creates a new [meta] instance with the default values,
@@ -171,8 +695,8 @@ func NewStreaming(reader io.Reader, config *StreamConfig) *StreamingWrapper {
 //   - A pointer to a newly created [meta] instance with the default values.
 func defaultMetaValues() *meta {
 	return Meta().
-		WithLocale("en_US"). // vi_VN, en_US
-		WithApiVersion("v0.0.1").
+		WithLocale(defaultLocale). // vi_VN, en_US
+		WithApiVersion(defaultAPIVersion).
 		WithRequestedTime(time.Now()).
 		WithDeltaValue(0).
 		autoRequestID()
@@ -0,0 +1,234 @@
+package replify
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sivaosorg/replify/pkg/encoding"
+	"github.com/sivaosorg/replify/pkg/match"
+)
+
+// Diff computes the structural differences between two [wrapper] instances.
+//
+// Both envelopes are compared in their serialized form (the same shape as
+// [wrapper.JSON]), so differences are reported across status, message,
+// headers, meta, pagination, debug and deep into `data`. Objects are
+// compared key by key and arrays index by index. Each difference carries the
+// JSON Pointer of the value, the operation and the old/new values.
+//
+// Parameters:
+//   - `a`: The reference envelope (e.g. the response of the current version).
+//   - `b`: The envelope to compare (e.g. the response of the candidate version).
+//   - `opts`: Optional settings such as [DiffIgnore] and [DiffIgnoreVolatile].
+//
+// Returns:
+//   - The ordered [Differences]; empty when the envelopes are equivalent.
+//
+// Example:
+//
+//	diffs := replify.Diff(v1Response, v2Response, replify.DiffIgnoreVolatile())
+//	if !diffs.IsEmpty() {
+//	    fmt.Println(diffs.String())
+//	    // ~ /data/user/email: "a@x.io" -> "a@y.io"
+//	    // + /meta/custom_fields/region: "eu"
+//	}
+func Diff(a, b *wrapper, opts ...DiffOption) Differences {
+	o := &diffOptions{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+	var diffs Differences
+	o.walk(&diffs, "", envelopeTree(a), envelopeTree(b))
+	return diffs
+}
+
+// DiffIgnore returns a [DiffOption] that excludes paths from the diff.
+// A path is either a JSON Pointer prefix ("/debug" ignores the whole debug
+// section) or a wildcard pattern ("/data/items/*/updated_at").
+//
+// Parameters:
+//   - `paths`: The JSON Pointers or patterns to ignore.
+//
+// Returns:
+//   - A [DiffOption].
+func DiffIgnore(paths ...string) DiffOption {
+	return func(o *diffOptions) {
+		o.ignore = append(o.ignore, paths...)
+	}
+}
+
+// DiffIgnoreVolatile returns a [DiffOption] that ignores the fields that
+// differ on every response: the request ID and the requested time in meta.
+//
+// Returns:
+//   - A [DiffOption].
+func DiffIgnoreVolatile() DiffOption {
+	return DiffIgnore("/meta/request_id", "/meta/requested_time")
+}
+
+// IsEmpty reports whether no difference was found.
+func (d Differences) IsEmpty() bool {
+	return len(d) == 0
+}
+
+// String renders the differences one per line: "+" for additions, "-" for
+// removals and "~" for replacements, followed by the path and the values.
+func (d Differences) String() string {
+	var sb strings.Builder
+	for i, e := range d {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		switch e.Op {
+		case DiffAdd:
+			fmt.Fprintf(&sb, "+ %s: %s", e.Path, diffValue(e.To))
+		case DiffRemove:
+			fmt.Fprintf(&sb, "- %s: %s", e.Path, diffValue(e.From))
+		default:
+			fmt.Fprintf(&sb, "~ %s: %s -> %s", e.Path, diffValue(e.From), diffValue(e.To))
+		}
+	}
+	return sb.String()
+}
+
+// JSONPatch renders the differences as a JSON Patch (RFC 6902) document
+// that transforms the first envelope into the second.
+//
+// Returns:
+//   - The patch operations, ready to be marshaled.
+func (d Differences) JSONPatch() []map[string]any {
+	patch := make([]map[string]any, 0, len(d))
+	for _, e := range d {
+		op := map[string]any{"op": string(e.Op), "path": e.Path}
+		if e.Op != DiffRemove {
+			op["value"] = e.To
+		}
+		patch = append(patch, op)
+	}
+	return patch
+}
+
+// JSONPatchString renders the differences as a JSON Patch (RFC 6902) string.
+func (d Differences) JSONPatchString() string {
+	return encoding.JSON(d.JSONPatch())
+}
+
+// walk appends the differences between x and y found at path.
+func (o *diffOptions) walk(diffs *Differences, path string, x, y any) {
+	if o.ignored(path) {
+		return
+	}
+	switch xv := x.(type) {
+	case map[string]any:
+		if yv, ok := y.(map[string]any); ok {
+			o.walkObject(diffs, path, xv, yv)
+			return
+		}
+	case []any:
+		if yv, ok := y.([]any); ok {
+			o.walkArray(diffs, path, xv, yv)
+			return
+		}
+	}
+	if !reflect.DeepEqual(x, y) {
+		*diffs = append(*diffs, DiffEntry{Op: DiffReplace, Path: path, From: x, To: y})
+	}
+}
+
+// walkObject compares two objects key by key, in sorted key order.
+func (o *diffOptions) walkObject(diffs *Differences, path string, x, y map[string]any) {
+	keys := make([]string, 0, len(x)+len(y))
+	for k := range x {
+		keys = append(keys, k)
+	}
+	for k := range y {
+		if _, ok := x[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		child := path + "/" + escapePointer(k)
+		xv, inX := x[k]
+		yv, inY := y[k]
+		switch {
+		case inX && inY:
+			o.walk(diffs, child, xv, yv)
+		case inX:
+			o.record(diffs, DiffEntry{Op: DiffRemove, Path: child, From: xv})
+		default:
+			o.record(diffs, DiffEntry{Op: DiffAdd, Path: child, To: yv})
+		}
+	}
+}
+
+// walkArray compares two arrays index by index. Trailing removals are
+// emitted from the highest index down so the JSON Patch applies in order.
+func (o *diffOptions) walkArray(diffs *Differences, path string, x, y []any) {
+	common := min(len(x), len(y))
+	for i := 0; i < common; i++ {
+		o.walk(diffs, path+"/"+strconv.Itoa(i), x[i], y[i])
+	}
+	for i := common; i < len(y); i++ {
+		o.record(diffs, DiffEntry{Op: DiffAdd, Path: path + "/" + strconv.Itoa(i), To: y[i]})
+	}
+	for i := len(x) - 1; i >= common; i-- {
+		o.record(diffs, DiffEntry{Op: DiffRemove, Path: path + "/" + strconv.Itoa(i), From: x[i]})
+	}
+}
+
+// record appends e unless its path is ignored.
+func (o *diffOptions) record(diffs *Differences, e DiffEntry) {
+	if !o.ignored(e.Path) {
+		*diffs = append(*diffs, e)
+	}
+}
+
+// ignored reports whether path matches one of the ignore rules.
+func (o *diffOptions) ignored(path string) bool {
+	for _, rule := range o.ignore {
+		if strings.ContainsAny(rule, "*?") {
+			if match.Match(path, rule) {
+				return true
+			}
+			continue
+		}
+		if path == rule || strings.HasPrefix(path, rule+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// envelopeTree returns the generic JSON form (maps, slices, float64...) of
+// the envelope, or nil if the [wrapper] is unavailable.
+func envelopeTree(w *wrapper) any {
+	if !w.Available() {
+		return nil
+	}
+	var tree any
+	if err := json.Unmarshal([]byte(w.JSON()), &tree); err != nil {
+		return nil
+	}
+	return tree
+}
+
+// escapePointer escapes a key for use as a JSON Pointer reference token.
+func escapePointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+// diffValue renders a value as compact JSON for the human-readable output.
+func diffValue(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
@@ -0,0 +1,37 @@
+package replify_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sivaosorg/replify"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	a := replify.WrapOk("users", map[string]any{"items": []any{1, 2, 3}, "owner": "alice"}).
+		WithApiVersion("v1").
+		WithDebuggingKV("trace", "x")
+	b := replify.WrapOk("users", map[string]any{"items": []any{1, 5}, "owner/name": "bob"}).
+		WithApiVersion("v2")
+
+	diffs := replify.Diff(a, b, replify.DiffIgnoreVolatile(), replify.DiffIgnore("/debug"))
+	got := diffs.String()
+	want := strings.Join([]string{
+		`~ /data/items/1: 2 -> 5`,
+		`- /data/items/2: 3`,
+		`- /data/owner: "alice"`,
+		`+ /data/owner~1name: "bob"`,
+		`~ /meta/api_version: "v1" -> "v2"`,
+	}, "\n")
+	if got != want {
+		t.Fatalf("unexpected diff:\n%s\nwant:\n%s", got, want)
+	}
+	if patch := diffs.JSONPatchString(); !strings.Contains(patch, `{"op":"remove","path":"/data/items/2"}`) {
+		t.Errorf("unexpected JSON Patch: %s", patch)
+	}
+	if !replify.Diff(a, a.Clone(), replify.DiffIgnoreVolatile()).IsEmpty() {
+		t.Error("a clone must not differ from its source")
+	}
+}
@@ -117,6 +117,16 @@
 //	fmt.Println(w.Pagination().TotalPages()) // 25
 //	fmt.Println(w.Pagination().IsLast())     // false
 //
+// A body too large to hold in memory can be set from an iterator or a
+// channel with WithBodySeq, BodySeq and BodyChan. EncodeTo, WriteHTTP and
+// Dump then write the `data` array as it is produced, and compute `total`
+// and the pagination totals at the end:
+//
+//	replify.WrapOk("Orders", nil).
+//	    WithPagination(replify.Pages().WithPerPage(1000)).
+//	    WithBodySeq(replify.BodySeq(orders.All(ctx))).
+//	    WriteHTTP(rw)
+//
 // # Metadata
 //
 // Attach API metadata to any response:
@@ -215,8 +225,56 @@
 //
 //	replify.CompressNone     // no compression (default)
 //	replify.CompressGzip     // gzip
-//	replify.CompressDeflate  // deflate
-//	replify.CompressFlate    // flate
+//	replify.CompressDeflate  // deflate (zlib)
+//	replify.CompressFlate    // flate (raw DEFLATE)
+//
+// Further codecs can be added with RegisterCodec; a registered codec is
+// usable as a streaming compression type, by CompressSafe through
+// WithCompressionCodec, and for Accept-Encoding negotiation in ServeHTTP
+// when WithContentEncoding is set.
+//
+// With WithFraming, the chunked strategy writes each chunk as a
+// self-describing frame (sequence number, size, codec and CRC-32) and closes
+// the stream with a trailer, which lets a framed receiver detect corrupt,
+// reordered and truncated streams. WithEncryption additionally seals every
+// frame with AES-256-GCM, using keys from a KeyProvider.
+//
+// With WithManifest, each run also builds a StreamManifest of SHA-256 chunk
+// digests and their Merkle root, attached to the wrapper meta; VerifyManifest
+// checks a received file against it and names the corrupt chunks.
+//
+// StreamingWrapper.ServeRange serves a seekable source to an HTTP client
+// with Range support: 206 Partial Content for one range, multipart/byteranges
+// for several, and If-Range validation against the wrapper ETag. A Dump is an
+// http.Handler with the same behavior; ServeRange does it for any
+// io.ReadSeeker.
+//
+// WithDestination fans a stream out to several named writers at once, each
+// with its own throttle rate and failure policy: DestinationFailAll stops
+// the stream, DestinationDrop drops the destination and goes on. Each
+// destination has its statistics in StreamingStats.Destinations.
+//
+// Streams attached to a shared BandwidthLimiter with WithBandwidthLimiter
+// share its rate in proportion to their weights. Limiters nest, e.g. a
+// global limit with a child per tenant made by NewChild, and SetRate
+// changes a rate while streams run.
+//
+// WithArchiveStreaming streams a tar, tar.gz or zip archive built on the fly
+// from ArchiveEntry values: files, sysx.Resource values, Dumps and readers,
+// with the progress across the archive in StreamProgress.Archive.
+//
+// StrategyContentDefined cuts chunks where a rolling hash of the content
+// matches (FastCDC), so that an edit only changes the chunks around it.
+// With WithChunkIndex, the sender sends the chunks the receiver already
+// holds as references to their SHA-256 digests, which the receiver resolves
+// from the ChunkStore set with WithChunkStore; StreamingStats.DedupBytes
+// counts the bytes saved.
+//
+// Ready-made progress sinks plug into WithCallback: ProgressBar draws a bar
+// with rate and ETA, redrawn in place on a terminal, and NewProgressLogger
+// logs the progress to a slogger at most once per interval. A
+// StreamRegistry tracks running streams by stream ID and, as an
+// http.Handler, serves their progress to browsers as server-sent events.
 //
 // # Error Handling
 //
@@ -1,6 +1,9 @@
 package replify
 
 import (
+	"bytes"
+	"io"
+	"net/http"
 	"os"
 
 	"github.com/sivaosorg/replify/pkg/strutil"
@@ -111,6 +114,59 @@ func (d *Dump) Close() error {
 	return d.closeErr
 }
 
+// ETag returns the entity tag of the [wrapper] the Dump was taken from (see
+// [wrapper.ETag]). Returns an empty string when the Dump is nil.
+func (d *Dump) ETag() string {
+	if d == nil {
+		return ""
+	}
+	return d.etag
+}
+
+// ServeHTTP implements [http.Handler], serving the dumped payload with
+// support for Range requests, single and multipart/byteranges, and If-Range
+// validation against [Dump.ETag] (see [ServeRange]).
+//
+// Each request reads through its own handle on the backing temp file, so
+// large downloads are served concurrently; a payload held in memory is
+// shared, with its reads serialized. Must not be called after [Dump.Close].
+//
+// Example:
+//
+//	d, _ := replify.WrapOk("export", rows).Dump()
+//	defer d.Close()
+//	mux.Handle("/exports/latest", d)
+func (d *Dump) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if d == nil || d.syr == nil || d.syr.Content() == nil {
+		http.Error(rw, "dump is not available", http.StatusNotFound)
+		return
+	}
+	content, release, err := d.openContent()
+	if err != nil {
+		http.Error(rw, "dump is not readable", http.StatusInternalServerError)
+		return
+	}
+	defer release()
+	_ = ServeRange(rw, r, content, d.Size(), d.syr.ContentType(), d.etag)
+}
+
+// openContent returns a reader of the payload for one request, and the
+// function releasing it.
+func (d *Dump) openContent() (io.ReadSeeker, func(), error) {
+	if p := d.syr.ActualPath(); strutil.IsNotEmpty(p) {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, nil, err
+		}
+		return f, func() { f.Close() }, nil
+	}
+	if blob, ok := d.syr.Content().(*sysx.MemBlob); ok {
+		return bytes.NewReader(blob.Bytes()), func() {}, nil
+	}
+	d.mu.Lock()
+	return d.syr.Content(), d.mu.Unlock, nil
+}
+
 // resolvePath returns the on-disk path for this Dump:
 //   - permanent destination for Dumps produced by [wrapper.DumpTo] or
 //     [wrapper.DumpBodyTo] (d.filepath).
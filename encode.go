@@ -0,0 +1,281 @@
+package replify
+
+import (
+	"bufio"
+	"io"
+	"iter"
+
+	"github.com/sivaosorg/replify/pkg/encoding"
+)
+
+// BodySeq adapts a typed iterator to a body for [wrapper.WithBodySeq].
+//
+// Parameters:
+//   - `seq`: The elements of the body, in order.
+//
+// Returns:
+//   - The iterator of the elements as `any` values.
+//
+// Example:
+//
+//	w := replify.WrapOk("export", nil).WithBodySeq(replify.BodySeq(store.Rows(ctx)))
+func BodySeq[T any](seq iter.Seq[T]) iter.Seq[any] {
+	return func(yield func(any) bool) {
+		for v := range seq {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// BodyChan adapts a channel to a body for [wrapper.WithBodySeq]. The body
+// ends when the channel is closed; the producer should stop when the
+// response is abandoned, e.g. on the cancellation of the request context,
+// since an encoding that fails stops receiving.
+//
+// Parameters:
+//   - `ch`: The elements of the body, in order.
+//
+// Returns:
+//   - The iterator of the elements received from the channel.
+//
+// Example:
+//
+//	rows := make(chan Row)
+//	go produce(r.Context(), rows) // closes rows when done
+//	replify.WrapOk("export", nil).WithBodySeq(replify.BodyChan(rows)).WriteHTTP(rw)
+func BodyChan[T any](ch <-chan T) iter.Seq[any] {
+	return func(yield func(any) bool) {
+		for v := range ch {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// WithBodySeq sets a body produced element by element, encoded as the
+// `data` array without ever being held in memory.
+//
+// [wrapper.EncodeTo] writes the envelope incrementally, and so do
+// [wrapper.WriteHTTP], [wrapper.ServeHTTP] and [wrapper.Dump] when such a
+// body is set. The iterator is consumed by the encoding, so a body from a
+// channel or a database cursor can only be encoded once. The methods
+// building the envelope in memory, such as [wrapper.JSON] and
+// [wrapper.Respond], do not consume it and render no `data`. Over HTTP,
+// the negotiated Content-Encoding and the response policies apply to the
+// streamed envelope, but a layout version other than
+// [EnvelopeVersionCurrent] is answered with 406 Not Acceptable.
+//
+// Parameters:
+//   - `seq`: The elements of the body, e.g. from [BodySeq] or [BodyChan]; nil clears it.
+//
+// Returns:
+//   - A pointer to the modified [wrapper] instance (enabling method chaining).
+//
+// Example:
+//
+//	w := replify.WrapOk("Orders", nil).
+//	    WithPagination(replify.Pages().WithPage(1).WithPerPage(1000)).
+//	    WithBodySeq(replify.BodySeq(orders.All(ctx)))
+//	w.WriteHTTP(rw)
+func (w *wrapper) WithBodySeq(seq iter.Seq[any]) *wrapper {
+	if !w.Available() {
+		return w
+	}
+	w.bodySeq = seq
+	if seq != nil {
+		w.data = nil
+	}
+	return w
+}
+
+// IsBodySeqPresent checks whether a body produced element by element is set
+// with [wrapper.WithBodySeq].
+//
+// Returns:
+//   - `true` if the body is streamed, `false` otherwise.
+func (w *wrapper) IsBodySeqPresent() bool {
+	return w.Available() && w.bodySeq != nil
+}
+
+// EncodeTo writes the JSON envelope of the [wrapper] to dst.
+//
+// With a body set by [wrapper.WithBodySeq], the envelope is written as the
+// elements are produced: `status_code`, `path`, `message`, `headers` and
+// `meta` first, then the `data` array, element by element, and last the
+// sections computed from it: `total`, the number of elements, and
+// `pagination`, whose total items, when unset, is the number of elements
+// and whose total pages and last page flag follow from it; `debug` and
+// `errors` close the envelope. The totals are also kept in the [wrapper].
+// With [wrapper.WithCanonicalJSON], `data` comes first and the other
+// sections follow in sorted order, so the output matches the canonical
+// form of [wrapper.JSON]. Elements are encoded like a body set with
+// [wrapper.WithBody], with the fields redacted by a [ResponsePolicy]
+// replaced. An element that cannot be encoded or redacted, or a failed
+// write, stops the encoding and leaves the envelope incomplete. Otherwise,
+// EncodeTo writes [wrapper.JSON].
+//
+// Parameters:
+//   - `dst`: The destination writer.
+//
+// Returns:
+//   - An error if the [wrapper] or the writer is nil, or if encoding or writing fails.
+//
+// Example:
+//
+//	f, _ := os.Create("orders.json")
+//	defer f.Close()
+//	w := replify.WrapOk("Orders", nil).WithBodySeq(replify.BodySeq(orders.All(ctx)))
+//	if err := w.EncodeTo(f); err != nil {
+//	    log.Fatal(err)
+//	}
+func (w *wrapper) EncodeTo(dst io.Writer) error {
+	if !w.Available() {
+		return NewError("EncodeTo: wrapper is not available")
+	}
+	if dst == nil {
+		return NewError("EncodeTo: writer is nil")
+	}
+	if w.bodySeq == nil {
+		_, err := io.WriteString(dst, w.JSON())
+		return err
+	}
+
+	head := []string{"status_code", "path", "message", "headers", "meta"}
+	tail := []string{"total", "pagination", "debug", "errors"}
+	if w.canonical {
+		head = nil
+		tail = []string{"debug", "errors", "headers", "message", "meta", "pagination", "path", "status_code", "total"}
+	}
+
+	bw := bufio.NewWriterSize(dst, bodySeqBufferSize)
+	fields := 0
+	sections := func(keys []string) error {
+		for _, key := range keys {
+			value, ok := w.encodedSection(key)
+			if !ok {
+				continue
+			}
+			s, err := w.encodeValue(value)
+			if err != nil {
+				return NewErrorf("EncodeTo: encode %s: %v", key, err)
+			}
+			if fields > 0 {
+				bw.WriteByte(',')
+			}
+			fields++
+			bw.WriteString(`"` + key + `":`)
+			if _, err := bw.WriteString(s); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	bw.WriteByte('{')
+	if err := sections(head); err != nil {
+		return err
+	}
+	count, err := w.encodeBodySeq(bw, fields > 0)
+	if err != nil {
+		return err
+	}
+	fields++
+	w.total = count
+	if w.pagination != nil {
+		if w.pagination.totalItems == 0 {
+			w.pagination.totalItems = count
+		}
+		w.pagination.calculate()
+	}
+	if err := sections(tail); err != nil {
+		return err
+	}
+	bw.WriteByte('}')
+	return bw.Flush()
+}
+
+// encodedSection returns the value of a section of a streamed envelope and
+// whether the envelope includes it.
+func (w *wrapper) encodedSection(key string) (any, bool) {
+	switch key {
+	case "status_code":
+		return w.statusCode, w.IsStatusCodePresent()
+	case "path":
+		return w.path, w.path != ""
+	case "message":
+		return w.message, w.message != ""
+	case "headers":
+		if w.IsHeaderPresent() {
+			return w.header.Respond(), true
+		}
+	case "meta":
+		if w.IsMetaPresent() {
+			return w.meta.Respond(), true
+		}
+	case "total":
+		return w.total, true
+	case "pagination":
+		if w.pagination != nil {
+			return w.pagination.Respond(), true
+		}
+	case "debug":
+		return w.debug, w.IsDebuggingPresent()
+	case "errors":
+		if w.IsErrorChainPresent() {
+			return w.ErrorTree(), true
+		}
+	}
+	return nil, false
+}
+
+// encodeBodySeq writes the `data` array of a streamed body and returns the
+// number of elements.
+func (w *wrapper) encodeBodySeq(bw *bufio.Writer, comma bool) (int, error) {
+	if comma {
+		bw.WriteByte(',')
+	}
+	bw.WriteString(`"data":[`)
+	count := 0
+	for v := range w.bodySeq {
+		v = safeBody(v)
+		if w.bodyRedact != nil {
+			var err error
+			if v, err = w.bodyRedact.apply(v); err != nil {
+				return count, NewErrorf("EncodeTo: redact element %d: %v", count, err)
+			}
+		}
+		s, err := w.encodeValue(v)
+		if err != nil {
+			return count, NewErrorf("EncodeTo: encode element %d: %v", count, err)
+		}
+		if count > 0 {
+			bw.WriteByte(',')
+		}
+		if _, err := bw.WriteString(s); err != nil {
+			return count, err
+		}
+		count++
+	}
+	_, err := bw.WriteString("]")
+	return count, err
+}
+
+// encodeValue encodes a section or an element of a streamed envelope with
+// the serializer of [wrapper.JSON], in canonical form when it is enabled.
+func (w *wrapper) encodeValue(v any) (string, error) {
+	if w.canonical {
+		if s, err := encoding.CanonicalJSONString(v); err == nil {
+			return s, nil
+		}
+	}
+	switch t := v.(type) {
+	case nil:
+		return "null", nil
+	case string:
+		return encoding.MarshalJSONString(t)
+	}
+	return encoding.JSONE(v)
+}
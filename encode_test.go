@@ -0,0 +1,211 @@
+package replify_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sivaosorg/replify"
+)
+
+type encodeRow struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func encodeRows(n int) func(yield func(encodeRow) bool) {
+	return func(yield func(encodeRow) bool) {
+		for i := range n {
+			if !yield(encodeRow{ID: i, Name: "row"}) {
+				return
+			}
+		}
+	}
+}
+
+func TestEncodeToBodySeq(t *testing.T) {
+	t.Parallel()
+
+	w := replify.New().
+		WithStatusCode(200).
+		WithMessage("rows").
+		WithPagination(replify.Pages().WithPage(2).WithPerPage(10)).
+		WithBodySeq(replify.BodySeq(encodeRows(25)))
+
+	var out bytes.Buffer
+	if err := w.EncodeTo(&out); err != nil {
+		t.Fatalf("EncodeTo: %v", err)
+	}
+	var got struct {
+		StatusCode int         `json:"status_code"`
+		Data       []encodeRow `json:"data"`
+		Total      int         `json:"total"`
+		Pagination struct {
+			TotalItems int  `json:"total_items"`
+			TotalPages int  `json:"total_pages"`
+			IsLast     bool `json:"is_last"`
+		} `json:"pagination"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON %s: %v", out.String(), err)
+	}
+	if len(got.Data) != 25 || got.Data[24].ID != 24 || got.Total != 25 {
+		t.Errorf("got %d rows, total %d, want 25", len(got.Data), got.Total)
+	}
+	if got.Pagination.TotalItems != 25 || got.Pagination.TotalPages != 3 || got.Pagination.IsLast {
+		t.Errorf("pagination = %+v, want 25 items on 3 pages, not last", got.Pagination)
+	}
+	if i, j := bytes.Index(out.Bytes(), []byte(`"message"`)), bytes.Index(out.Bytes(), []byte(`"pagination"`)); i > bytes.Index(out.Bytes(), []byte(`"data"`)) || j < bytes.Index(out.Bytes(), []byte(`"data"`)) {
+		t.Errorf("want the message before the data and the pagination after: %s", out.String())
+	}
+	if w.Total() != 25 {
+		t.Errorf("Total() = %d, want 25", w.Total())
+	}
+}
+
+func TestEncodeToBodyChanHTTP(t *testing.T) {
+	t.Parallel()
+
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		for _, s := range []string{"a", `{"b":1}`, "c"} {
+			ch <- s
+		}
+	}()
+	rec := httptest.NewRecorder()
+	w := replify.WrapOk("letters", nil).WithBodySeq(replify.BodyChan(ch))
+	if err := w.WriteHTTP(rec); err != nil {
+		t.Fatalf("WriteHTTP: %v", err)
+	}
+	var got struct {
+		Data  []any `json:"data"`
+		Total int   `json:"total"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON %s: %v", rec.Body.String(), err)
+	}
+	if rec.Code != 200 || got.Total != 3 || got.Data[0] != "a" || got.Data[1].(map[string]any)["b"] != 1.0 {
+		t.Errorf("got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestEncodeToBodySeqDump(t *testing.T) {
+	t.Parallel()
+
+	w := replify.WrapOk("rows", nil).WithBodySeq(replify.BodySeq(slices.Values([]int{1, 2, 3})))
+	d, res := w.Dump()
+	if res.IsError() {
+		t.Fatalf("Dump: %v", res.Error())
+	}
+	defer d.Close()
+	_ = d.Rewind()
+	b, _ := io.ReadAll(d.Resource().Content())
+	if !bytes.Contains(b, []byte(`"data":[1,2,3]`)) || !bytes.Contains(b, []byte(`"total":3`)) {
+		t.Errorf("dump = %s", b)
+	}
+}
+
+func TestEncodeToStopsOnWriteError(t *testing.T) {
+	t.Parallel()
+
+	var produced int
+	seq := func(yield func(int) bool) {
+		for i := 0; ; i++ {
+			produced++
+			if !yield(i) {
+				return
+			}
+		}
+	}
+	w := replify.New().WithBodySeq(replify.BodySeq(seq))
+	if err := w.EncodeTo(&brokenWriter{}); !errors.Is(err, errBroken) {
+		t.Fatalf("EncodeTo = %v, want errBroken", err)
+	}
+	if produced > 100000 {
+		t.Errorf("produced %d elements after the writer failed", produced)
+	}
+}
+
+func TestEncodeToBodySeqRedactsPolicyFields(t *testing.T) {
+	t.Parallel()
+
+	set, err := replify.ParsePolicies([]byte(policyConfig), nil)
+	if err != nil {
+		t.Fatalf("ParsePolicies: %v", err)
+	}
+	users := []map[string]any{{"name": "alice", "password": "secret1"}, {"name": "bob", "Password": "secret2"}}
+	w := replify.WrapOk("users", nil).
+		WithBodySeq(replify.BodySeq(slices.Values(users))).
+		WithPolicies(set)
+
+	rec := httptest.NewRecorder()
+	w.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/users", nil))
+	body := rec.Body.String()
+	if strings.Contains(body, "secret") {
+		t.Fatalf("streamed elements not redacted: %s", body)
+	}
+	if !strings.Contains(body, `"password":"[REDACTED]"`) || !strings.Contains(body, `"Password":"[REDACTED]"`) {
+		t.Errorf("body = %s", body)
+	}
+}
+
+func TestEncodeToBodySeqCanonical(t *testing.T) {
+	t.Parallel()
+
+	rows := []map[string]any{{"b": 1.0, "a": "x"}, {"c": 1e21}}
+	w := replify.WrapOk("rows", rows).WithTotal(len(rows)).WithCanonicalJSON(true)
+	want := w.JSON()
+	var out bytes.Buffer
+	if err := w.Clone().WithBodySeq(replify.BodySeq(slices.Values(rows))).EncodeTo(&out); err != nil {
+		t.Fatalf("EncodeTo: %v", err)
+	}
+	if out.String() != want {
+		t.Errorf("streamed = %s\nwant       %s", out.String(), want)
+	}
+}
+
+func TestServeHTTPBodySeqNegotiation(t *testing.T) {
+	t.Parallel()
+
+	rows := func() http.Handler {
+		return replify.WrapOk("rows", nil).
+			WithContentEncoding(64).
+			WithBodySeq(replify.BodySeq(encodeRows(100)))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	rows().ServeHTTP(rec, req)
+	if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	decoded, _ := io.ReadAll(zr)
+	var got struct {
+		Data  []encodeRow `json:"data"`
+		Total int         `json:"total"`
+	}
+	if err := json.Unmarshal(decoded, &got); err != nil || got.Total != 100 || len(got.Data) != 100 {
+		t.Errorf("decoded body = %.80s (%v)", decoded, err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "application/json; version=1")
+	rec = httptest.NewRecorder()
+	rows().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotAcceptable || strings.Contains(rec.Body.String(), `"data"`) {
+		t.Errorf("got %d %s, want 406 without data", rec.Code, rec.Body.String())
+	}
+}
@@ -25,14 +25,19 @@ import (
 //	"data"         data            string/[]byte → json.RawMessage when valid
 //	                                JSON; any other type stored as-is
 //	"debug"        debug           map[string]any
-//	"header"       header          object → *header (code, text, type,
+//	"headers"      header          object → *header (code, text, type,
 //	                                description)
 //	"meta"         meta            object → *meta (api_version, locale,
 //	                                request_id, requested_time,
-//	                                custom_fields)
+//	                                custom_fields, delta_cnt, delta_value)
+//	"errors"       errors          object → *ErrorNode (error chain)
 //	"pagination"   pagination      object → *pagination (page, per_page,
 //	                                total_pages, total_items, is_last)
 //
+// Documents of an older envelope layout (see [DetectEnvelopeVersion]) are
+// first upgraded to [EnvelopeVersionCurrent] with [MigrateEnvelope]; each
+// migration step applied increments the delta counter of the result.
+//
 // Unknown top-level keys are silently ignored. Missing keys leave the
 // corresponding field at its zero value—no error is returned.
 //
@@ -97,6 +102,11 @@ func UnwrapJSON(jsonStr string) (w *wrapper, err error) {
 	if len(data) == 0 {
 		return nil, NewErrorf("an unexpected error occurred while unmarshaling JSON to map, json: %s", nJSON)
 	}
+	// upgrade legacy envelope layouts before mapping the fields
+	data, steps, err := MigrateEnvelope(data, DetectEnvelopeVersion(data), EnvelopeVersionCurrent)
+	if err != nil {
+		return nil, err
+	}
 	w = &wrapper{}
 	if value, exists := data["status_code"].(float64); exists {
 		w.statusCode = int(value)
@@ -127,6 +137,12 @@ func UnwrapJSON(jsonStr string) (w *wrapper, err error) {
 		if customFields, exists := values["custom_fields"].(map[string]any); exists {
 			meta.customFields = customFields
 		}
+		if value, exists := values["delta_cnt"].(float64); exists {
+			meta.deltaCnt = int(value)
+		}
+		if value, exists := values["delta_value"].(float64); exists {
+			meta.deltaValue = value
+		}
 		if value, exists := values["requested_time"].(string); exists {
 			if t, err := time.Parse(time.RFC3339, value); err == nil {
 				meta.requestedTime = t
@@ -137,7 +153,12 @@ func UnwrapJSON(jsonStr string) (w *wrapper, err error) {
 		}
 		w.meta = meta
 	}
-	if values, exists := data["header"].(map[string]any); exists {
+	if node := errorNodeFrom(data["errors"]); node != nil {
+		w.errors = node
+		w.errorChain = true
+		w.errorChainStack = hasErrorStack(node)
+	}
+	if values, exists := data["headers"].(map[string]any); exists {
 		header := &header{}
 		if value, exists := values["code"].(float64); exists {
 			header.code = int(value)
@@ -181,6 +202,10 @@ func UnwrapJSON(jsonStr string) (w *wrapper, err error) {
 	if value, exists := data["data"]; exists {
 		w.data = safeBody(value)
 	}
+	// record the layout migration in the delta counter
+	for range steps {
+		w.IncreaseDeltaCnt()
+	}
 	return w, nil
 }
 
@@ -0,0 +1,405 @@
+package replify
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/sivaosorg/replify/pkg/match"
+	"github.com/sivaosorg/replify/pkg/strutil"
+)
+
+// envelopes is the process-wide registry of envelope layouts.
+var envelopes = newEnvelopeRegistry()
+
+// RegisterEnvelopeMigration registers the transforms between two envelope
+// layout versions.
+//
+// Migrations form a graph: Up is an edge from From to To and Down, when set,
+// an edge back. [MigrateEnvelope] walks the shortest path through the graph,
+// so registering "2"→"3" next to the built-in "1"→"2" lets documents travel
+// directly between "1" and "3". Registering a migration for a pair that is
+// already known replaces it.
+//
+// Parameters:
+//   - `m`: The migration; From, To and Up are required.
+//
+// Returns:
+//   - An error if the migration is incomplete.
+//
+// Example:
+//
+//	replify.RegisterEnvelopeMigration(replify.EnvelopeMigration{
+//	    From: replify.EnvelopeV2,
+//	    To:   "3",
+//	    Up: func(doc map[string]any) (map[string]any, error) {
+//	        doc["code"] = doc["status_code"]
+//	        delete(doc, "status_code")
+//	        return doc, nil
+//	    },
+//	})
+func RegisterEnvelopeMigration(m EnvelopeMigration) error {
+	if strutil.IsEmpty(m.From) || strutil.IsEmpty(m.To) {
+		return NewError("RegisterEnvelopeMigration: both versions are required")
+	}
+	if m.From == m.To {
+		return NewErrorf("RegisterEnvelopeMigration: cannot migrate version %q to itself", m.From)
+	}
+	if m.Up == nil {
+		return NewErrorf("RegisterEnvelopeMigration: missing up transform from %q to %q", m.From, m.To)
+	}
+	envelopes.mu.Lock()
+	defer envelopes.mu.Unlock()
+	for i, existing := range envelopes.migrations {
+		if existing.From == m.From && existing.To == m.To {
+			envelopes.migrations[i] = m
+			return nil
+		}
+	}
+	envelopes.migrations = append(envelopes.migrations, m)
+	return nil
+}
+
+// UnregisterEnvelopeMigration removes the migration registered between two
+// envelope layout versions, in that direction.
+//
+// Parameters:
+//   - `from`: The layout version the migration reads.
+//   - `to`: The layout version the migration produces.
+//
+// Returns:
+//   - `true` if a migration was removed.
+func UnregisterEnvelopeMigration(from, to string) bool {
+	envelopes.mu.Lock()
+	defer envelopes.mu.Unlock()
+	n := len(envelopes.migrations)
+	envelopes.migrations = slices.DeleteFunc(envelopes.migrations, func(m EnvelopeMigration) bool {
+		return m.From == from && m.To == to
+	})
+	return len(envelopes.migrations) < n
+}
+
+// RegisterEnvelopeDetector registers a function that recognizes the layout
+// version of decoded documents. Detectors are consulted in registration
+// order by [DetectEnvelopeVersion]; the built-in detector recognizes
+// [EnvelopeV1] documents.
+//
+// Parameters:
+//   - `d`: The detector; nil is ignored.
+func RegisterEnvelopeDetector(d EnvelopeDetector) {
+	if d == nil {
+		return
+	}
+	envelopes.mu.Lock()
+	envelopes.detectors = append(envelopes.detectors, d)
+	envelopes.mu.Unlock()
+}
+
+// BindEnvelopeVersion renders wrappers whose meta.apiVersion matches pattern
+// with the given envelope layout version, unless the [wrapper] or the
+// request selects a version explicitly. Bindings are evaluated in
+// registration order and the first match wins.
+//
+// Parameters:
+//   - `apiVersionPattern`: A wildcard pattern, e.g. "v1" or "v1.*".
+//   - `version`: The envelope layout version.
+//
+// Example:
+//
+//	replify.BindEnvelopeVersion("v1*", replify.EnvelopeV1)
+//	replify.WrapOk("ok", data).WithApiVersion("v1.4").WriteHTTP(rw) // legacy "header" key
+func BindEnvelopeVersion(apiVersionPattern, version string) {
+	if strutil.IsEmpty(apiVersionPattern) || strutil.IsEmpty(version) {
+		return
+	}
+	envelopes.mu.Lock()
+	envelopes.bindings = append(envelopes.bindings, envelopeBinding{pattern: apiVersionPattern, version: version})
+	envelopes.mu.Unlock()
+}
+
+// UnbindEnvelopeVersion removes the bindings registered for a pattern with
+// [BindEnvelopeVersion].
+//
+// Parameters:
+//   - `apiVersionPattern`: The pattern of the bindings to remove.
+//
+// Returns:
+//   - `true` if a binding was removed.
+func UnbindEnvelopeVersion(apiVersionPattern string) bool {
+	envelopes.mu.Lock()
+	defer envelopes.mu.Unlock()
+	n := len(envelopes.bindings)
+	envelopes.bindings = slices.DeleteFunc(envelopes.bindings, func(b envelopeBinding) bool {
+		return b.pattern == apiVersionPattern
+	})
+	return len(envelopes.bindings) < n
+}
+
+// DetectEnvelopeVersion reports the layout version of a decoded document
+// using the registered detectors, defaulting to [EnvelopeVersionCurrent].
+//
+// Parameters:
+//   - `doc`: The decoded envelope document.
+//
+// Returns:
+//   - The detected layout version.
+func DetectEnvelopeVersion(doc map[string]any) string {
+	envelopes.mu.RLock()
+	defer envelopes.mu.RUnlock()
+	for _, detect := range envelopes.detectors {
+		if version, ok := detect(doc); ok && strutil.IsNotEmpty(version) {
+			return version
+		}
+	}
+	return EnvelopeVersionCurrent
+}
+
+// MigrateEnvelope converts a decoded envelope document between two layout
+// versions, composing the registered migrations along the shortest path.
+// The input document is never modified; transforms operate on a deep copy.
+//
+// Parameters:
+//   - `doc`: The decoded envelope document.
+//   - `from`: The layout version of doc.
+//   - `to`: The target layout version.
+//
+// Returns:
+//   - The migrated document.
+//   - The number of migration steps applied (0 when from equals to).
+//   - An error if no migration path exists or a transform fails.
+//
+// Example:
+//
+//	legacy, steps, err := replify.MigrateEnvelope(doc, replify.EnvelopeV2, replify.EnvelopeV1)
+func MigrateEnvelope(doc map[string]any, from, to string) (map[string]any, int, error) {
+	if from == to {
+		return doc, 0, nil
+	}
+	path, err := envelopes.path(from, to)
+	if err != nil {
+		return nil, 0, err
+	}
+	out, err := copyDocument(doc)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, step := range path {
+		next, err := step.transform(out)
+		if err != nil {
+			return nil, 0, NewErrorf("migrate envelope from %q to %q: %v", step.from, step.to, err)
+		}
+		if next == nil {
+			next = make(map[string]any)
+		}
+		out = next
+	}
+	return out, len(path), nil
+}
+
+// NegotiateEnvelopeVersion extracts the envelope layout version requested by
+// the `version` parameter of an Accept header, e.g.
+// "application/json; version=1". Media ranges are considered in order.
+//
+// Parameters:
+//   - `accept`: The Accept header value.
+//
+// Returns:
+//   - The requested layout version.
+//   - `true` if a version parameter was found.
+func NegotiateEnvelopeVersion(accept string) (string, bool) {
+	for _, part := range strings.Split(accept, ",") {
+		if strutil.IsEmpty(strings.TrimSpace(part)) {
+			continue
+		}
+		_, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		if version := params[envelopeVersionParam]; strutil.IsNotEmpty(version) {
+			return version, true
+		}
+	}
+	return "", false
+}
+
+// WithEnvelopeVersion selects the envelope layout version used when the
+// [wrapper] is rendered through [wrapper.WriteHTTP]. An explicit version
+// overrides the bindings registered with [BindEnvelopeVersion].
+//
+// Parameters:
+//   - `version`: The envelope layout version; empty restores the default resolution.
+//
+// Returns:
+//   - A pointer to the modified [wrapper] instance (enabling method chaining).
+func (w *wrapper) WithEnvelopeVersion(version string) *wrapper {
+	if !w.Available() {
+		return w
+	}
+	w.envelopeVersion = version
+	return w
+}
+
+// EnvelopeVersion returns the envelope layout version the [wrapper] renders
+// with: the explicit version set by [wrapper.WithEnvelopeVersion], else the
+// version bound to meta.apiVersion, else [EnvelopeVersionCurrent].
+//
+// Returns:
+//   - The resolved layout version.
+func (w *wrapper) EnvelopeVersion() string {
+	if !w.Available() {
+		return EnvelopeVersionCurrent
+	}
+	if strutil.IsNotEmpty(w.envelopeVersion) {
+		return w.envelopeVersion
+	}
+	if w.IsMetaPresent() && w.meta.IsApiVersionPresent() {
+		envelopes.mu.RLock()
+		defer envelopes.mu.RUnlock()
+		for _, b := range envelopes.bindings {
+			if b.pattern == w.meta.apiVersion || match.Match(w.meta.apiVersion, b.pattern) {
+				return b.version
+			}
+		}
+	}
+	return EnvelopeVersionCurrent
+}
+
+// RespondVersion renders the [wrapper] as a document of the given envelope
+// layout version.
+//
+// When a migration is needed, the document is rendered from a copy of the
+// [wrapper] whose delta counter is increased once per migration step, so the
+// `meta.delta_cnt` of the output records the migration. The receiver itself
+// is left untouched, which keeps a shared [wrapper] safe to serve
+// concurrently.
+//
+// Parameters:
+//   - `version`: The target layout version.
+//
+// Returns:
+//   - The rendered document.
+//   - An error if no migration path leads to version.
+func (w *wrapper) RespondVersion(version string) (map[string]any, error) {
+	if !w.Available() {
+		return nil, NewError("RespondVersion: wrapper is not available")
+	}
+	if strutil.IsEmpty(version) || version == EnvelopeVersionCurrent {
+		return w.Respond(), nil
+	}
+	path, err := envelopes.path(EnvelopeVersionCurrent, version)
+	if err != nil {
+		return nil, err
+	}
+	c := w.Clone()
+	for range path {
+		c.IncreaseDeltaCnt()
+	}
+	doc, _, err := MigrateEnvelope(c.Respond(), EnvelopeVersionCurrent, version)
+	return doc, err
+}
+
+// JSONVersion renders the [wrapper] as a JSON string of the given envelope
+// layout version. See [wrapper.RespondVersion].
+//
+// Parameters:
+//   - `version`: The target layout version.
+//
+// Returns:
+//   - The JSON document.
+//   - An error if no migration path leads to version.
+func (w *wrapper) JSONVersion(version string) (string, error) {
+	doc, err := w.RespondVersion(version)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// requestEnvelopeVersion resolves the layout version for a request: the
+// Accept header wins over the version of the [wrapper].
+func (w *wrapper) requestEnvelopeVersion(r *http.Request) string {
+	if r != nil {
+		if version, ok := NegotiateEnvelopeVersion(r.Header.Get(HeaderAccept.String())); ok {
+			return version
+		}
+	}
+	return w.EnvelopeVersion()
+}
+
+// path finds the shortest chain of transforms from one version to another
+// with a breadth-first search over the migration graph.
+func (r *envelopeRegistry) path(from, to string) ([]envelopeStep, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	edges := make(map[string][]envelopeStep)
+	for _, m := range r.migrations {
+		edges[m.From] = append(edges[m.From], envelopeStep{from: m.From, to: m.To, transform: m.Up})
+		if m.Down != nil {
+			edges[m.To] = append(edges[m.To], envelopeStep{from: m.To, to: m.From, transform: m.Down})
+		}
+	}
+	prev := map[string]envelopeStep{from: {}}
+	queue := []string{from}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		if v == to {
+			var steps []envelopeStep
+			for v != from {
+				step := prev[v]
+				steps = append([]envelopeStep{step}, steps...)
+				v = step.from
+			}
+			return steps, nil
+		}
+		for _, e := range edges[v] {
+			if _, seen := prev[e.to]; !seen {
+				prev[e.to] = e
+				queue = append(queue, e.to)
+			}
+		}
+	}
+	return nil, NewErrorf("no envelope migration path from version %q to %q", from, to)
+}
+
+// renameEnvelopeKey returns a transform that moves a top-level key.
+func renameEnvelopeKey(from, to string) EnvelopeTransform {
+	return func(doc map[string]any) (map[string]any, error) {
+		if value, ok := doc[from]; ok {
+			doc[to] = value
+			delete(doc, from)
+		}
+		return doc, nil
+	}
+}
+
+// detectEnvelopeV1 recognizes legacy documents carrying the structured
+// header under the singular "header" key.
+func detectEnvelopeV1(doc map[string]any) (string, bool) {
+	_, legacy := doc["header"].(map[string]any)
+	_, current := doc["headers"]
+	return EnvelopeV1, legacy && !current
+}
+
+// copyDocument deep-copies a document through a JSON round-trip, so the
+// transforms never alias the maps owned by a [wrapper].
+func copyDocument(doc map[string]any) (map[string]any, error) {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]any
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	if out == nil {
+		out = make(map[string]any)
+	}
+	return out, nil
+}
@@ -0,0 +1,161 @@
+package replify_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sivaosorg/replify"
+)
+
+func TestUnwrapJSONUpgradesLegacyEnvelope(t *testing.T) {
+	t.Parallel()
+
+	legacy := `{"status_code":404,"message":"missing","header":{"code":404,"text":"Not Found","type":"Client Error"}}`
+	w, err := replify.UnwrapJSON(legacy)
+	if err != nil {
+		t.Fatalf("UnwrapJSON: %v", err)
+	}
+	if got := w.Header().Text(); got != "Not Found" {
+		t.Errorf("header text = %q, want %q", got, "Not Found")
+	}
+	if got := w.Meta().DeltaCnt(); got != 1 {
+		t.Errorf("delta_cnt = %d, want 1", got)
+	}
+
+	current, err := replify.UnwrapJSON(w.JSON())
+	if err != nil {
+		t.Fatalf("UnwrapJSON: %v", err)
+	}
+	if got := current.Meta().DeltaCnt(); got != 1 {
+		t.Errorf("current layout must not be migrated again, delta_cnt = %d", got)
+	}
+}
+
+func TestEnvelopeMigrationsCompose(t *testing.T) {
+	t.Parallel()
+
+	err := replify.RegisterEnvelopeMigration(replify.EnvelopeMigration{
+		From: replify.EnvelopeV2,
+		To:   "compose-3",
+		Up: func(doc map[string]any) (map[string]any, error) {
+			doc["code"] = doc["status_code"]
+			delete(doc, "status_code")
+			return doc, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("RegisterEnvelopeMigration: %v", err)
+	}
+	t.Cleanup(func() { replify.UnregisterEnvelopeMigration(replify.EnvelopeV2, "compose-3") })
+
+	legacy := map[string]any{"status_code": 200.0, "header": map[string]any{"code": 200.0}}
+	doc, steps, err := replify.MigrateEnvelope(legacy, replify.EnvelopeV1, "compose-3")
+	if err != nil {
+		t.Fatalf("MigrateEnvelope: %v", err)
+	}
+	if steps != 2 {
+		t.Errorf("steps = %d, want 2", steps)
+	}
+	if _, ok := doc["headers"]; !ok || doc["code"] != 200.0 {
+		t.Errorf("unexpected migrated document: %v", doc)
+	}
+	if _, ok := legacy["header"]; !ok {
+		t.Error("the input document must not be modified")
+	}
+	if _, _, err := replify.MigrateEnvelope(doc, "compose-3", replify.EnvelopeV1); err == nil {
+		t.Error("expected an error for a migration without a down transform")
+	}
+}
+
+func TestServeHTTPNegotiatesEnvelopeVersion(t *testing.T) {
+	t.Parallel()
+
+	w := replify.WrapOk("ok", map[string]any{"id": 1})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "application/json; version=1")
+	rec := httptest.NewRecorder()
+	w.ServeHTTP(rec, req)
+
+	body := rec.Body.String()
+	if !strings.Contains(body, `"header":{`) || strings.Contains(body, `"headers"`) {
+		t.Errorf("expected a legacy envelope, got %s", body)
+	}
+	if !strings.Contains(body, `"delta_cnt":1`) {
+		t.Errorf("expected the migration to be recorded, got %s", body)
+	}
+	if w.Meta().IsDeltaCntPresent() {
+		t.Error("rendering a version must not modify the wrapper")
+	}
+
+	rec = httptest.NewRecorder()
+	w.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(rec.Body.String(), `"headers":{`) {
+		t.Errorf("expected the current envelope, got %s", rec.Body.String())
+	}
+}
+
+func TestServeHTTPUnknownEnvelopeVersion(t *testing.T) {
+	t.Parallel()
+
+	w := replify.WrapOk("ok", map[string]any{"id": 1}).WithPath("/orders")
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set("Accept", "application/json; version=99")
+	rec := httptest.NewRecorder()
+	w.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotAcceptable {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotAcceptable)
+	}
+	got, err := replify.UnwrapJSON(rec.Body.String())
+	if err != nil {
+		t.Fatalf("invalid error envelope %q: %v", rec.Body.String(), err)
+	}
+	if got.StatusCode() != http.StatusNotAcceptable || !strings.Contains(got.Message(), `"99"`) || got.IsBodyPresent() {
+		t.Errorf("error envelope = %s", rec.Body.String())
+	}
+	if w.StatusCode() != http.StatusOK || w.Message() != "ok" {
+		t.Error("answering 406 must not modify the wrapper")
+	}
+}
+
+func TestUnregisterEnvelopeMigration(t *testing.T) {
+	t.Parallel()
+
+	m := replify.EnvelopeMigration{From: "unregister-a", To: "unregister-b", Up: func(doc map[string]any) (map[string]any, error) { return doc, nil }}
+	if err := replify.RegisterEnvelopeMigration(m); err != nil {
+		t.Fatalf("RegisterEnvelopeMigration: %v", err)
+	}
+	if !replify.UnregisterEnvelopeMigration(m.From, m.To) {
+		t.Fatal("UnregisterEnvelopeMigration = false, want true")
+	}
+	if _, _, err := replify.MigrateEnvelope(map[string]any{}, m.From, m.To); err == nil {
+		t.Error("expected no migration path after unregistering")
+	}
+	if replify.UnregisterEnvelopeMigration(m.From, m.To) {
+		t.Error("UnregisterEnvelopeMigration of an unknown migration = true")
+	}
+}
+
+func TestBindEnvelopeVersion(t *testing.T) {
+	t.Parallel()
+
+	replify.BindEnvelopeVersion("bind-v1*", replify.EnvelopeV1)
+	t.Cleanup(func() { replify.UnbindEnvelopeVersion("bind-v1*") })
+
+	w := replify.WrapOk("ok", nil).WithApiVersion("bind-v1.4")
+	if got := w.EnvelopeVersion(); got != replify.EnvelopeV1 {
+		t.Fatalf("EnvelopeVersion = %q, want %q", got, replify.EnvelopeV1)
+	}
+	if got := w.WithEnvelopeVersion(replify.EnvelopeV2).EnvelopeVersion(); got != replify.EnvelopeV2 {
+		t.Errorf("explicit version = %q, want %q", got, replify.EnvelopeV2)
+	}
+	if !replify.UnbindEnvelopeVersion("bind-v1*") {
+		t.Fatal("UnbindEnvelopeVersion = false, want true")
+	}
+	if got := replify.WrapOk("ok", nil).WithApiVersion("bind-v1.4").EnvelopeVersion(); got != replify.EnvelopeVersionCurrent {
+		t.Errorf("EnvelopeVersion after unbinding = %q, want %q", got, replify.EnvelopeVersionCurrent)
+	}
+}
@@ -0,0 +1,226 @@
+package replify
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/sivaosorg/replify/pkg/strutil"
+)
+
+// Classify attaches [ErrorClassification] metadata to an error.
+//
+// The returned error keeps the message, the stack trace and the chain of the
+// original error (it is reachable through `errors.Unwrap`), so it can be
+// passed to [NewErrorAck], [AppendError] and friends as usual. Classifying an
+// already classified error merges the flags; non-empty PublicMessage and
+// non-zero StatusCode override the previous values.
+//
+// Parameters:
+//   - `err`: The error to classify; nil yields nil.
+//   - `class`: The classification metadata.
+//
+// Returns:
+//   - The classified error, or nil if err is nil.
+//
+// Example:
+//
+//	err := replify.Classify(replify.NewError("upstream overloaded"), replify.ErrorClassification{
+//	    Retryable:     true,
+//	    Temporary:     true,
+//	    PublicMessage: "The service is busy, please retry shortly",
+//	})
+//	replify.IsRetryable(replify.AppendError(err, "fetch profile")) // true
+func Classify(err error, class ErrorClassification) error {
+	if err == nil {
+		return nil
+	}
+	if c, ok := err.(*classified); ok {
+		merged := c.class
+		merged.Retryable = merged.Retryable || class.Retryable
+		merged.Temporary = merged.Temporary || class.Temporary
+		merged.Timeout = merged.Timeout || class.Timeout
+		if strutil.IsNotEmpty(class.PublicMessage) {
+			merged.PublicMessage = class.PublicMessage
+		}
+		if class.StatusCode > 0 {
+			merged.StatusCode = class.StatusCode
+		}
+		return &classified{cause: c.cause, class: merged}
+	}
+	return &classified{cause: err, class: class}
+}
+
+// Retryable marks an error as retryable. It is shorthand for [Classify]
+// with ErrorClassification{Retryable: true}.
+func Retryable(err error) error {
+	return Classify(err, ErrorClassification{Retryable: true})
+}
+
+// Temporary marks an error as temporary (and therefore retryable). It is
+// shorthand for [Classify] with ErrorClassification{Temporary: true, Retryable: true}.
+func Temporary(err error) error {
+	return Classify(err, ErrorClassification{Temporary: true, Retryable: true})
+}
+
+// Timeout marks an error as a timeout. It is shorthand for [Classify] with
+// ErrorClassification{Timeout: true}.
+func Timeout(err error) error {
+	return Classify(err, ErrorClassification{Timeout: true})
+}
+
+// Public attaches a client-safe message to an error, keeping the error's own
+// message as internal detail. It is shorthand for [Classify] with
+// ErrorClassification{PublicMessage: message}.
+func Public(err error, message string) error {
+	return Classify(err, ErrorClassification{PublicMessage: message})
+}
+
+// IsRetryable reports whether any error in the chain of err reports itself
+// as retryable through a `Retryable() bool` method.
+func IsRetryable(err error) bool {
+	return anyError(err, func(e error) bool {
+		r, ok := e.(interface{ Retryable() bool })
+		return ok && r.Retryable()
+	})
+}
+
+// IsTemporary reports whether any error in the chain of err reports itself
+// as temporary through a `Temporary() bool` method (as `net.Error` does).
+func IsTemporary(err error) bool {
+	return anyError(err, func(e error) bool {
+		t, ok := e.(interface{ Temporary() bool })
+		return ok && t.Temporary()
+	})
+}
+
+// IsTimeout reports whether any error in the chain of err reports itself
+// as a timeout through a `Timeout() bool` method (as `net.Error` and
+// `context.DeadlineExceeded` do).
+func IsTimeout(err error) bool {
+	return anyError(err, func(e error) bool {
+		t, ok := e.(interface{ Timeout() bool })
+		return ok && t.Timeout()
+	})
+}
+
+// PublicMessage returns the first client-safe message found in the chain of
+// err (see [Public]), or an empty string if none is attached.
+func PublicMessage(err error) string {
+	var message string
+	anyError(err, func(e error) bool {
+		if p, ok := e.(interface{ PublicMessage() string }); ok {
+			message = p.PublicMessage()
+		}
+		return strutil.IsNotEmpty(message)
+	})
+	return message
+}
+
+// ClassOf aggregates the classification of every error in the chain of err.
+//
+// Parameters:
+//   - `err`: The error to inspect.
+//
+// Returns:
+//   - The aggregated [ErrorClassification].
+//   - `true` if at least one error of the chain carries classification metadata.
+func ClassOf(err error) (ErrorClassification, bool) {
+	class := ErrorClassification{
+		Retryable:     IsRetryable(err),
+		Temporary:     IsTemporary(err),
+		Timeout:       IsTimeout(err),
+		PublicMessage: PublicMessage(err),
+	}
+	anyError(err, func(e error) bool {
+		if c, ok := e.(*classified); ok && c.class.StatusCode > 0 {
+			class.StatusCode = c.class.StatusCode
+			return true
+		}
+		return false
+	})
+	found := class.Retryable || class.Temporary || class.Timeout ||
+		strutil.IsNotEmpty(class.PublicMessage) || class.StatusCode > 0
+	return class, found
+}
+
+// Error returns the message of the classified error unchanged.
+func (c *classified) Error() string { return c.cause.Error() }
+
+// Cause returns the classified error for the `classified` type.
+func (c *classified) Cause() error { return c.cause }
+
+// Unwrap provides compatibility for Go 1.13 error chains.
+func (c *classified) Unwrap() error { return c.cause }
+
+// Retryable reports whether the error is classified as retryable.
+func (c *classified) Retryable() bool { return c.class.Retryable }
+
+// Temporary reports whether the error is classified as temporary.
+func (c *classified) Temporary() bool { return c.class.Temporary }
+
+// Timeout reports whether the error is classified as a timeout.
+func (c *classified) Timeout() bool { return c.class.Timeout }
+
+// PublicMessage returns the client-safe message of the error, if any.
+func (c *classified) PublicMessage() string { return c.class.PublicMessage }
+
+// Format formats the classified error like the error it classifies.
+func (c *classified) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			fmt.Fprintf(s, "%+v", c.cause)
+			return
+		}
+		fallthrough
+	case 's':
+		io.WriteString(s, c.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", c.Error())
+	}
+}
+
+// applyErrorClass derives the status and the public message of the
+// [wrapper] from the classification of err. An explicit StatusCode wins;
+// otherwise timeouts map to 504 Gateway Timeout and temporary or retryable
+// errors to 503 Service Unavailable.
+func (w *wrapper) applyErrorClass(err error) *wrapper {
+	class, ok := ClassOf(err)
+	if !ok {
+		return w
+	}
+	switch {
+	case class.StatusCode > 0:
+		w.WithStatusCode(class.StatusCode)
+	case class.Timeout:
+		w.WithHeader(GatewayTimeout)
+	case class.Temporary || class.Retryable:
+		w.WithHeader(ServiceUnavailable)
+	}
+	if strutil.IsNotEmpty(class.PublicMessage) {
+		w.WithMessage(class.PublicMessage)
+	}
+	return w
+}
+
+// anyError walks the chain (and tree) of err depth-first and reports whether
+// pred holds for any error in it.
+func anyError(err error, pred func(error) bool) bool {
+	return anyErrorDepth(err, pred, 0)
+}
+
+// anyErrorDepth is the depth-bounded implementation of anyError.
+func anyErrorDepth(err error, pred func(error) bool, depth int) bool {
+	if err == nil || depth > maxErrorTreeDepth {
+		return false
+	}
+	if pred(err) {
+		return true
+	}
+	for _, cause := range unwrapErrors(err) {
+		if anyErrorDepth(cause, pred, depth+1) {
+			return true
+		}
+	}
+	return false
+}
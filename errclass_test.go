@@ -0,0 +1,57 @@
+package replify_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/sivaosorg/replify"
+)
+
+func TestClassify_QueryHelpers(t *testing.T) {
+	t.Parallel()
+
+	base := replify.Temporary(replify.NewError("pool exhausted"))
+	err := replify.AppendError(replify.Public(base, "please retry"), "load user")
+
+	if !replify.IsRetryable(err) || !replify.IsTemporary(err) || replify.IsTimeout(err) {
+		t.Fatalf("unexpected classification: %+v", err)
+	}
+	if got := replify.PublicMessage(err); got != "please retry" {
+		t.Errorf("PublicMessage = %q", got)
+	}
+	if err.Error() != "load user: pool exhausted" {
+		t.Errorf("classification must not change the message, got %q", err.Error())
+	}
+	if !replify.IsTimeout(context.DeadlineExceeded) {
+		t.Error("expected context.DeadlineExceeded to be classified as a timeout")
+	}
+	if _, ok := replify.ClassOf(errors.New("plain")); ok {
+		t.Error("plain errors must not be classified")
+	}
+}
+
+func TestWithErrorAck_DerivesStatusAndMessage(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		err     error
+		status  int
+		message string
+	}{
+		{"timeout", replify.Timeout(errors.New("db deadline")), http.StatusGatewayTimeout, "failed"},
+		{"retryable", replify.Retryable(errors.New("conflict")), http.StatusServiceUnavailable, "failed"},
+		{"explicit", replify.Classify(errors.New("no such user"), replify.ErrorClassification{
+			StatusCode: http.StatusNotFound, PublicMessage: "user not found",
+		}), http.StatusNotFound, "user not found"},
+		{"plain", errors.New("boom"), http.StatusInternalServerError, "failed"},
+	}
+	for _, tc := range cases {
+		w := replify.WrapInternalServerError("failed", nil).WithErrorAck(tc.err)
+		if w.StatusCode() != tc.status || w.Message() != tc.message {
+			t.Errorf("%s: got %d %q, want %d %q", tc.name, w.StatusCode(), w.Message(), tc.status, tc.message)
+		}
+	}
+}
@@ -48,6 +48,43 @@ func (f Frame) MarshalText() ([]byte, error) {
 	return fmt.Appendf(nil, "%s %s:%d", name, f.file(), f.line()), nil
 }
 
+// Frame.StackFrame resolves the Frame into its serializable [StackFrame] form.
+//
+// Usage:
+// Converts the raw program counter into a function name, file path and line
+// number, suitable for JSON output.
+//
+// Example:
+//
+//	sf := frame.StackFrame()
+//	fmt.Println(sf.Func, sf.File, sf.Line)
+func (f Frame) StackFrame() StackFrame {
+	return StackFrame{
+		Func: f.name(),
+		File: f.file(),
+		Line: f.line(),
+	}
+}
+
+// StackTrace.Frames resolves every Frame of the StackTrace into its
+// serializable [StackFrame] form, preserving the innermost-first order.
+//
+// Example:
+//
+//	for _, sf := range trace.Frames() {
+//	    fmt.Printf("%s (%s:%d)\n", sf.Func, sf.File, sf.Line)
+//	}
+func (st StackTrace) Frames() []StackFrame {
+	if len(st) == 0 {
+		return nil
+	}
+	frames := make([]StackFrame, 0, len(st))
+	for _, f := range st {
+		frames = append(frames, f.StackFrame())
+	}
+	return frames
+}
+
 // Frame represents a program counter inside a stack frame.
 // For historical reasons, if Frame is interpreted as a uintptr,
 // its value represents the program counter + 1.
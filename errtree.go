@@ -0,0 +1,246 @@
+package replify
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/sivaosorg/replify/pkg/encoding"
+	"github.com/sivaosorg/replify/pkg/strutil"
+)
+
+// ErrorTree converts an error chain into a structured [ErrorNode] tree.
+//
+// The chain is walked through `Unwrap() []error` (e.g. `errors.Join`),
+// `Unwrap() error` and the legacy `Cause() error`. Transparent annotations
+// that only attach a stack trace without changing the message (such as
+// [NewErrorAck]) are folded into the node they annotate, so the tree mirrors
+// the logical chain rather than every wrapping layer. A node's Code is taken
+// from a `Code() string` or `Code() int` method when the error provides one.
+//
+// Parameters:
+//   - `err`: The error to convert; nil yields nil.
+//   - `withStack`: When true, nodes carrying a [StackTrace] include their frames.
+//
+// Returns:
+//   - The root [ErrorNode] of the tree, or nil if err is nil.
+//
+// Example:
+//
+//	err := errors.Join(replify.NewError("db down"), replify.NewError("cache down"))
+//	tree := replify.ErrorTree(replify.AppendError(err, "health check failed"), false)
+//	// tree.Message: "health check failed: db down\ncache down"
+//	// tree.Causes[0].Causes: [{"message":"db down",...}, {"message":"cache down",...}]
+func ErrorTree(err error, withStack bool) *ErrorNode {
+	if err == nil {
+		return nil
+	}
+	return newErrorNode(err, withStack, nil, 0)
+}
+
+// ParseErrorTree rebuilds an [ErrorNode] tree from its JSON representation,
+// as produced in the `errors` section of the envelope.
+//
+// Parameters:
+//   - `jsonStr`: The JSON document of a single error node.
+//
+// Returns:
+//   - The rebuilt tree, which implements `error` and `Unwrap() []error`.
+//   - An error if the document is empty or cannot be decoded.
+func ParseErrorTree(jsonStr string) (*ErrorNode, error) {
+	if strutil.IsEmpty(jsonStr) {
+		return nil, NewError("ParseErrorTree: JSON string is required")
+	}
+	var node ErrorNode
+	if err := encoding.UnmarshalJSONString(jsonStr, &node); err != nil {
+		return nil, NewErrorAckf(err, "ParseErrorTree: invalid error tree")
+	}
+	return &node, nil
+}
+
+// Error returns the message of the node, which is the message of the
+// original error it was built from.
+func (n *ErrorNode) Error() string {
+	if n == nil {
+		return ""
+	}
+	return n.Message
+}
+
+// Unwrap returns the causes of the node as errors, enabling `errors.Is`
+// and `errors.As` over a rebuilt tree.
+func (n *ErrorNode) Unwrap() []error {
+	if n == nil || len(n.Causes) == 0 {
+		return nil
+	}
+	causes := make([]error, 0, len(n.Causes))
+	for _, c := range n.Causes {
+		if c != nil {
+			causes = append(causes, c)
+		}
+	}
+	return causes
+}
+
+// WithErrorChain enables the structured `errors` section of the envelope for
+// the [wrapper] instance.
+//
+// By default the error stored on the [wrapper] is internal and never
+// rendered. Once enabled, [wrapper.Respond] and [wrapper.JSON] include the
+// full chain as an [ErrorNode] tree (see [ErrorTree]).
+//
+// Parameters:
+//   - `withStack`: When true, nodes include their stack frames as `{func,file,line}`.
+//
+// Returns:
+//   - A pointer to the modified [wrapper] instance (enabling method chaining).
+//
+// Example:
+//
+//	w := replify.WrapInternalServerError("failed", nil).
+//	    WithErrorAck(err).
+//	    WithErrorChain(false)
+//	// {"errors":{"message":"...","type":"*errors.errorString"}, ...}
+func (w *wrapper) WithErrorChain(withStack bool) *wrapper {
+	if !w.Available() {
+		return w
+	}
+	w.errorChain = true
+	w.errorChainStack = withStack
+	return w
+}
+
+// ErrorTree returns the structured [ErrorNode] tree of the error stored on
+// the [wrapper] instance, honoring the stack setting of [wrapper.WithErrorChain].
+//
+// Returns:
+//   - The root [ErrorNode], or nil if no error is present.
+func (w *wrapper) ErrorTree() *ErrorNode {
+	if !w.IsErrorPresent() {
+		return nil
+	}
+	return ErrorTree(w.errors, w.errorChainStack)
+}
+
+// IsErrorChainPresent reports whether the `errors` section is enabled and
+// an error is available to render.
+//
+// Returns:
+//   - `true` if [wrapper.WithErrorChain] was called and an error is present.
+func (w *wrapper) IsErrorChainPresent() bool {
+	return w.IsErrorPresent() && w.errorChain
+}
+
+// errorChainKey returns the part of the cache key contributed by the
+// `errors` section; it is empty unless the section is rendered.
+func (w *wrapper) errorChainKey() string {
+	if !w.IsErrorChainPresent() {
+		return ""
+	}
+	return strconv.FormatBool(w.errorChainStack) + ":" + w.errors.Error()
+}
+
+// newErrorNode recursively builds the node of err. `inherited` carries the
+// stack of a folded transparent annotation down to the node it annotates.
+func newErrorNode(err error, withStack bool, inherited StackTrace, depth int) *ErrorNode {
+	if node, ok := err.(*ErrorNode); ok {
+		return node
+	}
+	causes := unwrapErrors(err)
+
+	// Fold annotations that only add a stack trace (e.g. NewErrorAck).
+	if len(causes) == 1 && causes[0] != nil && causes[0].Error() == err.Error() && depth < maxErrorTreeDepth {
+		if len(inherited) == 0 {
+			inherited = stackTraceOf(err)
+		}
+		return newErrorNode(causes[0], withStack, inherited, depth+1)
+	}
+
+	node := &ErrorNode{
+		Message: err.Error(),
+		Type:    fmt.Sprintf("%T", err),
+		Code:    errorCode(err),
+	}
+	if withStack {
+		st := stackTraceOf(err)
+		if len(st) == 0 {
+			st = inherited
+		}
+		node.Stack = st.Frames()
+	}
+	if depth >= maxErrorTreeDepth {
+		return node
+	}
+	for _, cause := range causes {
+		if cause != nil {
+			node.Causes = append(node.Causes, newErrorNode(cause, withStack, nil, depth+1))
+		}
+	}
+	return node
+}
+
+// errorNodeFrom converts a decoded JSON value (as found in an envelope) into
+// an [ErrorNode]. It returns nil when the value is not a valid error node.
+func errorNodeFrom(v any) *ErrorNode {
+	if v == nil {
+		return nil
+	}
+	node, err := ParseErrorTree(encoding.JSON(v))
+	if err != nil || (strutil.IsEmpty(node.Message) && len(node.Causes) == 0) {
+		return nil
+	}
+	return node
+}
+
+// unwrapErrors returns the direct causes of err, preferring the multi-error
+// `Unwrap() []error` form, then `Unwrap() error`, then `Cause() error`.
+func unwrapErrors(err error) []error {
+	switch e := err.(type) {
+	case interface{ Unwrap() []error }:
+		return e.Unwrap()
+	case interface{ Unwrap() error }:
+		if next := e.Unwrap(); next != nil && next != err {
+			return []error{next}
+		}
+	case interface{ Cause() error }:
+		if next := e.Cause(); next != nil && next != err {
+			return []error{next}
+		}
+	}
+	return nil
+}
+
+// stackTraceOf returns the stack trace carried by err itself, if any.
+func stackTraceOf(err error) StackTrace {
+	if st, ok := err.(interface{ StackTrace() StackTrace }); ok {
+		return st.StackTrace()
+	}
+	return nil
+}
+
+// errorCode returns the application code exposed by err through a
+// `Code() string` or `Code() int` method, or an empty string.
+func errorCode(err error) string {
+	switch e := err.(type) {
+	case interface{ Code() string }:
+		return e.Code()
+	case interface{ Code() int }:
+		return strconv.Itoa(e.Code())
+	}
+	return ""
+}
+
+// hasErrorStack reports whether any node of the tree carries stack frames.
+func hasErrorStack(node *ErrorNode) bool {
+	if node == nil {
+		return false
+	}
+	if len(node.Stack) > 0 {
+		return true
+	}
+	for _, c := range node.Causes {
+		if hasErrorStack(c) {
+			return true
+		}
+	}
+	return false
+}
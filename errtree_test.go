@@ -0,0 +1,69 @@
+package replify_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/sivaosorg/replify"
+)
+
+func TestErrorTree_JoinAndWrap(t *testing.T) {
+	t.Parallel()
+
+	joined := errors.Join(replify.NewError("db down"), io.ErrUnexpectedEOF)
+	err := replify.AppendErrorAck(joined, "health check failed")
+
+	tree := replify.ErrorTree(err, true)
+	if tree == nil {
+		t.Fatal("expected a tree")
+	}
+	if tree.Message != err.Error() {
+		t.Errorf("root message = %q, want %q", tree.Message, err.Error())
+	}
+	if len(tree.Stack) == 0 {
+		t.Error("expected the root node to inherit the AppendErrorAck stack")
+	}
+	if len(tree.Causes) != 1 || len(tree.Causes[0].Causes) != 2 {
+		t.Fatalf("expected the joined error to expose two causes, got %+v", tree.Causes)
+	}
+	if got := tree.Causes[0].Causes[1].Message; got != io.ErrUnexpectedEOF.Error() {
+		t.Errorf("second cause = %q", got)
+	}
+}
+
+func TestErrorChain_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	w := replify.WrapInternalServerError("failed", nil).
+		WithErrorAck(errors.Join(errors.New("a"), errors.New("b"))).
+		WithErrorChain(false)
+
+	body := w.JSON()
+	if !strings.Contains(body, `"errors"`) {
+		t.Fatalf("expected an errors section, got %s", body)
+	}
+
+	parsed, err := replify.UnwrapJSON(body)
+	if err != nil {
+		t.Fatalf("UnwrapJSON: %v", err)
+	}
+	tree := parsed.ErrorTree()
+	if tree == nil || tree.Error() != "a\nb" || len(tree.Unwrap()) != 2 {
+		t.Fatalf("unexpected rebuilt tree: %+v", tree)
+	}
+	var node *replify.ErrorNode
+	if !errors.As(parsed.Cause(), &node) {
+		t.Error("expected the rebuilt error to be an *ErrorNode")
+	}
+}
+
+func TestErrorChain_HiddenByDefault(t *testing.T) {
+	t.Parallel()
+
+	w := replify.WrapInternalServerError("failed", nil).WithErrorAck(errors.New("secret"))
+	if strings.Contains(w.JSON(), "secret") {
+		t.Error("error chain must not be rendered unless enabled")
+	}
+}
@@ -0,0 +1,247 @@
+package replify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sivaosorg/replify/pkg/strutil"
+	"github.com/sivaosorg/replify/pkg/sysx"
+)
+
+// Register adds a check to the registry.
+//
+// Parameters:
+//   - `check`: The check; Name and Check are required and the name must be unique.
+//
+// Returns:
+//   - An error if the check is incomplete or its name is already registered.
+//
+// Example:
+//
+//	health := replify.NewHealthRegistry()
+//	health.Register(replify.HealthCheck{
+//	    Name:     "postgres",
+//	    Check:    func(ctx context.Context) error { return db.PingContext(ctx) },
+//	    Timeout:  2 * time.Second,
+//	    Critical: true,
+//	    Kinds:    []replify.HealthCheckKind{replify.HealthKindReadiness},
+//	})
+//	mux.Handle("/healthz", health.Handler(""))
+//	mux.Handle("/readyz", health.Handler(replify.HealthKindReadiness))
+func (r *HealthRegistry) Register(check HealthCheck) error {
+	if strutil.IsEmpty(check.Name) {
+		return NewError("Register: health check name is required")
+	}
+	if check.Check == nil {
+		return NewErrorf("Register: health check %q has no check function", check.Name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.checks {
+		if c.Name == check.Name {
+			return NewErrorf("Register: health check %q is already registered", check.Name)
+		}
+	}
+	r.checks = append(r.checks, check)
+	return nil
+}
+
+// Unregister removes the check with the given name.
+//
+// Parameters:
+//   - `name`: The check name.
+//
+// Returns:
+//   - `true` if a check was removed.
+func (r *HealthRegistry) Unregister(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, c := range r.checks {
+		if c.Name == name {
+			r.checks = slices.Delete(r.checks, i, i+1)
+			return true
+		}
+	}
+	return false
+}
+
+// Run executes, concurrently, every check belonging to the probe kind and
+// aggregates their results.
+//
+// Each check runs with its own timeout; a check that does not return in
+// time (or panics) is reported down. The report is up when every check
+// passes, degraded when only non-critical checks fail and down when a
+// critical check fails. Results keep the registration order.
+//
+// Parameters:
+//   - `ctx`: The parent context of the checks.
+//   - `kind`: The probe; empty runs every check.
+//
+// Returns:
+//   - The aggregated [HealthReport].
+func (r *HealthRegistry) Run(ctx context.Context, kind HealthCheckKind) HealthReport {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	start := time.Now()
+
+	r.mu.RLock()
+	checks := make([]HealthCheck, 0, len(r.checks))
+	for _, c := range r.checks {
+		if kind == "" || len(c.Kinds) == 0 || slices.Contains(c.Kinds, kind) {
+			checks = append(checks, c)
+		}
+	}
+	timeout := r.defaultTimeout
+	r.mu.RUnlock()
+
+	results := make([]HealthCheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c HealthCheck) {
+			defer wg.Done()
+			results[i] = runHealthCheck(ctx, c, timeout)
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := HealthReport{
+		Status:     HealthUp,
+		Kind:       kind,
+		Checks:     results,
+		System:     CollectSystemInfo(),
+		CheckedAt:  start,
+		DurationMs: durationMs(time.Since(start)),
+	}
+	for _, res := range results {
+		if res.Status == HealthUp {
+			continue
+		}
+		if res.Critical {
+			report.Status = HealthDown
+			break
+		}
+		report.Status = HealthDegraded
+	}
+	return report
+}
+
+// Handler returns an `http.Handler` that runs the checks of the probe kind
+// and writes the [HealthReport] as a replify envelope: 200 OK when the
+// report is up or degraded, 503 Service Unavailable when it is down.
+//
+// Parameters:
+//   - `kind`: The probe; empty runs every check.
+//
+// Returns:
+//   - The probe handler.
+func (r *HealthRegistry) Handler(kind HealthCheckKind) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		report := r.Run(req.Context(), kind)
+		_ = WrapHealth(report).WithPath(req.URL.Path).WriteHTTP(rw)
+	})
+}
+
+// HealthHandler returns the handler running every check. See [HealthRegistry.Handler].
+func (r *HealthRegistry) HealthHandler() http.Handler {
+	return r.Handler("")
+}
+
+// ReadinessHandler returns the handler running the readiness checks. See [HealthRegistry.Handler].
+func (r *HealthRegistry) ReadinessHandler() http.Handler {
+	return r.Handler(HealthKindReadiness)
+}
+
+// LivenessHandler returns the handler running the liveness checks. See [HealthRegistry.Handler].
+func (r *HealthRegistry) LivenessHandler() http.Handler {
+	return r.Handler(HealthKindLiveness)
+}
+
+// WrapHealth wraps a [HealthReport] in a replify envelope with the report as
+// data: 200 OK when the report is up or degraded, 503 Service Unavailable
+// when it is down. Probe responses are never cached.
+//
+// Parameters:
+//   - `report`: The health report.
+//
+// Returns:
+//   - A pointer to a new [wrapper] instance.
+func WrapHealth(report HealthReport) *wrapper {
+	var w *wrapper
+	if report.Status == HealthDown {
+		w = WrapServiceUnavailable("Service is unhealthy", report)
+	} else {
+		w = WrapOk(fmt.Sprintf("Service is %s", report.Status), report)
+	}
+	return w.WithHTTPHeader(HeaderCacheControl, "no-store")
+}
+
+// CollectSystemInfo gathers the process information reported by health probes.
+//
+// Returns:
+//   - The [HealthSystemInfo] of the current process.
+func CollectSystemInfo() HealthSystemInfo {
+	mem := sysx.MemStats()
+	hostname, _ := sysx.Hostname()
+	return HealthSystemInfo{
+		Hostname:     hostname,
+		GoVersion:    sysx.GoVersion(),
+		PID:          sysx.PID(),
+		NumCPU:       sysx.NumCPU(),
+		NumGoroutine: sysx.NumGoroutine(),
+		HeapAlloc:    mem.HeapAlloc,
+		HeapSys:      mem.HeapSys,
+		Sys:          mem.Sys,
+		NumGC:        mem.NumGC,
+	}
+}
+
+// runHealthCheck runs one check under its timeout, converting panics and
+// deadline overruns into failures.
+func runHealthCheck(parent context.Context, c HealthCheck, timeout time.Duration) HealthCheckResult {
+	if c.Timeout > 0 {
+		timeout = c.Timeout
+	}
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- NewErrorf("health check panicked: %v", p)
+			}
+		}()
+		done <- c.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = NewErrorf("health check timed out after %s", timeout)
+	}
+
+	res := HealthCheckResult{
+		Name:      c.Name,
+		Status:    HealthUp,
+		Critical:  c.Critical,
+		LatencyMs: durationMs(time.Since(start)),
+	}
+	if err != nil {
+		res.Status = HealthDown
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// durationMs converts a duration to fractional milliseconds.
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
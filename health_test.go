@@ -0,0 +1,69 @@
+package replify_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sivaosorg/replify"
+	"github.com/sivaosorg/replify/pkg/fj"
+)
+
+func TestHealthRegistry(t *testing.T) {
+	t.Parallel()
+
+	health := replify.NewHealthRegistry()
+	ok := func(context.Context) error { return nil }
+	mustRegister := func(c replify.HealthCheck) {
+		t.Helper()
+		if err := health.Register(c); err != nil {
+			t.Fatalf("Register(%s): %v", c.Name, err)
+		}
+	}
+	mustRegister(replify.HealthCheck{Name: "process", Check: ok, Kinds: []replify.HealthCheckKind{replify.HealthKindLiveness}})
+	mustRegister(replify.HealthCheck{Name: "cache", Check: func(context.Context) error { return errors.New("miss") }})
+	mustRegister(replify.HealthCheck{
+		Name:     "db",
+		Critical: true,
+		Timeout:  20 * time.Millisecond,
+		Kinds:    []replify.HealthCheckKind{replify.HealthKindReadiness},
+		Check: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+	if err := health.Register(replify.HealthCheck{Name: "db", Check: ok}); err == nil {
+		t.Error("expected an error for a duplicate check")
+	}
+
+	if r := health.Run(context.Background(), replify.HealthKindLiveness); r.Status != replify.HealthDegraded || len(r.Checks) != 2 {
+		t.Errorf("liveness report = %s with %d checks, want degraded with 2", r.Status, len(r.Checks))
+	}
+
+	rec := httptest.NewRecorder()
+	health.ReadinessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	body := rec.Body.String()
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503: %s", rec.Code, body)
+	}
+	if got := fj.Get(body, "data.status").String(); got != string(replify.HealthDown) {
+		t.Errorf("data.status = %q, want down", got)
+	}
+	if got := fj.Get(body, "data.checks.1.name").String(); got != "db" {
+		t.Errorf("checks must keep registration order, got %q", got)
+	}
+	if fj.Get(body, "data.checks.1.error").String() == "" || fj.Get(body, "data.system.go_version").String() == "" {
+		t.Errorf("missing check error or system info: %s", body)
+	}
+
+	health.Unregister("db")
+	health.Unregister("cache")
+	rec = httptest.NewRecorder()
+	health.HealthHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("status = %d, Cache-Control = %q", rec.Code, rec.Header().Get("Cache-Control"))
+	}
+}
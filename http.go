@@ -0,0 +1,269 @@
+package replify
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/sivaosorg/replify/pkg/strutil"
+)
+
+// WithHTTPHeader sets a transport-level HTTP header on the [wrapper] instance.
+//
+// Transport headers are not part of the JSON envelope; they are emitted by
+// [wrapper.WriteHTTP] alongside the serialized body. Setting a header that
+// already exists replaces all of its previous values.
+//
+// Parameters:
+//   - `key`: The header name, e.g. [HeaderCacheControl].
+//   - `value`: The header value.
+//
+// Returns:
+//   - A pointer to the modified [wrapper] instance (enabling method chaining).
+//
+// Example:
+//
+//	w := replify.New().
+//	    WithHTTPHeader(replify.HeaderCacheControl, "no-store")
+func (w *wrapper) WithHTTPHeader(key HeaderType, value string) *wrapper {
+	if !w.Available() {
+		return w
+	}
+	if w.httpHeaders == nil {
+		w.httpHeaders = make(http.Header)
+	}
+	w.httpHeaders.Set(key.String(), value)
+	return w
+}
+
+// AddHTTPHeader appends a value to a transport-level HTTP header on the [wrapper] instance.
+//
+// Unlike [wrapper.WithHTTPHeader], existing values for the same header are preserved.
+//
+// Parameters:
+//   - `key`: The header name.
+//   - `value`: The header value to append.
+//
+// Returns:
+//   - A pointer to the modified [wrapper] instance (enabling method chaining).
+func (w *wrapper) AddHTTPHeader(key HeaderType, value string) *wrapper {
+	if !w.Available() {
+		return w
+	}
+	if w.httpHeaders == nil {
+		w.httpHeaders = make(http.Header)
+	}
+	w.httpHeaders.Add(key.String(), value)
+	return w
+}
+
+// HTTPHeaders returns a copy of the transport-level HTTP headers of the [wrapper] instance.
+//
+// Returns:
+//   - A cloned `http.Header`, or nil if no transport header has been set.
+func (w *wrapper) HTTPHeaders() http.Header {
+	if !w.Available() || w.httpHeaders == nil {
+		return nil
+	}
+	return w.httpHeaders.Clone()
+}
+
+// HTTPHeader returns the first value of a transport-level HTTP header.
+//
+// Parameters:
+//   - `key`: The header name.
+//
+// Returns:
+//   - The header value, or an empty string if it is not set.
+func (w *wrapper) HTTPHeader(key HeaderType) string {
+	if !w.Available() || w.httpHeaders == nil {
+		return ""
+	}
+	return w.httpHeaders.Get(key.String())
+}
+
+// ETag returns the entity tag of the [wrapper] instance: the ETag transport
+// header when one is set, otherwise a strong tag derived from
+// [wrapper.Hash256]. Range requests served from a [Dump] of the [wrapper]
+// validate If-Range against it.
+//
+// Returns:
+//   - The quoted entity tag, or an empty string if the [wrapper] is not available.
+func (w *wrapper) ETag() string {
+	if !w.Available() {
+		return ""
+	}
+	if etag := w.HTTPHeader(HeaderETag); strutil.IsNotEmpty(etag) {
+		return etag
+	}
+	if hash := w.Hash256(); strutil.IsNotEmpty(hash) {
+		return strconv.Quote(hash)
+	}
+	return ""
+}
+
+// WriteHTTP writes the [wrapper] instance to an `http.ResponseWriter`.
+//
+// The transport headers set through [wrapper.WithHTTPHeader] (and the
+// builders that rely on it, such as [wrapper.WithRateLimit] or
+// [wrapper.WithRetryAfter]) are copied to the response, Content-Type
+// defaults to application/json, the status code of the [wrapper] is written
+// (200 if unset) and finally the JSON envelope is written as the body.
+// No body is written for 204 No Content and 304 Not Modified responses.
+// The body is rendered with the envelope layout of [wrapper.EnvelopeVersion].
+// A body set with [wrapper.WithBodySeq] is encoded as it is produced (see
+// [wrapper.EncodeTo]), with the current layout and no Content-Encoding.
+// The response policies (see [wrapper.WithPolicies] and
+// [SetResponsePolicies]) are applied to a copy before writing. WriteHTTP
+// has no request to resolve them from: it matches them against the path
+// set with [wrapper.WithPath] and does not check their methods, so without
+// a path only the policies whose route matches the empty path (e.g. "*")
+// apply. Use [wrapper.ServeHTTP] to resolve the route policies from the
+// request method and path.
+//
+// Parameters:
+//   - `rw`: The destination response writer.
+//
+// Returns:
+//   - An error if the [wrapper] or the writer is nil, or if writing the body fails.
+//
+// Example:
+//
+//	func handler(rw http.ResponseWriter, r *http.Request) {
+//	    replify.WrapOk("ok", data).WithPath(r.URL.Path).WriteHTTP(rw)
+//	}
+func (w *wrapper) WriteHTTP(rw http.ResponseWriter) error {
+	if !w.Available() {
+		return NewError("WriteHTTP: wrapper is not available")
+	}
+	pw := w.withResolvedPolicy("", w.path)
+	return pw.writeHTTP(rw, pw.EnvelopeVersion(), "")
+}
+
+// ServeHTTP implements `http.Handler`, allowing a prepared [wrapper] to be
+// mounted directly on a mux. It behaves like [wrapper.WriteHTTP], except
+// that a `version` parameter of the Accept header (e.g.
+// "application/json; version=1") selects the envelope layout and that the
+// response policies are resolved from the request method and path. A
+// version that no migration leads to is answered with 406 Not Acceptable
+// and an error envelope of the current layout.
+//
+// Parameters:
+//   - `rw`: The destination response writer.
+//   - `r`: The incoming request.
+func (w *wrapper) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if !w.Available() {
+		return
+	}
+	pw := w.withResolvedPolicy(requestRoute(r))
+	var accept string
+	if r != nil {
+		accept = r.Header.Get(HeaderAcceptEncoding.String())
+	}
+	_ = pw.writeHTTP(rw, pw.requestEnvelopeVersion(r), accept)
+}
+
+// writeHTTP writes the [wrapper] to rw using the given envelope layout version.
+func (w *wrapper) writeHTTP(rw http.ResponseWriter, version string, acceptEncoding string) error {
+	if !w.Available() {
+		return NewError("WriteHTTP: wrapper is not available")
+	}
+	if rw == nil {
+		return NewError("WriteHTTP: response writer is nil")
+	}
+	if w.bodySeq != nil {
+		return w.writeHTTPSeq(rw, version, acceptEncoding)
+	}
+	body := w.JSON()
+	if version != EnvelopeVersionCurrent {
+		var err error
+		if body, err = w.JSONVersion(version); err != nil {
+			return w.writeNotAcceptable(rw, err)
+		}
+	}
+	h := rw.Header()
+	for key, values := range w.httpHeaders {
+		h[key] = append([]string(nil), values...)
+	}
+	if h.Get(HeaderContentType.String()) == "" {
+		h.Set(HeaderContentType.String(), string(MediaTypeApplicationJSON))
+	}
+	code := w.StatusCode()
+	if code <= 0 {
+		code = http.StatusOK
+	}
+	if code != http.StatusNoContent && code != http.StatusNotModified {
+		body = w.encodeBody(h, body, acceptEncoding)
+	}
+	rw.WriteHeader(code)
+	if code == http.StatusNoContent || code == http.StatusNotModified {
+		return nil
+	}
+	_, err := io.WriteString(rw, body)
+	return err
+}
+
+// writeHTTPSeq writes a [wrapper] whose body is set with [wrapper.WithBodySeq],
+// encoding the envelope as the body is produced.
+//
+// Envelope migrations transform the whole document, which a streamed body
+// never is, so a layout version other than [EnvelopeVersionCurrent] is
+// answered with 406 Not Acceptable. The negotiated Content-Encoding is
+// applied as the envelope is written, whatever its size.
+func (w *wrapper) writeHTTPSeq(rw http.ResponseWriter, version string, acceptEncoding string) error {
+	if strutil.IsNotEmpty(version) && version != EnvelopeVersionCurrent {
+		return w.writeNotAcceptable(rw, NewErrorf("envelope version %q cannot be streamed", version))
+	}
+	h := rw.Header()
+	for key, values := range w.httpHeaders {
+		h[key] = append([]string(nil), values...)
+	}
+	if h.Get(HeaderContentType.String()) == "" {
+		h.Set(HeaderContentType.String(), string(MediaTypeApplicationJSON))
+	}
+	code := w.StatusCode()
+	if code <= 0 {
+		code = http.StatusOK
+	}
+	if code == http.StatusNoContent || code == http.StatusNotModified {
+		rw.WriteHeader(code)
+		return nil
+	}
+	var dst io.Writer = rw
+	var zw io.WriteCloser
+	if w.contentEncoding {
+		h.Add(HeaderVary.String(), HeaderAcceptEncoding.String())
+		if name := NegotiateEncoding(acceptEncoding); strutil.IsNotEmpty(name) && h.Get(HeaderContentEncoding.String()) == "" {
+			if codec, options, ok := LookupCodec(name); ok {
+				var err error
+				if zw, err = codec.NewWriter(rw, options); err == nil {
+					h.Set(HeaderContentEncoding.String(), name)
+					h.Del("Content-Length")
+					dst = zw
+				}
+			}
+		}
+	}
+	rw.WriteHeader(code)
+	if err := w.EncodeTo(dst); err != nil {
+		return err
+	}
+	if zw != nil {
+		return zw.Close()
+	}
+	return nil
+}
+
+// writeNotAcceptable answers 406 Not Acceptable with an error envelope when
+// the [wrapper] cannot be rendered as requested, and returns cause.
+func (w *wrapper) writeNotAcceptable(rw http.ResponseWriter, cause error) error {
+	nw := New().
+		WithStatusCode(http.StatusNotAcceptable).
+		WithHeader(NotAcceptable).
+		WithPath(w.path).
+		WithMessage(cause.Error())
+	if err := nw.writeHTTP(rw, EnvelopeVersionCurrent, ""); err != nil {
+		return err
+	}
+	return cause
+}
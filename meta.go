@@ -1179,6 +1179,30 @@ func (m *meta) CustomFields() map[string]any {
 	return m.customFields
 }
 
+// DeltaCnt retrieves the delta count from the [meta] instance.
+//
+// Returns:
+//   - The number of changes recorded by normalization, transformation or envelope migration.
+//   - `0` if the [meta] instance is unavailable.
+func (m *meta) DeltaCnt() int {
+	if !m.Available() {
+		return 0
+	}
+	return m.deltaCnt
+}
+
+// DeltaValue retrieves the delta value from the [meta] instance.
+//
+// Returns:
+//   - The magnitude of change recorded for the payload.
+//   - `0` if the [meta] instance is unavailable.
+func (m *meta) DeltaValue() float64 {
+	if !m.Available() {
+		return 0
+	}
+	return m.deltaValue
+}
+
 // IncreaseDeltaCnt increments the delta count for the [meta] instance by 1.
 // Represents an additional change introduced by payload normalization or transformation.
 //
@@ -338,3 +338,125 @@ func WithInjectStackTrace() ROption {
 		w.InjectStackTrace()
 	}
 }
+
+// WithHTTPHeader returns an [ROption] that sets a transport-level HTTP header.
+//
+// This is the functional-option equivalent of [wrapper.WithHTTPHeader].
+func WithHTTPHeader(key HeaderType, value string) ROption {
+	return func(w *wrapper) {
+		w.WithHTTPHeader(key, value)
+	}
+}
+
+// WithRateLimit returns an [ROption] that sets the RateLimit-* headers and
+// mirrors them into [meta].
+//
+// This is the functional-option equivalent of [wrapper.WithRateLimit].
+func WithRateLimit(limit, remaining int, reset time.Duration) ROption {
+	return func(w *wrapper) {
+		w.WithRateLimit(limit, remaining, reset)
+	}
+}
+
+// WithRetryAfter returns an [ROption] that sets the Retry-After header in
+// seconds and mirrors it into [meta].
+//
+// This is the functional-option equivalent of [wrapper.WithRetryAfter].
+func WithRetryAfter(delay time.Duration) ROption {
+	return func(w *wrapper) {
+		w.WithRetryAfter(delay)
+	}
+}
+
+// WithDeprecation returns an [ROption] that sets the Deprecation header and
+// mirrors it into [meta].
+//
+// This is the functional-option equivalent of [wrapper.WithDeprecation].
+func WithDeprecation(at time.Time) ROption {
+	return func(w *wrapper) {
+		w.WithDeprecation(at)
+	}
+}
+
+// WithSunset returns an [ROption] that sets the Sunset header and mirrors it
+// into [meta].
+//
+// This is the functional-option equivalent of [wrapper.WithSunset].
+func WithSunset(at time.Time) ROption {
+	return func(w *wrapper) {
+		w.WithSunset(at)
+	}
+}
+
+// WithErrorChain returns an [ROption] that renders the stored error chain in
+// the `errors` section of the envelope.
+//
+// This is the functional-option equivalent of [wrapper.WithErrorChain].
+func WithErrorChain(withStack bool) ROption {
+	return func(w *wrapper) {
+		w.WithErrorChain(withStack)
+	}
+}
+
+// WithStackTraceOptions returns an [ROption] that captures the call stack
+// filtered by the given [StackOptions] into the debug key "stack_trace".
+// The stack is captured when the option is created, so its top frame is the
+// caller of WithStackTraceOptions rather than the code applying the option.
+//
+// This is the functional-option equivalent of [wrapper.WithStackTraceOptions].
+func WithStackTraceOptions(opts *StackOptions) ROption {
+	stack := callersN(3, 128)
+	return func(w *wrapper) {
+		w.WithDebuggingKV("stack_trace", stack.FramesWith(opts))
+	}
+}
+
+// WithEnvelopeVersion returns an [ROption] that selects the envelope layout
+// version used when the [wrapper] is written to an HTTP response.
+//
+// This is the functional-option equivalent of [wrapper.WithEnvelopeVersion].
+func WithEnvelopeVersion(version string) ROption {
+	return func(w *wrapper) {
+		w.WithEnvelopeVersion(version)
+	}
+}
+
+// WithCanonicalJSON returns an [ROption] that enables or disables RFC 8785
+// canonical JSON output and hashing.
+//
+// This is the functional-option equivalent of [wrapper.WithCanonicalJSON].
+func WithCanonicalJSON(enabled bool) ROption {
+	return func(w *wrapper) {
+		w.WithCanonicalJSON(enabled)
+	}
+}
+
+// WithPolicies returns an [ROption] that attaches a [PolicySet] applied when
+// the [wrapper] is written over HTTP.
+//
+// This is the functional-option equivalent of [wrapper.WithPolicies].
+func WithPolicies(set *PolicySet) ROption {
+	return func(w *wrapper) {
+		w.WithPolicies(set)
+	}
+}
+
+// WithCompressionCodec returns an [ROption] that selects the registered
+// codec used by [wrapper.CompressSafe].
+//
+// This is the functional-option equivalent of [wrapper.WithCompressionCodec].
+func WithCompressionCodec(name string) ROption {
+	return func(w *wrapper) {
+		w.WithCompressionCodec(name)
+	}
+}
+
+// WithContentEncoding returns an [ROption] that enables Accept-Encoding
+// negotiation for bodies of at least minSize bytes.
+//
+// This is the functional-option equivalent of [wrapper.WithContentEncoding].
+func WithContentEncoding(minSize int) ROption {
+	return func(w *wrapper) {
+		w.WithContentEncoding(minSize)
+	}
+}
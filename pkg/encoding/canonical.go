@@ -0,0 +1,252 @@
+package encoding
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Canonicalize rewrites a JSON document into its canonical form as defined
+// by RFC 8785 (JSON Canonicalization Scheme).
+//
+// The canonical form has no insignificant whitespace, object members sorted
+// by the UTF-16 code units of their names, numbers serialized like
+// ECMAScript's Number.prototype.toString (IEEE 754 double precision, shortest
+// round-trip representation) and strings escaped minimally: only `"`, `\`
+// and control characters are escaped, using the short forms where they
+// exist. Two documents that are logically equal always canonicalize to the
+// same bytes, which makes the output suitable for hashing and signing.
+//
+// Parameters:
+//   - `data`: The JSON document.
+//
+// Returns:
+//   - The canonical JSON bytes.
+//   - An error if data is empty, not valid JSON, or contains a number that
+//     cannot be represented as a finite IEEE 754 double.
+//
+// Example:
+//
+//	out, _ := encoding.Canonicalize([]byte(`{"b": 1.0E2, "a": "é"}`))
+//	// out: {"a":"é","b":100}
+func Canonicalize(data []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, ErrEmptyInput
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJSON, err)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("%w: unexpected data after top-level value", ErrInvalidJSON)
+	}
+	var buf bytes.Buffer
+	if err := writeCanonical(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// CanonicalJSON marshals a Go value and returns its RFC 8785 canonical form.
+// See [Canonicalize] for the rules applied.
+//
+// Parameters:
+//   - `v`: The Go value to be converted to canonical JSON.
+//
+// Returns:
+//   - The canonical JSON bytes.
+//   - An error if the value cannot be marshalled or canonicalized.
+//
+// Example:
+//
+//	out, err := encoding.CanonicalJSON(map[string]any{"b": 2, "a": 1})
+//	// out: {"a":1,"b":2}
+func CanonicalJSON(v any) ([]byte, error) {
+	s, err := safeMarshalJSONString(v, false)
+	if err != nil {
+		return nil, err
+	}
+	return Canonicalize([]byte(s))
+}
+
+// CanonicalJSONString is like [CanonicalJSON] but returns a string.
+//
+// Parameters:
+//   - `v`: The Go value to be converted to canonical JSON.
+//
+// Returns:
+//   - The canonical JSON string.
+//   - An error if the value cannot be marshalled or canonicalized.
+func CanonicalJSONString(v any) (string, error) {
+	b, err := CanonicalJSON(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// FormatES6Number formats a float64 the way ECMAScript's
+// Number.prototype.toString does, as required by RFC 8785 §3.2.2.3.
+//
+// Parameters:
+//   - `f`: The number to format.
+//
+// Returns:
+//   - The formatted number.
+//   - [ErrNonFiniteFloat] if f is NaN or ±Inf.
+//
+// Example:
+//
+//	s, _ := encoding.FormatES6Number(1e21) // "1e+21"
+//	s, _ = encoding.FormatES6Number(0.000001) // "0.000001"
+func FormatES6Number(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", ErrNonFiniteFloat
+	}
+	if f == 0 {
+		return "0", nil // also covers -0
+	}
+	var sign string
+	if f < 0 {
+		sign, f = "-", -f
+	}
+
+	// Shortest round-trip digits and exponent: d.ddd e±x.
+	mantissa, exp, _ := strings.Cut(strconv.FormatFloat(f, 'e', -1, 64), "e")
+	digits := strings.Replace(mantissa, ".", "", 1)
+	x, _ := strconv.Atoi(exp)
+	k, n := len(digits), x+1 // value = 0.digits × 10^n
+
+	switch {
+	case k <= n && n <= 21:
+		return sign + digits + strings.Repeat("0", n-k), nil
+	case 0 < n && n <= 21:
+		return sign + digits[:n] + "." + digits[n:], nil
+	case -6 < n && n <= 0:
+		return sign + "0." + strings.Repeat("0", -n) + digits, nil
+	}
+	s := digits[:1]
+	if k > 1 {
+		s += "." + digits[1:]
+	}
+	if n-1 >= 0 {
+		return sign + s + "e+" + strconv.Itoa(n-1), nil
+	}
+	return sign + s + "e" + strconv.Itoa(n-1), nil
+}
+
+// writeCanonical appends the canonical form of a decoded JSON value.
+func writeCanonical(buf *bytes.Buffer, v any) error {
+	switch t := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(t))
+	case json.Number:
+		f, err := strconv.ParseFloat(string(t), 64)
+		if err != nil {
+			return fmt.Errorf("%w: number %s", ErrNonFiniteFloat, t)
+		}
+		s, err := FormatES6Number(f)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(s)
+	case string:
+		writeCanonicalString(buf, t)
+	case []any:
+		buf.WriteByte('[')
+		for i, e := range t {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonical(buf, e); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]any:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return lessUTF16(keys[i], keys[j]) })
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeCanonicalString(buf, k)
+			buf.WriteByte(':')
+			if err := writeCanonical(buf, t[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("%w: %T", ErrUnsupportedValue, v)
+	}
+	return nil
+}
+
+// writeCanonicalString appends a JSON string with the minimal escaping
+// required by RFC 8785 §3.2.2.2.
+func writeCanonicalString(buf *bytes.Buffer, s string) {
+	const hex = "0123456789abcdef"
+	buf.WriteByte('"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			buf.WriteRune(r) // invalid sequences become U+FFFD
+			i += size
+			continue
+		}
+		switch c {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if c < 0x20 {
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hex[c>>4])
+				buf.WriteByte(hex[c&0xf])
+			} else {
+				buf.WriteByte(c)
+			}
+		}
+		i++
+	}
+	buf.WriteByte('"')
+}
+
+// lessUTF16 orders two strings by their UTF-16 code units, as required for
+// object member names by RFC 8785 §3.2.3.
+func lessUTF16(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
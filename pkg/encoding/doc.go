@@ -31,6 +31,17 @@
 // JSONPretty and JSONPrettyToken produce indented output. The Token variants
 // return an explicit error instead of an empty string sentinel.
 //
+// # Canonical JSON
+//
+// Canonicalize, CanonicalJSON and CanonicalJSONString produce the RFC 8785
+// (JCS) canonical form of a document: no whitespace, object members sorted
+// by UTF-16 code units, ECMAScript number serialization (FormatES6Number)
+// and minimal string escaping. Logically equal documents canonicalize to
+// identical bytes, so the output can be hashed or signed across services:
+//
+//	out, _ := encoding.CanonicalJSON(map[string]any{"b": 1.0, "a": "<x>"})
+//	// out: {"a":"<x>","b":1}
+//
 // # Pretty Printing
 //
 // Pretty and Color format an existing JSON byte slice with configurable
@@ -1349,3 +1349,72 @@ func TestJSON_NilChan(t *testing.T) {
 		t.Errorf("JSON(nil chan) = %q; want %q", got, "null")
 	}
 }
+
+// ///////////////////////////
+// Section: Canonicalize()
+// ///////////////////////////
+
+// TestCanonicalize_SortsAndCompacts verifies key ordering by UTF-16 code units,
+// whitespace removal and minimal escaping.
+func TestCanonicalize_SortsAndCompacts(t *testing.T) {
+	in := "{\"b\": [1.0, true, null], \"a\": \"<\\u00e9>\\n\\u001f\", \"\\ud83d\\ude00\": 1, \"\\ufb33\": 2}"
+	got, err := encoding.Canonicalize([]byte(in))
+	if err != nil {
+		t.Fatalf("Canonicalize unexpected error: %v", err)
+	}
+	want := "{\"a\":\"<\u00e9>\\n\\u001f\",\"b\":[1,true,null],\"\U0001f600\":1,\"\ufb33\":2}"
+	if string(got) != want {
+		t.Errorf("Canonicalize = %s; want %s", got, want)
+	}
+}
+
+// TestCanonicalJSON_Deterministic verifies that equal values produce identical bytes.
+func TestCanonicalJSON_Deterministic(t *testing.T) {
+	a, err := encoding.CanonicalJSON(map[string]any{"z": 1, "m": map[string]any{"y": 2.50, "x": "&"}})
+	if err != nil {
+		t.Fatalf("CanonicalJSON unexpected error: %v", err)
+	}
+	b, _ := encoding.Canonicalize([]byte(`{"m":{"x":"&","y":2.5e0},"z":1E0}`))
+	if string(a) != string(b) || string(a) != `{"m":{"x":"&","y":2.5},"z":1}` {
+		t.Errorf("CanonicalJSON = %s; Canonicalize = %s", a, b)
+	}
+}
+
+// TestCanonicalize_Invalid verifies error reporting for invalid input.
+func TestCanonicalize_Invalid(t *testing.T) {
+	for _, in := range []string{"", "{", `{"a":1} 2`, `1e400`} {
+		if _, err := encoding.Canonicalize([]byte(in)); err == nil {
+			t.Errorf("Canonicalize(%q) expected error", in)
+		}
+	}
+}
+
+// TestFormatES6Number verifies the ECMAScript number serialization.
+func TestFormatES6Number(t *testing.T) {
+	cases := map[float64]string{
+		0:                      "0",
+		math.Copysign(0, -1):   "0",
+		1:                      "1",
+		-1.5:                   "-1.5",
+		100:                    "100",
+		123.456:                "123.456",
+		1e21:                   "1e+21",
+		1e20:                   "100000000000000000000",
+		0.000001:               "0.000001",
+		1e-7:                   "1e-7",
+		333333333.3333333:      "333333333.3333333",
+		4.35:                   "4.35",
+		5e-324:                 "5e-324",
+		1.7976931348623157e308: "1.7976931348623157e+308",
+		9007199254740992:       "9007199254740992",
+	}
+	for in, want := range cases {
+		got, err := encoding.FormatES6Number(in)
+		if err != nil || got != want {
+			t.Errorf("FormatES6Number(%v) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := encoding.FormatES6Number(math.NaN()); !errors.Is(err, encoding.ErrNonFiniteFloat) {
+		t.Errorf("FormatES6Number(NaN) error = %v; want ErrNonFiniteFloat", err)
+	}
+}
@@ -0,0 +1,23 @@
+package replifytest
+
+// Golden file settings.
+const (
+	// UpdateGoldenEnv is the environment variable that, when set to a
+	// non-empty value other than "0" or "false", makes [Assertion.Golden]
+	// write the actual envelope instead of comparing against it.
+	UpdateGoldenEnv = "REPLIFY_UPDATE_GOLDEN"
+
+	// goldenDir is the directory, relative to the package under test, that
+	// holds golden files.
+	goldenDir = "testdata"
+
+	// goldenExt is the file extension of golden files.
+	goldenExt = ".golden.json"
+
+	// maskedValue replaces the value of masked fields.
+	maskedValue = "<masked>"
+)
+
+// defaultMaskedKeys lists the envelope fields that change on every run and
+// are therefore masked in golden comparisons.
+var defaultMaskedKeys = []string{"request_id", "requested_time"}
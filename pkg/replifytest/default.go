@@ -0,0 +1,60 @@
+package replifytest
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+// Of creates an [Assertion] for an envelope built with replify.
+//
+// Parameters:
+//   - `t`: The test handle used to report failures.
+//   - `e`: The envelope, e.g. replify.WrapOk("ok", data).
+//
+// Returns:
+//   - A pointer to a newly created [Assertion].
+func Of(t testing.TB, e Envelope) *Assertion {
+	t.Helper()
+	body := ""
+	if e != nil {
+		body = e.JSON()
+	}
+	return Body(t, body)
+}
+
+// Recorder creates an [Assertion] for an HTTP response recorded with
+// httptest.ResponseRecorder. Envelope checks apply to the recorded body and
+// [Assertion.Status] additionally checks the recorded status code.
+//
+// Parameters:
+//   - `t`: The test handle used to report failures.
+//   - `rec`: The recorder that captured the response.
+//
+// Returns:
+//   - A pointer to a newly created [Assertion].
+func Recorder(t testing.TB, rec *httptest.ResponseRecorder) *Assertion {
+	t.Helper()
+	if rec == nil {
+		t.Fatalf("replifytest: response recorder is nil")
+	}
+	a := Body(t, rec.Body.String())
+	a.recorder = rec
+	return a
+}
+
+// Body creates an [Assertion] for a raw JSON envelope.
+//
+// Parameters:
+//   - `t`: The test handle used to report failures.
+//   - `body`: The JSON document of the envelope.
+//
+// Returns:
+//   - A pointer to a newly created [Assertion].
+func Body(t testing.TB, body string) *Assertion {
+	t.Helper()
+	return &Assertion{
+		t:     t,
+		body:  body,
+		masks: append([]string(nil), defaultMaskedKeys...),
+	}
+}
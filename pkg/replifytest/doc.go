@@ -0,0 +1,40 @@
+// Package replifytest provides fluent test assertions for replify envelopes
+// and the HTTP responses that carry them.
+//
+// An [Assertion] is created from anything that renders a replify envelope
+// (the builder returned by replify.New and the Wrap* helpers, or a *replify.R),
+// from an httptest.ResponseRecorder, or from a raw JSON body. Every check
+// calls t.Helper and reports failures with t.Errorf, so a single test can
+// record several failures, and every check returns the [Assertion] so that
+// checks can be chained.
+//
+// # Envelope Assertions
+//
+//	replifytest.Of(t, replify.WrapOk("users", users).WithPath("/v1/users")).
+//	    Status(http.StatusOK).
+//	    Message("users").
+//	    Path("/v1/users").
+//	    JSONPath("data.0.name", "alice")
+//
+// # HTTP Assertions
+//
+//	rec := httptest.NewRecorder()
+//	handler.ServeHTTP(rec, req)
+//	replifytest.Recorder(t, rec).
+//	    Status(http.StatusTooManyRequests).
+//	    HTTPHeader("Retry-After", "30").
+//	    Meta("retry_after", 30)
+//
+// # Diffs
+//
+// [Assertion.EqualJSON] and [Assertion.Golden] compare whole envelopes. The
+// documents are normalized (keys sorted, indented) and a line diff is
+// reported on mismatch, with "-" marking expected and "+" marking actual lines.
+//
+// # Golden Files
+//
+// [Assertion.Golden] compares the envelope with testdata/<name>.golden.json.
+// Volatile fields (request_id and requested_time by default, plus any key
+// passed to [Assertion.Mask]) are replaced by "<masked>" before comparison.
+// Run the tests with REPLIFY_UPDATE_GOLDEN=1 to create or refresh the files.
+package replifytest
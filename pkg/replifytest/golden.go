@@ -0,0 +1,58 @@
+package replifytest
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/sivaosorg/replify/pkg/sysx"
+)
+
+// Golden compares the envelope with the golden file testdata/<name>.golden.json,
+// reporting a line diff on mismatch. Volatile fields are masked (see
+// [Assertion.Mask]) and the document is normalized before both comparing and
+// writing, so golden files are stable across runs.
+//
+// When the environment variable REPLIFY_UPDATE_GOLDEN is set, the golden
+// file is (re)written from the actual envelope instead.
+//
+// Parameters:
+//   - `name`: The golden file name without directory and extension.
+//
+// Returns:
+//   - The [Assertion], enabling chaining.
+//
+// Example:
+//
+//	replifytest.Of(t, replify.WrapOk("users", users)).Golden("list_users")
+//	// REPLIFY_UPDATE_GOLDEN=1 go test ./... to refresh testdata/list_users.golden.json
+func (a *Assertion) Golden(name string) *Assertion {
+	a.t.Helper()
+	path := filepath.Join(goldenDir, name+goldenExt)
+	got := a.normalized(a.body)
+
+	if updateGolden() {
+		if err := os.MkdirAll(goldenDir, 0o755); err != nil {
+			a.t.Fatalf("replifytest: cannot create %s: %v", goldenDir, err)
+		}
+		if err := sysx.WriteBytes(path, []byte(got+"\n")); err != nil {
+			a.t.Fatalf("replifytest: cannot write golden file %s: %v", path, err)
+		}
+		return a
+	}
+
+	data, err := sysx.ReadBytes(path)
+	if err != nil {
+		a.t.Errorf("replifytest: cannot read golden file %s (run with %s=1 to create it): %v", path, UpdateGoldenEnv, err)
+		return a
+	}
+	if want := a.normalized(string(data)); got != want {
+		a.t.Errorf("replifytest: envelope does not match golden file %s\n%s", path, diffLines(want, got))
+	}
+	return a
+}
+
+// updateGolden reports whether golden files should be rewritten.
+func updateGolden() bool {
+	v := os.Getenv(UpdateGoldenEnv)
+	return v != "" && v != "0" && v != "false"
+}
@@ -0,0 +1,224 @@
+package replifytest
+
+import (
+	"strings"
+
+	"github.com/sivaosorg/replify/pkg/fj"
+)
+
+// JSON returns the raw JSON document under test.
+func (a *Assertion) JSON() string {
+	return a.body
+}
+
+// Status checks the status code of the envelope and, for an [Assertion]
+// created with [Recorder], the recorded HTTP status code as well.
+//
+// Parameters:
+//   - `code`: The expected HTTP status code.
+//
+// Returns:
+//   - The [Assertion], enabling chaining.
+func (a *Assertion) Status(code int) *Assertion {
+	a.t.Helper()
+	if a.recorder != nil && a.recorder.Code != code {
+		a.t.Errorf("replifytest: HTTP status = %d, want %d", a.recorder.Code, code)
+	}
+	return a.JSONPath("status_code", code)
+}
+
+// HeaderType checks the type of the envelope header (e.g. "Client Error").
+// The header carries a type only when it was set explicitly, e.g. in an
+// envelope received from another service.
+//
+// Parameters:
+//   - `typ`: The expected header type.
+//
+// Returns:
+//   - The [Assertion], enabling chaining.
+func (a *Assertion) HeaderType(typ string) *Assertion {
+	a.t.Helper()
+	return a.JSONPath("headers.type", typ)
+}
+
+// Message checks the message of the envelope.
+//
+// Parameters:
+//   - `message`: The expected message.
+//
+// Returns:
+//   - The [Assertion], enabling chaining.
+func (a *Assertion) Message(message string) *Assertion {
+	a.t.Helper()
+	return a.JSONPath("message", message)
+}
+
+// MessageContains checks that the message of the envelope contains substr.
+//
+// Parameters:
+//   - `substr`: The expected substring.
+//
+// Returns:
+//   - The [Assertion], enabling chaining.
+func (a *Assertion) MessageContains(substr string) *Assertion {
+	a.t.Helper()
+	if got := fj.Get(a.body, "message").String(); !strings.Contains(got, substr) {
+		a.t.Errorf("replifytest: message %q does not contain %q", got, substr)
+	}
+	return a
+}
+
+// Path checks the request path of the envelope.
+//
+// Parameters:
+//   - `path`: The expected path.
+//
+// Returns:
+//   - The [Assertion], enabling chaining.
+func (a *Assertion) Path(path string) *Assertion {
+	a.t.Helper()
+	return a.JSONPath("path", path)
+}
+
+// Total checks the total item count of the envelope.
+//
+// Parameters:
+//   - `total`: The expected total.
+//
+// Returns:
+//   - The [Assertion], enabling chaining.
+func (a *Assertion) Total(total int) *Assertion {
+	a.t.Helper()
+	return a.JSONPath("total", total)
+}
+
+// Meta checks a field of the envelope meta section. Built-in fields
+// (api_version, locale, request_id, requested_time) are looked up first,
+// then custom fields.
+//
+// Parameters:
+//   - `key`: The meta field name or a dotted path below it.
+//   - `want`: The expected value.
+//
+// Returns:
+//   - The [Assertion], enabling chaining.
+func (a *Assertion) Meta(key string, want any) *Assertion {
+	a.t.Helper()
+	if fj.Get(a.body, "meta."+key).Exists() {
+		return a.JSONPath("meta."+key, want)
+	}
+	return a.JSONPath("meta.custom_fields."+key, want)
+}
+
+// Pagination checks the page, per-page size and total item count of the
+// envelope pagination section.
+//
+// Parameters:
+//   - `page`: The expected current page.
+//   - `perPage`: The expected page size.
+//   - `totalItems`: The expected total number of items.
+//
+// Returns:
+//   - The [Assertion], enabling chaining.
+func (a *Assertion) Pagination(page, perPage, totalItems int) *Assertion {
+	a.t.Helper()
+	return a.JSONPath("pagination.page", page).
+		JSONPath("pagination.per_page", perPage).
+		JSONPath("pagination.total_items", totalItems)
+}
+
+// JSONPath checks the value at an fj path of the envelope. Values are
+// compared structurally after a JSON round-trip, so numbers of any Go type,
+// maps, slices and structs can be used as the expected value.
+//
+// Parameters:
+//   - `path`: The fj path, e.g. "data.items.0.id".
+//   - `want`: The expected value.
+//
+// Returns:
+//   - The [Assertion], enabling chaining.
+func (a *Assertion) JSONPath(path string, want any) *Assertion {
+	a.t.Helper()
+	ctx := fj.Get(a.body, path)
+	if !ctx.Exists() {
+		a.t.Errorf("replifytest: path %q not found in envelope\n%s", path, pretty(a.body))
+		return a
+	}
+	if !equalJSON(ctx.Raw(), want) {
+		a.t.Errorf("replifytest: path %q mismatch\n%s", path, diffLines(prettyValue(want), pretty(ctx.Raw())))
+	}
+	return a
+}
+
+// JSONPathExists checks that an fj path exists in the envelope.
+//
+// Parameters:
+//   - `path`: The fj path.
+//
+// Returns:
+//   - The [Assertion], enabling chaining.
+func (a *Assertion) JSONPathExists(path string) *Assertion {
+	a.t.Helper()
+	if !fj.Get(a.body, path).Exists() {
+		a.t.Errorf("replifytest: path %q not found in envelope\n%s", path, pretty(a.body))
+	}
+	return a
+}
+
+// HTTPHeader checks a header of the recorded HTTP response. It fails when
+// the [Assertion] was not created with [Recorder].
+//
+// Parameters:
+//   - `key`: The header name.
+//   - `want`: The expected header value.
+//
+// Returns:
+//   - The [Assertion], enabling chaining.
+func (a *Assertion) HTTPHeader(key, want string) *Assertion {
+	a.t.Helper()
+	if a.recorder == nil {
+		a.t.Errorf("replifytest: HTTPHeader(%q) requires an assertion created with Recorder", key)
+		return a
+	}
+	if got := a.recorder.Header().Get(key); got != want {
+		a.t.Errorf("replifytest: header %s = %q, want %q", key, got, want)
+	}
+	return a
+}
+
+// EqualJSON checks that the whole envelope is structurally equal to want,
+// reporting a line diff on mismatch. Masked fields (see [Assertion.Mask])
+// are ignored on both sides.
+//
+// Parameters:
+//   - `want`: The expected JSON document.
+//
+// Returns:
+//   - The [Assertion], enabling chaining.
+func (a *Assertion) EqualJSON(want string) *Assertion {
+	a.t.Helper()
+	got, expected := a.normalized(a.body), a.normalized(want)
+	if got != expected {
+		a.t.Errorf("replifytest: envelope mismatch\n%s", diffLines(expected, got))
+	}
+	return a
+}
+
+// Mask adds field names whose values are replaced by "<masked>" before
+// whole-document comparisons ([Assertion.EqualJSON] and [Assertion.Golden]).
+// request_id and requested_time are always masked.
+//
+// Parameters:
+//   - `keys`: The field names to mask, at any depth.
+//
+// Returns:
+//   - The [Assertion], enabling chaining.
+func (a *Assertion) Mask(keys ...string) *Assertion {
+	a.masks = append(a.masks, keys...)
+	return a
+}
+
+// normalized returns the masked, key-sorted, indented form of doc.
+func (a *Assertion) normalized(doc string) string {
+	return normalize(doc, a.masks)
+}
@@ -0,0 +1,98 @@
+package replifytest_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sivaosorg/replify"
+	"github.com/sivaosorg/replify/pkg/replifytest"
+)
+
+// recordingT captures failures instead of failing the enclosing test.
+type recordingT struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...any) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingT) Fatalf(format string, args ...any) {
+	r.Errorf(format, args...)
+}
+
+func sampleEnvelope() replifytest.Envelope {
+	return replify.WrapOk("users retrieved", []map[string]any{{"id": 1, "name": "alice"}}).
+		WithPath("/v1/users").
+		WithTotal(1).
+		WithPagination(replify.Pages().WithPage(1).WithPerPage(10).WithTotalItems(1)).
+		WithApiVersion("v1.0.0").
+		WithCustomFieldKV("region", "eu")
+}
+
+func TestOf_Passes(t *testing.T) {
+	replifytest.Of(t, sampleEnvelope()).
+		Status(http.StatusOK).
+		Message("users retrieved").
+		MessageContains("users").
+		Path("/v1/users").
+		Total(1).
+		Meta("api_version", "v1.0.0").
+		Meta("region", "eu").
+		Pagination(1, 10, 1).
+		JSONPath("data.0.name", "alice").
+		JSONPath("data.0", map[string]any{"id": 1, "name": "alice"})
+}
+
+func TestOf_ReportsDiff(t *testing.T) {
+	rt := &recordingT{TB: t}
+	replifytest.Of(rt, sampleEnvelope()).
+		Message("wrong").
+		JSONPath("data.0.missing", 1)
+
+	if len(rt.failures) != 2 {
+		t.Fatalf("expected 2 failures, got %d: %v", len(rt.failures), rt.failures)
+	}
+	if !strings.Contains(rt.failures[0], `- "wrong"`) || !strings.Contains(rt.failures[0], `+ "users retrieved"`) {
+		t.Errorf("expected a line diff, got:\n%s", rt.failures[0])
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	rec := httptest.NewRecorder()
+	replify.WrapTooManyRequest("slow down", nil).WithRetryAfter(30 * time.Second).WriteHTTP(rec)
+
+	replifytest.Recorder(t, rec).
+		Status(http.StatusTooManyRequests).
+		JSONPath("headers.text", "Too Many Requests").
+		HTTPHeader("Retry-After", "30").
+		Meta("retry_after", 30)
+}
+
+func TestGolden_MasksVolatileFields(t *testing.T) {
+	replifytest.Of(t, sampleEnvelope()).Golden("users")
+	if os.Getenv(replifytest.UpdateGoldenEnv) != "" {
+		return
+	}
+
+	rt := &recordingT{TB: t}
+	replifytest.Of(rt, replify.WrapOk("changed", nil)).Golden("users")
+	if len(rt.failures) != 1 {
+		t.Fatalf("expected a golden mismatch, got %v", rt.failures)
+	}
+}
+
+func TestBody_HeaderType(t *testing.T) {
+	replifytest.Body(t, `{"status_code":404,"message":"missing","headers":{"code":404,"text":"Not Found","type":"Client Error"}}`).
+		Status(http.StatusNotFound).
+		HeaderType("Client Error").
+		Message("missing")
+}
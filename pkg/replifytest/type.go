@@ -0,0 +1,21 @@
+package replifytest
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+// Envelope is anything that renders a replify envelope as JSON, such as the
+// builder returned by replify.New or a *replify.R.
+type Envelope interface {
+	JSON() string
+}
+
+// Assertion holds a rendered envelope (and optionally the HTTP response that
+// carried it) and exposes fluent checks against it.
+type Assertion struct {
+	t        testing.TB
+	body     string
+	recorder *httptest.ResponseRecorder
+	masks    []string
+}
@@ -0,0 +1,151 @@
+package replifytest
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// equalJSON reports whether the raw JSON value and want are structurally equal.
+func equalJSON(raw string, want any) bool {
+	var got any
+	if err := json.Unmarshal([]byte(raw), &got); err != nil {
+		return false
+	}
+	expected, ok := roundTrip(want)
+	if !ok {
+		return false
+	}
+	return reflect.DeepEqual(got, expected)
+}
+
+// roundTrip converts v into its generic JSON form (maps, slices, float64...).
+func roundTrip(v any) (any, bool) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, false
+	}
+	var out any
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+// normalize returns doc with the given keys masked, object keys sorted and
+// two-space indentation. Invalid JSON is returned unchanged.
+func normalize(doc string, masks []string) string {
+	var v any
+	if err := json.Unmarshal([]byte(doc), &v); err != nil {
+		return doc
+	}
+	if len(masks) > 0 {
+		set := make(map[string]struct{}, len(masks))
+		for _, k := range masks {
+			set[k] = struct{}{}
+		}
+		v = maskValue(v, set)
+	}
+	return marshalIndent(v, doc)
+}
+
+// marshalIndent encodes v with two-space indentation and without HTML
+// escaping, returning fallback if v cannot be encoded.
+func marshalIndent(v any, fallback string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fallback
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// maskValue replaces, at any depth, the values of object keys found in set.
+func maskValue(v any, set map[string]struct{}) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			if _, ok := set[k]; ok {
+				t[k] = maskedValue
+				continue
+			}
+			t[k] = maskValue(child, set)
+		}
+	case []any:
+		for i := range t {
+			t[i] = maskValue(t[i], set)
+		}
+	}
+	return v
+}
+
+// pretty returns the normalized form of a JSON document without masking.
+func pretty(doc string) string {
+	return normalize(doc, nil)
+}
+
+// prettyValue returns the normalized JSON form of a Go value.
+func prettyValue(v any) string {
+	return pretty(marshalIndent(v, ""))
+}
+
+// diffLines renders a line diff between want and got: unchanged lines are
+// prefixed with two spaces, expected-only lines with "- " and actual-only
+// lines with "+ ". The diff is computed with a longest-common-subsequence
+// table, falling back to a plain listing of both sides for very large inputs.
+func diffLines(want, got string) string {
+	a, b := strings.Split(want, "\n"), strings.Split(got, "\n")
+	var sb strings.Builder
+	sb.WriteString("--- want\n+++ got\n")
+
+	if len(a)*len(b) > 4_000_000 {
+		for _, l := range a {
+			sb.WriteString("- " + l + "\n")
+		}
+		for _, l := range b {
+			sb.WriteString("+ " + l + "\n")
+		}
+		return sb.String()
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			sb.WriteString("  " + a[i] + "\n")
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			sb.WriteString("- " + a[i] + "\n")
+			i++
+		default:
+			sb.WriteString("+ " + b[j] + "\n")
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		sb.WriteString("- " + a[i] + "\n")
+	}
+	for ; j < len(b); j++ {
+		sb.WriteString("+ " + b[j] + "\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,380 @@
+package replify
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/sivaosorg/replify/pkg/match"
+	"github.com/sivaosorg/replify/pkg/strutil"
+	"github.com/sivaosorg/replify/pkg/sysx"
+)
+
+// defaultPolicies holds the package-wide policies installed with [SetResponsePolicies].
+var defaultPolicies atomic.Pointer[PolicySet]
+
+// ParsePolicies decodes a [PolicyConfig] document into a [PolicySet].
+//
+// The package has no YAML dependency: JSON is decoded by default, and YAML
+// (or any other format) is supported by passing the Unmarshal function of
+// the library of your choice.
+//
+// Parameters:
+//   - `data`: The configuration document.
+//   - `unmarshal`: The decoder; nil means json.Unmarshal.
+//
+// Returns:
+//   - The decoded [PolicySet].
+//   - An error if the document cannot be decoded or a policy has no route.
+//
+// Example:
+//
+//	set, err := replify.ParsePolicies(data, yaml.Unmarshal)
+func ParsePolicies(data []byte, unmarshal PolicyUnmarshalFunc) (*PolicySet, error) {
+	if unmarshal == nil {
+		unmarshal = json.Unmarshal
+	}
+	var cfg PolicyConfig
+	if err := unmarshal(data, &cfg); err != nil {
+		return nil, NewErrorf("parse response policies: %v", err)
+	}
+	for i, p := range cfg.Policies {
+		if strutil.IsEmpty(p.Route) {
+			return nil, NewErrorf("parse response policies: policy #%d (%q) has no route", i, p.Name)
+		}
+	}
+	return NewPolicySet(cfg.Environment, cfg.Policies...), nil
+}
+
+// LoadPolicies reads a [PolicyConfig] file into a [PolicySet].
+//
+// Parameters:
+//   - `path`: The configuration file path.
+//   - `unmarshal`: The decoder; nil means json.Unmarshal. A ".yaml" or ".yml"
+//     file requires a YAML decoder.
+//
+// Returns:
+//   - The loaded [PolicySet].
+//   - An error if the file cannot be read or decoded.
+//
+// Example:
+//
+//	set, err := replify.LoadPolicies("config/response-policies.json", nil)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	replify.SetResponsePolicies(set)
+func LoadPolicies(path string, unmarshal PolicyUnmarshalFunc) (*PolicySet, error) {
+	if unmarshal == nil {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml":
+			return nil, NewErrorf("load response policies: %s is YAML; pass a YAML unmarshal function", path)
+		}
+	}
+	data, err := sysx.ReadBytes(path)
+	if err != nil {
+		return nil, NewErrorf("load response policies: %v", err)
+	}
+	return ParsePolicies(data, unmarshal)
+}
+
+// SetResponsePolicies installs the package-wide [PolicySet] applied by
+// [wrapper.WriteHTTP] and [wrapper.ServeHTTP] to wrappers that have no
+// policies of their own. Passing nil removes it. Only ServeHTTP resolves
+// the policies from the request; WriteHTTP matches the path set with
+// [wrapper.WithPath].
+//
+// Parameters:
+//   - `set`: The policies to install.
+func SetResponsePolicies(set *PolicySet) {
+	defaultPolicies.Store(set)
+}
+
+// ResponsePolicies returns the package-wide [PolicySet], or nil if none is installed.
+func ResponsePolicies() *PolicySet {
+	return defaultPolicies.Load()
+}
+
+// Environment returns the deployment environment the set is evaluated for.
+func (s *PolicySet) Environment() string {
+	if s == nil {
+		return ""
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.environment
+}
+
+// Policies returns a copy of the policies of the set.
+func (s *PolicySet) Policies() []ResponsePolicy {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]ResponsePolicy(nil), s.policies...)
+}
+
+// Reload re-reads the configuration file and atomically replaces the
+// environment and policies of the set, so behavior can change without a
+// restart. On error the set is left unchanged.
+//
+// Parameters:
+//   - `path`: The configuration file path.
+//   - `unmarshal`: The decoder; nil means json.Unmarshal.
+//
+// Returns:
+//   - An error if the file cannot be read or decoded.
+func (s *PolicySet) Reload(path string, unmarshal PolicyUnmarshalFunc) error {
+	if s == nil {
+		return NewError("Reload: policy set is nil")
+	}
+	loaded, err := LoadPolicies(path, unmarshal)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.environment = loaded.environment
+	s.policies = loaded.policies
+	s.mu.Unlock()
+	return nil
+}
+
+// Resolve merges every policy matching the request into one effective
+// [ResponsePolicy].
+//
+// Policies are evaluated in order, so list general policies first and
+// specific ones after: later matches override the scalar settings of
+// earlier ones and add to their redaction list.
+//
+// Parameters:
+//   - `method`: The HTTP method; empty matches any method restriction.
+//   - `path`: The request path.
+//
+// Returns:
+//   - The effective policy.
+//   - `true` if at least one policy matched.
+func (s *PolicySet) Resolve(method, path string) (ResponsePolicy, bool) {
+	var merged ResponsePolicy
+	if s == nil {
+		return merged, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	found := false
+	for _, p := range s.policies {
+		if !p.matches(s.environment, method, path) {
+			continue
+		}
+		found = true
+		merged.merge(p)
+	}
+	return merged, found
+}
+
+// WithPolicies attaches a [PolicySet] to the [wrapper] instance, overriding
+// the package-wide set installed with [SetResponsePolicies].
+//
+// Parameters:
+//   - `set`: The policies applied when the [wrapper] is written over HTTP.
+//
+// Returns:
+//   - A pointer to the modified [wrapper] instance (enabling method chaining).
+func (w *wrapper) WithPolicies(set *PolicySet) *wrapper {
+	if !w.Available() {
+		return w
+	}
+	w.policies = set
+	return w
+}
+
+// WithPolicy applies a [ResponsePolicy] to the [wrapper] instance: it
+// replaces the package default API version and locale, redacts the
+// configured fields, strips the debug section when debug is disabled and
+// compresses large bodies.
+//
+// Parameters:
+//   - `p`: The policy to apply.
+//
+// Returns:
+//   - A pointer to the modified [wrapper] instance (enabling method chaining).
+//
+// Example:
+//
+//	w := replify.WrapOk("ok", user).WithPolicy(replify.ResponsePolicy{
+//	    Redact: []string{"password"},
+//	    Locale: "en_US",
+//	})
+func (w *wrapper) WithPolicy(p ResponsePolicy) *wrapper {
+	if !w.Available() {
+		return w
+	}
+	if strutil.IsNotEmpty(p.APIVersion) && (!w.IsMetaPresent() || w.meta.apiVersion == "" || w.meta.apiVersion == defaultAPIVersion) {
+		w.WithApiVersion(p.APIVersion)
+	}
+	if strutil.IsNotEmpty(p.Locale) && (!w.IsMetaPresent() || w.meta.locale == "" || w.meta.locale == defaultLocale) {
+		w.WithLocale(p.Locale)
+	}
+	if len(p.Redact) > 0 {
+		w.redact(p.Redact, p.RedactWith)
+	}
+	if p.Debug != nil && !*p.Debug {
+		w.debug = nil
+	}
+	if p.CompressThreshold > 0 {
+		w.CompressSafe(p.CompressThreshold)
+	}
+	return w
+}
+
+// withResolvedPolicy returns a copy of the [wrapper] with the policies
+// matching the request applied, or the [wrapper] itself when none matches.
+// The receiver is never modified, so a shared [wrapper] stays safe to serve.
+func (w *wrapper) withResolvedPolicy(method, path string) *wrapper {
+	set := w.policies
+	if set == nil {
+		set = ResponsePolicies()
+	}
+	if set == nil {
+		return w
+	}
+	p, ok := set.Resolve(method, path)
+	if !ok {
+		return w
+	}
+	return w.Clone().WithPolicy(p)
+}
+
+// redact replaces the values of the given field names in data, debug and
+// meta custom fields, and records them for the elements of a streamed body.
+func (w *wrapper) redact(fields []string, with string) {
+	set := make(map[string]struct{}, len(fields))
+	for _, f := range fields {
+		set[strings.ToLower(f)] = struct{}{}
+	}
+	var replacement any = defaultRedactedValue
+	if strutil.IsNotEmpty(with) {
+		replacement = with
+	}
+	w.bodyRedact = w.bodyRedact.merge(set, replacement)
+	if w.data != nil {
+		if tree, ok := redactTree(w.data); ok {
+			w.data = redactValue(tree, set, replacement)
+		}
+	}
+	if w.debug != nil {
+		if tree, ok := redactTree(w.debug); ok {
+			w.debug, _ = redactValue(tree, set, replacement).(map[string]any)
+		}
+	}
+	if w.IsMetaPresent() && w.meta.customFields != nil {
+		if tree, ok := redactTree(w.meta.customFields); ok {
+			w.meta.customFields, _ = redactValue(tree, set, replacement).(map[string]any)
+		}
+	}
+}
+
+// matches reports whether the policy applies to the request.
+func (p ResponsePolicy) matches(environment, method, path string) bool {
+	if !match.Match(path, p.Route) {
+		return false
+	}
+	if strutil.IsNotEmpty(method) && len(p.Methods) > 0 &&
+		!slices.ContainsFunc(p.Methods, func(m string) bool { return strings.EqualFold(m, method) }) {
+		return false
+	}
+	if len(p.Environments) > 0 &&
+		!slices.ContainsFunc(p.Environments, func(e string) bool { return match.Match(environment, e) }) {
+		return false
+	}
+	return true
+}
+
+// merge overlays the settings of o onto p.
+func (p *ResponsePolicy) merge(o ResponsePolicy) {
+	if strutil.IsNotEmpty(o.Name) {
+		p.Name = o.Name
+	}
+	p.Route = o.Route
+	if o.Debug != nil {
+		debug := *o.Debug
+		p.Debug = &debug
+	}
+	if o.CompressThreshold != 0 {
+		p.CompressThreshold = o.CompressThreshold
+	}
+	p.Redact = append(p.Redact, o.Redact...)
+	if strutil.IsNotEmpty(o.RedactWith) {
+		p.RedactWith = o.RedactWith
+	}
+	if strutil.IsNotEmpty(o.APIVersion) {
+		p.APIVersion = o.APIVersion
+	}
+	if strutil.IsNotEmpty(o.Locale) {
+		p.Locale = o.Locale
+	}
+}
+
+// merge returns a new redaction covering the fields of r and set, with the
+// given replacement. The receiver may be shared by clones and is never modified.
+func (r *bodyRedaction) merge(set map[string]struct{}, replacement any) *bodyRedaction {
+	merged := &bodyRedaction{fields: make(map[string]struct{}, len(set)), replacement: replacement}
+	if r != nil {
+		maps.Copy(merged.fields, r.fields)
+	}
+	maps.Copy(merged.fields, set)
+	return merged
+}
+
+// apply returns the redacted generic JSON copy of an element of a streamed body.
+func (r *bodyRedaction) apply(v any) (any, error) {
+	tree, ok := redactTree(v)
+	if !ok {
+		return nil, NewError("element cannot be redacted")
+	}
+	return redactValue(tree, r.fields, r.replacement), nil
+}
+
+// redactTree returns a private generic JSON copy (maps, slices, float64...) of v.
+func redactTree(v any) (any, bool) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, false
+	}
+	var tree any
+	if err := json.Unmarshal(b, &tree); err != nil {
+		return nil, false
+	}
+	return tree, true
+}
+
+// redactValue replaces, at any depth, the values of object keys found in set.
+func redactValue(v any, set map[string]struct{}, replacement any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			if _, ok := set[strings.ToLower(k)]; ok {
+				t[k] = replacement
+				continue
+			}
+			t[k] = redactValue(child, set, replacement)
+		}
+	case []any:
+		for i := range t {
+			t[i] = redactValue(t[i], set, replacement)
+		}
+	}
+	return v
+}
+
+// requestRoute returns the method and path used to resolve policies.
+func requestRoute(r *http.Request) (string, string) {
+	if r == nil || r.URL == nil {
+		return "", ""
+	}
+	return r.Method, r.URL.Path
+}
@@ -0,0 +1,129 @@
+package replify_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sivaosorg/replify"
+)
+
+const policyConfig = `{
+  "environment": "production",
+  "policies": [
+    {"name": "api", "route": "/api/*", "debug": false, "redact": ["password"], "api_version": "v1", "locale": "en_US"},
+    {"name": "admin", "route": "/api/admin/*", "methods": ["POST"], "redact": ["token"], "redact_with": "***"},
+    {"name": "staging-only", "route": "/api/*", "environments": ["staging*"], "locale": "vi_VN"}
+  ]
+}`
+
+func TestParsePoliciesResolve(t *testing.T) {
+	t.Parallel()
+
+	set, err := replify.ParsePolicies([]byte(policyConfig), nil)
+	if err != nil {
+		t.Fatalf("ParsePolicies: %v", err)
+	}
+	p, ok := set.Resolve(http.MethodPost, "/api/admin/users")
+	if !ok {
+		t.Fatal("expected a matching policy")
+	}
+	if p.Name != "admin" || p.Locale != "en_US" || p.RedactWith != "***" || len(p.Redact) != 2 {
+		t.Errorf("unexpected merged policy: %+v", p)
+	}
+	if p, _ := set.Resolve(http.MethodGet, "/api/admin/users"); p.Name != "api" {
+		t.Errorf("method restriction ignored: %+v", p)
+	}
+	if _, ok := set.Resolve(http.MethodGet, "/health"); ok {
+		t.Error("unexpected match for /health")
+	}
+	if _, err := replify.ParsePolicies([]byte(`{"policies":[{"name":"x"}]}`), nil); err == nil {
+		t.Error("expected an error for a policy without route")
+	}
+}
+
+func TestServeHTTPAppliesPolicies(t *testing.T) {
+	t.Parallel()
+
+	set, err := replify.ParsePolicies([]byte(policyConfig), nil)
+	if err != nil {
+		t.Fatalf("ParsePolicies: %v", err)
+	}
+	w := replify.WrapOk("ok", map[string]any{"user": map[string]any{"name": "alice", "Password": "s3cret"}}).
+		WithDebuggingKV("sql", "select 1").
+		WithPolicies(set)
+
+	rec := httptest.NewRecorder()
+	w.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/users", nil))
+	body := rec.Body.String()
+	for _, want := range []string{`"Password":"[REDACTED]"`, `"api_version":"v1"`, `"locale":"en_US"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %s in %s", want, body)
+		}
+	}
+	if strings.Contains(body, `"debug"`) || strings.Contains(body, "s3cret") {
+		t.Errorf("policy not applied: %s", body)
+	}
+	if !strings.Contains(w.JSON(), "s3cret") {
+		t.Error("applying a policy must not modify the wrapper")
+	}
+}
+
+func TestWriteHTTPResolvesPoliciesFromPath(t *testing.T) {
+	t.Parallel()
+
+	set, err := replify.ParsePolicies([]byte(policyConfig), nil)
+	if err != nil {
+		t.Fatalf("ParsePolicies: %v", err)
+	}
+	w := replify.WrapOk("ok", map[string]any{"Password": "s3cret"}).WithPolicies(set)
+
+	rec := httptest.NewRecorder()
+	if err := w.WriteHTTP(rec); err != nil {
+		t.Fatalf("WriteHTTP: %v", err)
+	}
+	if !strings.Contains(rec.Body.String(), "s3cret") {
+		t.Errorf("route policy applied without a path: %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	if err := w.WithPath("/api/users").WriteHTTP(rec); err != nil {
+		t.Fatalf("WriteHTTP: %v", err)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `"Password":"[REDACTED]"`) {
+		t.Errorf("route policy not applied to the path of the wrapper: %s", body)
+	}
+}
+
+func TestLoadPolicies(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "policies.json")
+	if err := os.WriteFile(path, []byte(`{"policies":[{"route":"/v1/*","locale":"fr_FR"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	set, err := replify.LoadPolicies(path, nil)
+	if err != nil {
+		t.Fatalf("LoadPolicies: %v", err)
+	}
+	if p, ok := set.Resolve("", "/v1/x"); !ok || p.Locale != "fr_FR" {
+		t.Fatalf("unexpected policy: %+v", p)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"policies":[{"route":"/v1/*","locale":"de_DE"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Reload(path, nil); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if p, _ := set.Resolve("", "/v1/x"); p.Locale != "de_DE" {
+		t.Errorf("reload not applied: %+v", p)
+	}
+	if _, err := replify.LoadPolicies(filepath.Join(dir, "policies.yaml"), nil); err == nil {
+		t.Error("expected an error for YAML without an unmarshal function")
+	}
+}
@@ -0,0 +1,182 @@
+package replify
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sivaosorg/replify/pkg/slogger"
+)
+
+// Callback returns the streaming callback drawing the progress to the bar.
+// Draws are throttled; errors are always printed, on their own line.
+// Progress arriving after [ProgressBar.Finish], e.g. from a late
+// asynchronous callback, is ignored.
+//
+// Returns:
+//   - The callback, to register with [StreamingWrapper.WithCallback].
+//
+// Example:
+//
+//	bar := replify.NewProgressBar(os.Stderr, "backup.tar")
+//	streaming := replify.New().WithStreaming(file, nil)
+//	streaming.WithTotalBytes(size)
+//	streaming.WithCallback(bar.Callback())
+//	streaming.Start(ctx)
+//	bar.Finish(streaming.GetProgress())
+func (b *ProgressBar) Callback() StreamingCallback {
+	return func(p *StreamProgress, err error) {
+		if p == nil {
+			return
+		}
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.finished {
+			return
+		}
+		if err != nil {
+			b.clear()
+			fmt.Fprintf(b.out, "%s: %v\n", b.label, err)
+			return
+		}
+		if now := time.Now(); now.Sub(b.last) >= b.interval {
+			b.last = now
+			b.draw(p)
+		}
+	}
+}
+
+// Finish draws the final progress and ends the line of the bar.
+//
+// Parameters:
+//   - `p`: The final progress, e.g. from [StreamingWrapper.GetProgress].
+func (b *ProgressBar) Finish(p *StreamProgress) {
+	if p == nil {
+		p = &StreamProgress{}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.finished = true
+	b.draw(p)
+	if b.tty {
+		fmt.Fprintln(b.out)
+		b.drawn = false
+	}
+}
+
+// draw writes the progress line: redrawn in place on a terminal, appended
+// elsewhere. The caller holds mu.
+func (b *ProgressBar) draw(p *StreamProgress) {
+	line := b.render(p)
+	if b.tty {
+		fmt.Fprintf(b.out, "\r%s\x1b[K", line)
+		b.drawn = true
+		return
+	}
+	fmt.Fprintln(b.out, line)
+}
+
+// clear erases the pending line of a terminal. The caller holds mu.
+func (b *ProgressBar) clear() {
+	if b.tty && b.drawn {
+		io.WriteString(b.out, "\r\x1b[K")
+		b.drawn = false
+	}
+}
+
+// render formats the progress line: the label, the bar and the percentage
+// when the total size is known, the bytes transferred, the rate and the ETA.
+func (b *ProgressBar) render(p *StreamProgress) string {
+	var sb strings.Builder
+	if b.label != "" {
+		sb.WriteString(b.label)
+		sb.WriteByte(' ')
+	}
+	if p.TotalBytes > 0 {
+		pct := min(max(p.Percentage, 0), 100)
+		filled := pct * progressBarWidth / 100
+		sb.WriteByte('[')
+		sb.WriteString(strings.Repeat("=", filled))
+		if filled < progressBarWidth {
+			sb.WriteByte('>')
+			sb.WriteString(strings.Repeat(" ", progressBarWidth-filled-1))
+		}
+		fmt.Fprintf(&sb, "] %3d%% %s/%s", pct, formatBytes(p.TransferredBytes), formatBytes(p.TotalBytes))
+	} else {
+		sb.WriteString(formatBytes(p.TransferredBytes))
+	}
+	fmt.Fprintf(&sb, " %s/s", formatBytes(p.TransferRate))
+	if p.TotalBytes > 0 && p.Percentage < 100 && p.EstimatedTimeRemaining > 0 {
+		fmt.Fprintf(&sb, " ETA %s", p.EstimatedTimeRemaining.Round(time.Second))
+	}
+	return sb.String()
+}
+
+// NewProgressLogger returns a streaming callback logging the progress of a
+// stream to a slogger, at most once per interval. Errors are always logged,
+// at warn level.
+//
+// Parameters:
+//   - `logger`: The logger, e.g. with a field naming the stream; nil selects [slogger.S].
+//   - `interval`: The shortest time between two progress entries; 0 or less selects one second.
+//
+// Returns:
+//   - The callback, to register with [StreamingWrapper.WithCallback].
+//
+// Example:
+//
+//	logger := slogger.S().With(slogger.String("export", id))
+//	streaming.WithCallback(replify.NewProgressLogger(logger, 10*time.Second))
+func NewProgressLogger(logger *slogger.Logger, interval time.Duration) StreamingCallback {
+	if logger == nil {
+		logger = slogger.S()
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+	var mu sync.Mutex
+	var last time.Time
+	return func(p *StreamProgress, err error) {
+		if p == nil {
+			return
+		}
+		fields := []slogger.Field{
+			slogger.Int64("chunk", p.CurrentChunk),
+			slogger.Int64("transferred_bytes", p.TransferredBytes),
+			slogger.Int64("total_bytes", p.TotalBytes),
+			slogger.Int("percentage", p.Percentage),
+			slogger.Int64("transfer_rate", p.TransferRate),
+			slogger.Duration("eta", p.EstimatedTimeRemaining),
+		}
+		if err != nil {
+			logger.Warn("stream error", append(fields, slogger.Err(err))...)
+			return
+		}
+		mu.Lock()
+		now := time.Now()
+		due := now.Sub(last) >= interval
+		if due {
+			last = now
+		}
+		mu.Unlock()
+		if due {
+			logger.Info("stream progress", fields...)
+		}
+	}
+}
+
+// formatBytes formats a byte count with a binary unit, e.g. "12.3 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 5; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,70 @@
+package replify_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sivaosorg/replify"
+)
+
+func TestProgressBarLines(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	bar := replify.NewProgressBar(&out, "export.bin")
+	callback := bar.Callback()
+	callback(&replify.StreamProgress{TransferredBytes: 512 << 10, TotalBytes: 2 << 20, Percentage: 25, TransferRate: 1 << 20}, nil)
+	callback(&replify.StreamProgress{TransferredBytes: 1 << 20, TotalBytes: 2 << 20, Percentage: 50}, nil)
+	callback(&replify.StreamProgress{}, errors.New("chunk 3 failed"))
+	bar.Finish(&replify.StreamProgress{TransferredBytes: 2 << 20, TotalBytes: 2 << 20, Percentage: 100})
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want the first draw, the error and the final draw:\n%s", len(lines), out.String())
+	}
+	if want := "export.bin [=======>                      ]  25% 512.0 KiB/2.0 MiB 1.0 MiB/s"; lines[0] != want {
+		t.Errorf("line = %q, want %q", lines[0], want)
+	}
+	if lines[1] != "export.bin: chunk 3 failed" {
+		t.Errorf("error line = %q", lines[1])
+	}
+	if !strings.Contains(lines[2], "[==============================] 100% 2.0 MiB/2.0 MiB") || strings.Contains(lines[2], "\r") {
+		t.Errorf("final line = %q", lines[2])
+	}
+}
+
+func TestProgressBarIgnoresProgressAfterFinish(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	bar := replify.NewProgressBar(&out, "export.bin")
+	callback := bar.Callback()
+	bar.Finish(&replify.StreamProgress{TransferredBytes: 2 << 20, TotalBytes: 2 << 20, Percentage: 100})
+	final := out.String()
+	callback(&replify.StreamProgress{TransferredBytes: 1 << 20, TotalBytes: 2 << 20, Percentage: 50}, nil)
+	callback(&replify.StreamProgress{}, errors.New("late"))
+	if out.String() != final {
+		t.Errorf("late callbacks drew after Finish:\n%s", out.String())
+	}
+}
+
+func TestProgressBarStream(t *testing.T) {
+	t.Parallel()
+
+	data := checkpointSource(8 << 10)
+	var out bytes.Buffer
+	bar := replify.NewProgressBar(&out, "")
+	sw := newCheckpointStream(data, replify.StrategyChunked)
+	sw.WithTotalBytes(int64(len(data)))
+	sw.WithCallback(bar.Callback())
+	if w := sw.Start(context.Background()); w.IsError() {
+		t.Fatalf("Start: %v", w.Error())
+	}
+	bar.Finish(sw.GetProgress())
+	if !strings.Contains(out.String(), "100% 8.0 KiB/8.0 KiB") {
+		t.Errorf("output = %q, want a complete bar", out.String())
+	}
+}
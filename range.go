@@ -0,0 +1,247 @@
+package replify
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/sivaosorg/replify/pkg/strutil"
+)
+
+// Errors returned by [ParseRange].
+var (
+	// ErrRangeMalformed reports a Range header that cannot be parsed; servers ignore it.
+	ErrRangeMalformed = errors.New("malformed range")
+
+	// ErrRangeUnsatisfiable reports a Range header none of whose ranges overlaps the resource.
+	ErrRangeUnsatisfiable = errors.New("range not satisfiable")
+)
+
+// ParseRange parses a Range request header (RFC 9110 §14.2) against a
+// resource of the given size.
+//
+// Ranges are returned in request order and clipped to the resource; ranges
+// starting past its end are dropped. A header requesting more than 64
+// ranges, or more bytes in total than the resource holds, is reported as
+// malformed, which guards against amplification through overlapping ranges.
+//
+// Parameters:
+//   - `header`: The Range header value, e.g. "bytes=0-499, -500".
+//   - `size`: The size of the resource in bytes.
+//
+// Returns:
+//   - The satisfiable ranges; nil when the header is empty.
+//   - [ErrRangeMalformed] if the header is invalid, or [ErrRangeUnsatisfiable]
+//     if no range overlaps the resource.
+//
+// Example:
+//
+//	ranges, err := replify.ParseRange("bytes=0-99,-100", 1000)
+//	// ranges: [{Start:0 Length:100} {Start:900 Length:100}]
+func ParseRange(header string, size int64) ([]ByteRange, error) {
+	if strutil.IsEmpty(header) {
+		return nil, nil
+	}
+	unit, specs, ok := strings.Cut(header, "=")
+	if !ok || !strings.EqualFold(strings.TrimSpace(unit), rangeUnit) {
+		return nil, fmt.Errorf("%w: %q", ErrRangeMalformed, header)
+	}
+
+	var ranges []ByteRange
+	var total, count int64
+	for spec := range strings.SplitSeq(specs, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		if count++; count > int64(maxRanges) {
+			return nil, fmt.Errorf("%w: more than %d ranges", ErrRangeMalformed, maxRanges)
+		}
+		first, last, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrRangeMalformed, spec)
+		}
+		first, last = strings.TrimSpace(first), strings.TrimSpace(last)
+
+		var br ByteRange
+		if first == "" {
+			// Suffix range: the last n bytes.
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("%w: %q", ErrRangeMalformed, spec)
+			}
+			if n == 0 || size == 0 {
+				continue
+			}
+			br.Length = min(n, size)
+			br.Start = size - br.Length
+		} else {
+			start, err := strconv.ParseInt(first, 10, 64)
+			if err != nil || start < 0 {
+				return nil, fmt.Errorf("%w: %q", ErrRangeMalformed, spec)
+			}
+			end := size - 1
+			if last != "" {
+				if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+					return nil, fmt.Errorf("%w: %q", ErrRangeMalformed, spec)
+				}
+			}
+			if start >= size {
+				continue
+			}
+			br.Start, br.Length = start, min(end, size-1)-start+1
+		}
+		if total += br.Length; total > size {
+			return nil, fmt.Errorf("%w: ranges exceed the resource size", ErrRangeMalformed)
+		}
+		ranges = append(ranges, br)
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrRangeMalformed, header)
+	}
+	if len(ranges) == 0 {
+		return nil, ErrRangeUnsatisfiable
+	}
+	return ranges, nil
+}
+
+// ContentRange returns the Content-Range header value of the range.
+//
+// Parameters:
+//   - `size`: The size of the whole resource.
+//
+// Returns:
+//   - The header value, e.g. "bytes 0-99/1000".
+func (br ByteRange) ContentRange(size int64) string {
+	return fmt.Sprintf("%s %d-%d/%d", rangeUnit, br.Start, br.Start+br.Length-1, size)
+}
+
+// ServeRange serves a seekable resource, honoring Range requests.
+//
+// It answers a GET or HEAD request carrying a satisfiable Range header with
+// 206 Partial Content: a single range as is, several ranges as
+// multipart/byteranges. An unsatisfiable Range is answered with 416 and
+// "Content-Range: bytes */size"; a malformed one is ignored. When the
+// request has an If-Range header, the ranges are honored only if it equals
+// etag under the strong comparison; otherwise, as for requests without
+// Range, the whole resource is served with 200 OK. Accept-Ranges and, when
+// set, ETag are always sent.
+//
+// Parameters:
+//   - `rw`: The response writer.
+//   - `r`: The request.
+//   - `content`: The resource.
+//   - `size`: The size of the resource in bytes.
+//   - `contentType`: The media type of the resource; empty selects application/octet-stream.
+//   - `etag`: The strong entity tag of the resource, quoted; empty disables If-Range.
+//
+// Returns:
+//   - An error if reading the resource or writing the response fails.
+//
+// Example:
+//
+//	f, _ := os.Open(path)
+//	info, _ := f.Stat()
+//	replify.ServeRange(rw, r, f, info.Size(), "video/mp4", `"v42"`)
+func ServeRange(rw http.ResponseWriter, r *http.Request, content io.ReadSeeker, size int64, contentType string, etag string) error {
+	if rw == nil || r == nil || content == nil {
+		return NewError("ServeRange: response writer, request and content are required")
+	}
+	if strutil.IsEmpty(contentType) {
+		contentType = MediaTypeApplicationOctetStream.String()
+	}
+	header := rw.Header()
+	header.Set(HeaderAcceptRanges.String(), rangeUnit)
+	if strutil.IsNotEmpty(etag) {
+		header.Set(HeaderETag.String(), etag)
+	}
+
+	ranges, err := requestedRanges(r, size, etag)
+	if errors.Is(err, ErrRangeUnsatisfiable) {
+		header.Set(HeaderContentRange.String(), fmt.Sprintf("%s */%d", rangeUnit, size))
+		rw.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		return nil
+	}
+	full := len(ranges) == 0
+	if full {
+		ranges = []ByteRange{{Start: 0, Length: size}}
+	}
+	return writeRanges(rw, r, content, size, contentType, ranges, full)
+}
+
+// requestedRanges returns the ranges a request asks for, or nil when the
+// whole resource must be served: no Range header, a method other than GET
+// and HEAD, a failed If-Range precondition or a malformed header.
+func requestedRanges(r *http.Request, size int64, etag string) ([]ByteRange, error) {
+	header := r.Header.Get(HeaderRange.String())
+	if header == "" || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return nil, nil
+	}
+	if ifRange := r.Header.Get(HeaderIfRange.String()); ifRange != "" {
+		// Strong comparison only; dates are not supported, so they never match.
+		if strutil.IsEmpty(etag) || strings.HasPrefix(etag, "W/") || ifRange != etag {
+			return nil, nil
+		}
+	}
+	ranges, err := ParseRange(header, size)
+	if errors.Is(err, ErrRangeMalformed) {
+		return nil, nil
+	}
+	return ranges, err
+}
+
+// writeRanges writes the ranges of a resource: the whole resource with 200
+// OK when full is set, otherwise a 206 response.
+func writeRanges(rw http.ResponseWriter, r *http.Request, content io.ReadSeeker, size int64, contentType string, ranges []ByteRange, full bool) error {
+	header := rw.Header()
+	head := r.Method == http.MethodHead
+	if full || len(ranges) == 1 {
+		br := ranges[0]
+		header.Set(HeaderContentType.String(), contentType)
+		header.Set(HeaderContentLength.String(), strconv.FormatInt(br.Length, 10))
+		if full {
+			rw.WriteHeader(http.StatusOK)
+		} else {
+			header.Set(HeaderContentRange.String(), br.ContentRange(size))
+			rw.WriteHeader(http.StatusPartialContent)
+		}
+		if head {
+			return nil
+		}
+		return copyRange(rw, content, br)
+	}
+
+	mw := multipart.NewWriter(rw)
+	header.Set(HeaderContentType.String(), MediaTypeMultipartByteRanges.String()+"; boundary="+mw.Boundary())
+	rw.WriteHeader(http.StatusPartialContent)
+	if head {
+		return nil
+	}
+	for _, br := range ranges {
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			HeaderContentType.String():  {contentType},
+			HeaderContentRange.String(): {br.ContentRange(size)},
+		})
+		if err != nil {
+			return err
+		}
+		if err := copyRange(part, content, br); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// copyRange copies a range of a resource to w.
+func copyRange(w io.Writer, content io.ReadSeeker, br ByteRange) error {
+	if _, err := content.Seek(br.Start, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := io.CopyN(w, content, br.Length)
+	return err
+}
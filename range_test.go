@@ -0,0 +1,175 @@
+package replify_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sivaosorg/replify"
+)
+
+func TestParseRange(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		header string
+		want   []replify.ByteRange
+		err    error
+	}{
+		{"", nil, nil},
+		{"bytes=0-99", []replify.ByteRange{{Start: 0, Length: 100}}, nil},
+		{"bytes=900-", []replify.ByteRange{{Start: 900, Length: 100}}, nil},
+		{"bytes=-100", []replify.ByteRange{{Start: 900, Length: 100}}, nil},
+		{"bytes=-5000", []replify.ByteRange{{Start: 0, Length: 1000}}, nil},
+		{"bytes=990-2000", []replify.ByteRange{{Start: 990, Length: 10}}, nil},
+		{"bytes=0-9, 20-29", []replify.ByteRange{{Start: 0, Length: 10}, {Start: 20, Length: 10}}, nil},
+		{"bytes=0-9,5000-", []replify.ByteRange{{Start: 0, Length: 10}}, nil},
+		{"bytes=5000-", nil, replify.ErrRangeUnsatisfiable},
+		{"bytes=-0", nil, replify.ErrRangeUnsatisfiable},
+		{"items=0-9", nil, replify.ErrRangeMalformed},
+		{"bytes=9-0", nil, replify.ErrRangeMalformed},
+		{"bytes=a-b", nil, replify.ErrRangeMalformed},
+		{"bytes=", nil, replify.ErrRangeMalformed},
+		{"bytes=0-999,0-999", nil, replify.ErrRangeMalformed},
+	}
+	for _, tt := range tests {
+		got, err := replify.ParseRange(tt.header, 1000)
+		if !errors.Is(err, tt.err) || len(got) != len(tt.want) {
+			t.Errorf("ParseRange(%q) = %v, %v; want %v, %v", tt.header, got, err, tt.want, tt.err)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("ParseRange(%q)[%d] = %+v, want %+v", tt.header, i, got[i], tt.want[i])
+			}
+		}
+	}
+	if s := (replify.ByteRange{Start: 10, Length: 5}).ContentRange(100); s != "bytes 10-14/100" {
+		t.Errorf("ContentRange = %q", s)
+	}
+}
+
+func rangeRequest(h http.Handler, method string, headers ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/", nil)
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestDumpServeRange(t *testing.T) {
+	t.Parallel()
+
+	d, w := replify.WrapOk("export", map[string]any{"rows": strings.Repeat("0123456789", 100)}).Dump()
+	if w.IsError() {
+		t.Fatalf("Dump: %v", w.Error())
+	}
+	defer d.Close()
+
+	full := rangeRequest(d, http.MethodGet)
+	body := full.Body.Bytes()
+	if full.Code != http.StatusOK || len(body) < 1000 || int64(len(body)) != d.Size() || full.Header().Get("Accept-Ranges") != "bytes" {
+		t.Fatalf("full: %d, %d bytes", full.Code, len(body))
+	}
+	etag := full.Header().Get("ETag")
+	if etag == "" || etag != d.ETag() {
+		t.Fatalf("ETag = %q, want %q", etag, d.ETag())
+	}
+
+	rec := rangeRequest(d, http.MethodGet, "Range", "bytes=10-19")
+	if rec.Code != http.StatusPartialContent || !bytes.Equal(rec.Body.Bytes(), body[10:20]) {
+		t.Errorf("single: %d %q", rec.Code, rec.Body.String())
+	}
+	if cr := rec.Header().Get("Content-Range"); cr != "bytes 10-19/"+full.Header().Get("Content-Length") {
+		t.Errorf("Content-Range = %q", cr)
+	}
+
+	rec = rangeRequest(d, http.MethodGet, "Range", "bytes=0-4,-5")
+	mediaType, params, _ := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if rec.Code != http.StatusPartialContent || mediaType != "multipart/byteranges" {
+		t.Fatalf("multipart: %d %s", rec.Code, mediaType)
+	}
+	mr := multipart.NewReader(rec.Body, params["boundary"])
+	for _, want := range [][]byte{body[:5], body[len(body)-5:]} {
+		part, err := mr.NextPart()
+		if err != nil {
+			t.Fatalf("NextPart: %v", err)
+		}
+		if got, _ := io.ReadAll(part); !bytes.Equal(got, want) {
+			t.Errorf("part %s = %q, want %q", part.Header.Get("Content-Range"), got, want)
+		}
+	}
+
+	rec = rangeRequest(d, http.MethodGet, "Range", "bytes=100000-")
+	if rec.Code != http.StatusRequestedRangeNotSatisfiable || !strings.HasPrefix(rec.Header().Get("Content-Range"), "bytes */") {
+		t.Errorf("unsatisfiable: %d %q", rec.Code, rec.Header().Get("Content-Range"))
+	}
+
+	if rec = rangeRequest(d, http.MethodGet, "Range", "bytes=0-4", "If-Range", etag); rec.Code != http.StatusPartialContent {
+		t.Errorf("If-Range match: %d", rec.Code)
+	}
+	if rec = rangeRequest(d, http.MethodGet, "Range", "bytes=0-4", "If-Range", `"stale"`); rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), body) {
+		t.Errorf("If-Range mismatch: %d", rec.Code)
+	}
+	if rec = rangeRequest(d, http.MethodHead, "Range", "bytes=0-4"); rec.Code != http.StatusPartialContent || rec.Body.Len() != 0 {
+		t.Errorf("HEAD: %d, %d bytes", rec.Code, rec.Body.Len())
+	}
+}
+
+func TestStreamingServeRange(t *testing.T) {
+	t.Parallel()
+
+	data := checkpointSource(8 * 1024)
+	serve := func(headers ...string) (*httptest.ResponseRecorder, int) {
+		sw := replify.New().
+			WithHTTPHeader(replify.HeaderETag, `"v1"`).
+			WithStreaming(bytes.NewReader(data), nil)
+		sw.WithChunkSize(1024)
+		sw.WithStreamingStrategy(replify.StrategyChunked)
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for i := 0; i+1 < len(headers); i += 2 {
+			r.Header.Set(headers[i], headers[i+1])
+		}
+		rec := httptest.NewRecorder()
+		w := sw.ServeRange(rec, r)
+		if w.IsError() {
+			t.Errorf("ServeRange(%v): %v", headers, w.Error())
+		}
+		return rec, w.StatusCode()
+	}
+
+	rec, status := serve("Range", "bytes=1000-2999")
+	if rec.Code != http.StatusPartialContent || status != http.StatusPartialContent || !bytes.Equal(rec.Body.Bytes(), data[1000:3000]) {
+		t.Errorf("single: %d/%d, %d bytes", rec.Code, status, rec.Body.Len())
+	}
+	if cr := rec.Header().Get("Content-Range"); cr != "bytes 1000-2999/8192" {
+		t.Errorf("Content-Range = %q", cr)
+	}
+
+	if rec, _ = serve("Range", "bytes=0-9,-10"); rec.Code != http.StatusPartialContent || !strings.HasPrefix(rec.Header().Get("Content-Type"), "multipart/byteranges") {
+		t.Errorf("multipart: %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if rec, _ = serve("Range", "bytes=0-9", "If-Range", `"v0"`); rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), data) {
+		t.Errorf("If-Range mismatch: %d, %d bytes", rec.Code, rec.Body.Len())
+	}
+
+	// A compressed stream does not support ranges.
+	sw := replify.New().WithStreaming(bytes.NewReader(data), nil)
+	sw.WithCompressionType(replify.CompressGzip)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Range", "bytes=0-9")
+	rec = httptest.NewRecorder()
+	sw.ServeRange(rec, r.WithContext(context.Background()))
+	if rec.Code != http.StatusOK || rec.Header().Get("Accept-Ranges") != "none" {
+		t.Errorf("compressed: %d %q", rec.Code, rec.Header().Get("Accept-Ranges"))
+	}
+}
@@ -0,0 +1,329 @@
+package replify
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// WithRateLimit sets the IETF RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset transport headers on the [wrapper] instance and mirrors
+// them into the `rate_limit` custom field of [meta].
+//
+// Parameters:
+//   - `limit`: The quota size of the current window.
+//   - `remaining`: The quota units left in the current window.
+//   - `reset`: The time until the quota is replenished (rounded up to whole seconds).
+//
+// Returns:
+//   - A pointer to the modified [wrapper] instance (enabling method chaining).
+//
+// Example:
+//
+//	w := replify.WrapOk("ok", data).
+//	    WithRateLimit(100, 42, 30*time.Second)
+//	// RateLimit-Limit: 100, RateLimit-Remaining: 42, RateLimit-Reset: 30
+//	// meta.custom_fields.rate_limit = {"limit":100,"remaining":42,"reset":30}
+func (w *wrapper) WithRateLimit(limit, remaining int, reset time.Duration) *wrapper {
+	if !w.Available() {
+		return w
+	}
+	if remaining < 0 {
+		remaining = 0
+	}
+	seconds := ceilSeconds(reset)
+	w.WithHTTPHeader(HeaderRateLimitLimit, strconv.Itoa(limit))
+	w.WithHTTPHeader(HeaderRateLimitRemaining, strconv.Itoa(remaining))
+	w.WithHTTPHeader(HeaderRateLimitReset, strconv.FormatInt(seconds, 10))
+	w.WithCustomFieldKV(metaKeyRateLimit, map[string]any{
+		"limit":     limit,
+		"remaining": remaining,
+		"reset":     seconds,
+	})
+	return w
+}
+
+// WithRateLimitDecision applies a [RateLimitDecision] to the [wrapper] instance.
+//
+// The RateLimit-* headers are always set; when the decision rejects the
+// request, the Retry-After header is set as well.
+//
+// Parameters:
+//   - `d`: The decision returned by a [RateLimiter].
+//
+// Returns:
+//   - A pointer to the modified [wrapper] instance (enabling method chaining).
+func (w *wrapper) WithRateLimitDecision(d RateLimitDecision) *wrapper {
+	w.WithRateLimit(d.Limit, d.Remaining, d.Reset)
+	if !d.Allowed {
+		w.WithRetryAfter(d.RetryAfter)
+	}
+	return w
+}
+
+// WithRetryAfter sets the Retry-After transport header to a delay in seconds
+// and mirrors it into the `retry_after` custom field of [meta].
+//
+// Parameters:
+//   - `delay`: The time the client should wait before retrying (rounded up to whole seconds).
+//
+// Returns:
+//   - A pointer to the modified [wrapper] instance (enabling method chaining).
+//
+// Example:
+//
+//	w := replify.WrapServiceUnavailable("maintenance", nil).
+//	    WithRetryAfter(2 * time.Minute) // Retry-After: 120
+func (w *wrapper) WithRetryAfter(delay time.Duration) *wrapper {
+	if !w.Available() {
+		return w
+	}
+	seconds := ceilSeconds(delay)
+	w.WithHTTPHeader(HeaderRetryAfter, strconv.FormatInt(seconds, 10))
+	w.WithCustomFieldKV(metaKeyRetryAfter, seconds)
+	return w
+}
+
+// WithRetryAfterTime sets the Retry-After transport header to an HTTP-date
+// and mirrors the remaining delay (in seconds, as of the call) into the
+// `retry_after` custom field of [meta].
+//
+// Parameters:
+//   - `at`: The point in time after which the client may retry.
+//
+// Returns:
+//   - A pointer to the modified [wrapper] instance (enabling method chaining).
+func (w *wrapper) WithRetryAfterTime(at time.Time) *wrapper {
+	if !w.Available() {
+		return w
+	}
+	w.WithHTTPHeader(HeaderRetryAfter, at.UTC().Format(http.TimeFormat))
+	w.WithCustomFieldKV(metaKeyRetryAfter, ceilSeconds(time.Until(at)))
+	return w
+}
+
+// WithDeprecation sets the Deprecation transport header (RFC 9745 structured
+// date, e.g. "@1688169599") and mirrors the date into the `deprecation`
+// custom field of [meta] as RFC 3339.
+//
+// Parameters:
+//   - `at`: The date at which the resource was (or will be) deprecated.
+//
+// Returns:
+//   - A pointer to the modified [wrapper] instance (enabling method chaining).
+func (w *wrapper) WithDeprecation(at time.Time) *wrapper {
+	if !w.Available() {
+		return w
+	}
+	w.WithHTTPHeader(HeaderDeprecation, "@"+strconv.FormatInt(at.Unix(), 10))
+	w.WithCustomFieldKV(metaKeyDeprecation, at.UTC().Format(time.RFC3339))
+	return w
+}
+
+// WithSunset sets the Sunset transport header (RFC 8594 HTTP-date) and
+// mirrors the date into the `sunset` custom field of [meta] as RFC 3339.
+//
+// Parameters:
+//   - `at`: The date after which the resource is expected to become unresponsive.
+//
+// Returns:
+//   - A pointer to the modified [wrapper] instance (enabling method chaining).
+func (w *wrapper) WithSunset(at time.Time) *wrapper {
+	if !w.Available() {
+		return w
+	}
+	w.WithHTTPHeader(HeaderSunset, at.UTC().Format(http.TimeFormat))
+	w.WithCustomFieldKV(metaKeySunset, at.UTC().Format(time.RFC3339))
+	return w
+}
+
+// Allow consumes one token from the bucket identified by key.
+//
+// Parameters:
+//   - `key`: The bucket key, e.g. a client IP address.
+//
+// Returns:
+//   - A [RateLimitDecision] describing whether the request is admitted.
+func (l *TokenBucketLimiter) Allow(key string) RateLimitDecision {
+	now := time.Now()
+	rate := float64(l.limit) / l.period.Seconds() // tokens per second
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.calls++
+	if l.calls%1024 == 0 {
+		l.prune(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(l.limit), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.limit), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	d := RateLimitDecision{Limit: l.limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = secondsToDuration((1 - b.tokens) / rate)
+	}
+	d.Remaining = int(b.tokens)
+	d.Reset = secondsToDuration((float64(l.limit) - b.tokens) / rate)
+	return d
+}
+
+// prune drops buckets that have been idle long enough to be full again,
+// since they are indistinguishable from a freshly created bucket.
+// The caller must hold l.mu.
+func (l *TokenBucketLimiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= l.period {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Allow records one request for the window identified by key.
+//
+// Parameters:
+//   - `key`: The window key, e.g. a client IP address.
+//
+// Returns:
+//   - A [RateLimitDecision] describing whether the request is admitted.
+func (l *SlidingWindowLimiter) Allow(key string) RateLimitDecision {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.calls++
+	if l.calls%1024 == 0 {
+		l.prune(now)
+	}
+
+	win, ok := l.windows[key]
+	if !ok {
+		win = &slidingWindow{start: now.Truncate(l.window)}
+		l.windows[key] = win
+	}
+	win.advance(now, l.window)
+
+	elapsed := now.Sub(win.start)
+	weight := 1 - float64(elapsed)/float64(l.window)
+	estimate := float64(win.previous)*weight + float64(win.current)
+
+	d := RateLimitDecision{Limit: l.limit, Reset: l.window - elapsed}
+	if estimate+1 <= float64(l.limit) {
+		win.current++
+		estimate++
+		d.Allowed = true
+	} else {
+		d.RetryAfter = win.retryAfter(elapsed, l.limit, l.window)
+	}
+	d.Remaining = max(0, l.limit-int(math.Ceil(estimate)))
+	return d
+}
+
+// prune drops windows that have seen no traffic for two full windows.
+// The caller must hold l.mu.
+func (l *SlidingWindowLimiter) prune(now time.Time) {
+	for key, win := range l.windows {
+		if now.Sub(win.start) >= 2*l.window {
+			delete(l.windows, key)
+		}
+	}
+}
+
+// advance rolls the fixed windows forward so that `start` is the beginning
+// of the window containing now.
+func (sw *slidingWindow) advance(now time.Time, window time.Duration) {
+	elapsed := now.Sub(sw.start)
+	if elapsed < window {
+		return
+	}
+	if elapsed < 2*window {
+		sw.previous = sw.current
+	} else {
+		sw.previous = 0
+	}
+	sw.current = 0
+	sw.start = now.Truncate(window)
+}
+
+// retryAfter computes how long a rejected client must wait until the
+// weighted estimate drops below the limit again.
+func (sw *slidingWindow) retryAfter(elapsed time.Duration, limit int, window time.Duration) time.Duration {
+	untilNext := window - elapsed
+	if sw.current+1 > limit || sw.previous == 0 {
+		return untilNext
+	}
+	// previous*(1 - t/window) + current + 1 <= limit  =>  t >= window*(1 - (limit-current-1)/previous)
+	needed := time.Duration(float64(window) * (1 - float64(limit-sw.current-1)/float64(sw.previous)))
+	if wait := needed - elapsed; wait > 0 && wait < untilNext {
+		return wait
+	}
+	return untilNext
+}
+
+// RateLimitMiddleware returns an HTTP middleware that enforces a [RateLimiter].
+//
+// Admitted requests are forwarded to the next handler with the RateLimit-*
+// headers already set on the response. Rejected requests are answered with a
+// 429 Too Many Requests envelope (see [WrapTooManyRequest]) carrying the
+// RateLimit-* and Retry-After headers, mirrored into [meta].
+//
+// Parameters:
+//   - `limiter`: The limiter deciding admission, e.g. [NewTokenBucketLimiter].
+//   - `keyFunc`: Derives the bucket key from the request; defaults to [RemoteAddrKey] when nil.
+//
+// Returns:
+//   - A middleware wrapping an `http.Handler`.
+//
+// Example:
+//
+//	limiter := replify.NewTokenBucketLimiter(100, time.Minute)
+//	http.Handle("/api/", replify.RateLimitMiddleware(limiter, nil)(apiHandler))
+func RateLimitMiddleware(limiter RateLimiter, keyFunc RateLimitKeyFunc) func(http.Handler) http.Handler {
+	if keyFunc == nil {
+		keyFunc = RemoteAddrKey
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			d := limiter.Allow(keyFunc(r))
+			if d.Allowed {
+				h := rw.Header()
+				h.Set(HeaderRateLimitLimit.String(), strconv.Itoa(d.Limit))
+				h.Set(HeaderRateLimitRemaining.String(), strconv.Itoa(d.Remaining))
+				h.Set(HeaderRateLimitReset.String(), strconv.FormatInt(ceilSeconds(d.Reset), 10))
+				next.ServeHTTP(rw, r)
+				return
+			}
+			_ = WrapTooManyRequest("rate limit exceeded", nil).
+				WithPath(r.URL.Path).
+				WithRateLimitDecision(d).
+				WriteHTTP(rw)
+		})
+	}
+}
+
+// RemoteAddrKey is the default [RateLimitKeyFunc]; it keys requests by the
+// client IP address taken from `http.Request.RemoteAddr` (port stripped).
+//
+// Parameters:
+//   - `r`: The incoming request.
+//
+// Returns:
+//   - The client IP address, or the raw RemoteAddr if it has no port.
+func RemoteAddrKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
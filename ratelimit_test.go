@@ -0,0 +1,95 @@
+package replify_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sivaosorg/replify"
+)
+
+func TestWithRateLimit_HeadersAndMeta(t *testing.T) {
+	t.Parallel()
+
+	w := replify.WrapOk("ok", nil).
+		WithRateLimit(100, 42, 1500*time.Millisecond).
+		WithRetryAfter(2 * time.Minute).
+		WithDeprecation(time.Unix(1688169599, 0)).
+		WithSunset(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	cases := map[replify.HeaderType]string{
+		replify.HeaderRateLimitLimit:     "100",
+		replify.HeaderRateLimitRemaining: "42",
+		replify.HeaderRateLimitReset:     "2",
+		replify.HeaderRetryAfter:         "120",
+		replify.HeaderDeprecation:        "@1688169599",
+		replify.HeaderSunset:             "Tue, 01 Jan 2030 00:00:00 GMT",
+	}
+	for key, want := range cases {
+		if got := w.HTTPHeader(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	if !w.Meta().IsCustomKeyPresent("rate_limit") || w.Meta().CustomInt64("retry_after", 0) != 120 {
+		t.Errorf("expected rate_limit and retry_after to be mirrored into meta, got %v", w.Meta().CustomFields())
+	}
+}
+
+func TestTokenBucketLimiter_Allow(t *testing.T) {
+	t.Parallel()
+
+	l := replify.NewTokenBucketLimiter(2, time.Hour)
+	if !l.Allow("a").Allowed || !l.Allow("a").Allowed {
+		t.Fatal("expected the first two requests to be admitted")
+	}
+	d := l.Allow("a")
+	if d.Allowed || d.RetryAfter <= 0 || d.Remaining != 0 {
+		t.Fatalf("expected third request to be rejected with a retry hint, got %+v", d)
+	}
+	if !l.Allow("b").Allowed {
+		t.Fatal("expected an independent key to be admitted")
+	}
+}
+
+func TestSlidingWindowLimiter_Allow(t *testing.T) {
+	t.Parallel()
+
+	l := replify.NewSlidingWindowLimiter(3, time.Hour)
+	for i := 0; i < 3; i++ {
+		if !l.Allow("a").Allowed {
+			t.Fatalf("request %d should be admitted", i+1)
+		}
+	}
+	if d := l.Allow("a"); d.Allowed || d.RetryAfter <= 0 {
+		t.Fatalf("expected fourth request to be rejected, got %+v", d)
+	}
+}
+
+func TestRateLimitMiddleware_TooManyRequests(t *testing.T) {
+	t.Parallel()
+
+	limiter := replify.NewTokenBucketLimiter(1, time.Hour)
+	handler := replify.RateLimitMiddleware(limiter, nil)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		replify.WrapOk("ok", nil).WriteHTTP(rw)
+	}))
+
+	first := httptest.NewRecorder()
+	handler.ServeHTTP(first, httptest.NewRequest(http.MethodGet, "/v1/items", nil))
+	if first.Code != http.StatusOK || first.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("first request: code=%d headers=%v", first.Code, first.Header())
+	}
+
+	second := httptest.NewRecorder()
+	handler.ServeHTTP(second, httptest.NewRequest(http.MethodGet, "/v1/items", nil))
+	if second.Code != http.StatusTooManyRequests || second.Header().Get("Retry-After") == "" {
+		t.Fatalf("second request: code=%d headers=%v", second.Code, second.Header())
+	}
+	w, err := replify.UnwrapJSON(second.Body.String())
+	if err != nil {
+		t.Fatalf("UnwrapJSON: %v", err)
+	}
+	if w.StatusCode() != http.StatusTooManyRequests || !w.Meta().IsCustomKeyPresent("retry_after") {
+		t.Fatalf("unexpected envelope: %s", second.Body.String())
+	}
+}
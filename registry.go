@@ -0,0 +1,275 @@
+package replify
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/sivaosorg/replify/pkg/encoding"
+	"github.com/sivaosorg/replify/pkg/randn"
+	"github.com/sivaosorg/replify/pkg/strutil"
+)
+
+// WithStreamID sets the ID of the stream, under which a [StreamRegistry]
+// tracks it. It is recorded in the debugging information as "stream_id".
+//
+// Parameters:
+//   - `id`: The ID; it must not be empty.
+//
+// Returns:
+//   - A pointer to the underlying [wrapper] instance, allowing for method chaining.
+//   - If the streaming wrapper is nil, returns a new wrapper with an error message.
+func (sw *StreamingWrapper) WithStreamID(id string) *wrapper {
+	if sw == nil {
+		return respondStreamBadRequestDefault()
+	}
+	if strutil.IsEmpty(id) {
+		return sw.wrapper.
+			WithStatusCode(http.StatusBadRequest).
+			WithMessage("stream ID cannot be empty").
+			BindCause()
+	}
+	sw.mu.Lock()
+	registered := sw.registry != nil
+	if !registered {
+		sw.id = id
+	}
+	sw.mu.Unlock()
+	if registered {
+		return sw.wrapper.
+			WithStatusCode(http.StatusConflict).
+			WithMessage("cannot change the ID of a registered stream").
+			BindCause()
+	}
+	sw.wrapper.WithDebuggingKV("stream_id", id)
+	return sw.wrapper
+}
+
+// StreamID returns the ID of the stream, empty until set with
+// [StreamingWrapper.WithStreamID] or by [StreamRegistry.Register].
+func (sw *StreamingWrapper) StreamID() string {
+	if sw == nil {
+		return ""
+	}
+	sw.mu.RLock()
+	defer sw.mu.RUnlock()
+	return sw.id
+}
+
+// WithInterval sets the time between two progress events sent by the
+// registry.
+//
+// Parameters:
+//   - `interval`: The interval; values of 0 or less are ignored.
+//
+// Returns:
+//   - The registry, for chaining.
+func (r *StreamRegistry) WithInterval(interval time.Duration) *StreamRegistry {
+	if interval > 0 {
+		r.mu.Lock()
+		r.interval = interval
+		r.mu.Unlock()
+	}
+	return r
+}
+
+// Register adds a stream to the registry, under its ID. A stream without an
+// ID receives a random one, hard to guess, since anyone knowing it can
+// watch the stream. The stream leaves the registry when its Start returns,
+// so register it before starting it.
+//
+// Parameters:
+//   - `sw`: The stream.
+//
+// Returns:
+//   - The ID of the stream.
+//   - An error if the stream is nil, already registered, or its ID is taken.
+//
+// Example:
+//
+//	id, _ := registry.Register(streaming)
+//	go streaming.Start(context.Background())
+//	replify.WrapAccepted("Export started", map[string]string{"progress": "/streams/" + id}).WriteHTTP(rw)
+func (r *StreamRegistry) Register(sw *StreamingWrapper) (string, error) {
+	if sw == nil {
+		return "", NewError("Register: stream is nil")
+	}
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if sw.registry != nil {
+		return "", NewErrorf("Register: stream %q is already registered", sw.id)
+	}
+	id := sw.id
+	if strutil.IsEmpty(id) {
+		id = randn.CryptoID()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, taken := r.streams[id]; taken {
+		return "", NewErrorf("Register: stream ID %q is taken", id)
+	}
+	r.streams[id] = &registeredStream{sw: sw, done: make(chan struct{})}
+	if sw.id != id {
+		sw.id = id
+		sw.wrapper.WithDebuggingKV("stream_id", id)
+	}
+	sw.registry = r
+	return id, nil
+}
+
+// Unregister removes a stream from the registry; the progress events of its
+// watchers end as if it had completed.
+//
+// Parameters:
+//   - `id`: The ID of the stream.
+func (r *StreamRegistry) Unregister(id string) {
+	r.mu.Lock()
+	e, ok := r.streams[id]
+	if ok {
+		delete(r.streams, id)
+		close(e.done)
+	}
+	r.mu.Unlock()
+	if ok {
+		e.sw.mu.Lock()
+		e.sw.registry = nil
+		e.sw.mu.Unlock()
+	}
+}
+
+// Get returns a registered stream.
+//
+// Parameters:
+//   - `id`: The ID of the stream.
+//
+// Returns:
+//   - The stream, and `true` if it is registered.
+func (r *StreamRegistry) Get(id string) (*StreamingWrapper, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.streams[id]
+	if !ok {
+		return nil, false
+	}
+	return e.sw, true
+}
+
+// IDs returns the IDs of the registered streams, sorted.
+func (r *StreamRegistry) IDs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ids := make([]string, 0, len(r.streams))
+	for id := range r.streams {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// ServeHTTP implements [http.Handler], serving the progress of a registered
+// stream as server-sent events, for a browser EventSource.
+//
+// The stream is named by the "id" path wildcard of the route (see
+// [http.Request.PathValue]), or else by the "id" query parameter; an
+// unknown ID is answered with 404. A "progress" event carrying the
+// [StreamProgress] as JSON is sent at once, then whenever the progress
+// changes, at most once per interval. When the stream completes, a "done"
+// event carries its ID, status code, message, error if any, and
+// [StreamingStats], and the response ends. It also ends when the client
+// goes away.
+//
+// Example:
+//
+//	registry := replify.NewStreamRegistry()
+//	mux.Handle("GET /streams/{id}", registry)
+//
+//	// In the browser:
+//	//   const events = new EventSource("/streams/" + id);
+//	//   events.addEventListener("progress", e => render(JSON.parse(e.data)));
+//	//   events.addEventListener("done", e => events.close());
+func (r *StreamRegistry) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+	if strutil.IsEmpty(id) {
+		id = req.URL.Query().Get("id")
+	}
+	r.mu.RLock()
+	e, ok := r.streams[id]
+	interval := r.interval
+	r.mu.RUnlock()
+	if !ok {
+		http.Error(rw, "stream not found", http.StatusNotFound)
+		return
+	}
+
+	rc := http.NewResponseController(rw)
+	h := rw.Header()
+	h.Set(HeaderContentType.String(), MediaTypeTextEventStream.String())
+	h.Set(HeaderCacheControl.String(), "no-cache")
+	rw.WriteHeader(http.StatusOK)
+
+	var last time.Time
+	send := func() error {
+		p := e.sw.GetProgress()
+		if !last.IsZero() && p.LastUpdate.Equal(last) {
+			return nil
+		}
+		last = p.LastUpdate
+		if last.IsZero() {
+			last = time.Now()
+		}
+		return writeEvent(rw, rc, "progress", p.JSON())
+	}
+	if send() != nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if send() != nil {
+				return
+			}
+		case <-e.done:
+			_ = writeEvent(rw, rc, "done", streamOutcome(id, e.sw))
+			return
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+// unregister removes the stream from its registry, once Start returns.
+func (sw *StreamingWrapper) unregister() {
+	sw.mu.RLock()
+	r, id := sw.registry, sw.id
+	sw.mu.RUnlock()
+	if r != nil {
+		r.Unregister(id)
+	}
+}
+
+// streamOutcome returns the JSON payload of the "done" event of a stream.
+func streamOutcome(id string, sw *StreamingWrapper) string {
+	outcome := map[string]any{
+		"id":          id,
+		"status_code": sw.wrapper.StatusCode(),
+		"message":     sw.wrapper.Message(),
+		"stats":       sw.GetStats(),
+	}
+	if sw.wrapper.IsError() {
+		outcome["error"] = sw.wrapper.Error()
+	}
+	return encoding.JSON(outcome)
+}
+
+// writeEvent writes a server-sent event and flushes it to the client.
+func writeEvent(w io.Writer, rc *http.ResponseController, event, data string) error {
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	return rc.Flush()
+}
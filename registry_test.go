@@ -0,0 +1,110 @@
+package replify_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sivaosorg/replify"
+)
+
+// readEvents reads the server-sent events of body, as event name and data.
+func readEvents(t *testing.T, body io.Reader) [][2]string {
+	t.Helper()
+	var events [][2]string
+	var event string
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			events = append(events, [2]string{event, strings.TrimPrefix(line, "data: ")})
+		}
+	}
+	return events
+}
+
+func TestStreamRegistrySSE(t *testing.T) {
+	t.Parallel()
+
+	registry := replify.NewStreamRegistry().WithInterval(10 * time.Millisecond)
+	mux := http.NewServeMux()
+	mux.Handle("GET /streams/{id}", registry)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	pr, pw := io.Pipe()
+	sw := replify.New().WithStreaming(pr, nil)
+	sw.WithChunkSize(1024)
+	sw.WithStreamingStrategy(replify.StrategyChunked)
+	sw.WithWriter(io.Discard)
+	id, err := registry.Register(sw)
+	if err != nil || id == "" || sw.StreamID() != id {
+		t.Fatalf("Register = %q, %v; StreamID = %q", id, err, sw.StreamID())
+	}
+	if _, err := registry.Register(sw); err == nil {
+		t.Error("registering a stream twice succeeded")
+	}
+
+	resp, err := http.Get(srv.URL + "/streams/" + id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sw.Start(context.Background())
+	}()
+	for range 4 {
+		pw.Write(make([]byte, 1024))
+		time.Sleep(20 * time.Millisecond)
+	}
+	pw.Close()
+	<-done
+
+	events := readEvents(t, resp.Body)
+	if len(events) < 2 || events[0][0] != "progress" {
+		t.Fatalf("events = %v, want progress events then done", events)
+	}
+	last := events[len(events)-1]
+	var outcome struct {
+		ID         string `json:"id"`
+		StatusCode int    `json:"status_code"`
+		Stats      struct {
+			TotalBytes int64 `json:"total_bytes"`
+		} `json:"stats"`
+	}
+	if err := json.Unmarshal([]byte(last[1]), &outcome); last[0] != "done" || err != nil {
+		t.Fatalf("last event = %v (%v), want done", last, err)
+	}
+	if outcome.ID != id || outcome.StatusCode != 200 || outcome.Stats.TotalBytes != 4096 {
+		t.Errorf("outcome = %+v", outcome)
+	}
+	if _, ok := registry.Get(id); ok {
+		t.Error("completed stream still registered")
+	}
+}
+
+func TestStreamRegistryUnknown(t *testing.T) {
+	t.Parallel()
+
+	registry := replify.NewStreamRegistry()
+	rec := httptest.NewRecorder()
+	registry.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/streams?id=missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
@@ -184,9 +184,10 @@ func (w *wrapper) BodyString() string {
 //
 // This function checks if the [wrapper] instance is available and if the body data
 // exceeds the specified threshold for compression. If the body data is larger than
-// the threshold, it compresses the data using gzip and updates the body with the
-// compressed data. It also adds debugging information about the compression process,
-// including the original and compressed sizes.
+// the threshold, it compresses the data with the codec set by
+// [wrapper.WithCompressionCodec] (gzip by default) and updates the body with the
+// base64-encoded compressed data. It also adds debugging information about the
+// compression process, including the original and compressed sizes.
 // If the threshold is not specified or is less than or equal to zero, it defaults to 1024 bytes (1KB).
 // It also removes any empty debugging fields to clean up the response.
 // Parameters:
@@ -220,7 +221,8 @@ func (w *wrapper) CompressSafe(threshold int) *wrapper {
 
 	// Compress the body data and update the instance with the compressed data.
 	// If the compression fails, return the original instance without modifications.
-	compressed := compress(w.data)
+	codec := w.CompressionCodec()
+	compressed := compress(w.data, codec)
 	if strutil.IsEmpty(compressed) {
 		return w // compression failed, leave body unchanged
 	}
@@ -228,7 +230,7 @@ func (w *wrapper) CompressSafe(threshold int) *wrapper {
 	// Update the instance with the compressed data and debugging information.
 	w.
 		WithBody(compressed).
-		WithDebuggingKV("compression", "gzip").
+		WithDebuggingKV("compression", codec).
 		WithDebuggingKV("original_size", originalSize).
 		WithDebuggingKV("compressed_size", len(compressed))
 	return w
@@ -237,9 +239,11 @@ func (w *wrapper) CompressSafe(threshold int) *wrapper {
 // DecompressSafe decompresses the body data if it is compressed.
 //
 // This function checks if the [wrapper] instance is available and if the body data
-// is compressed. If the body data is compressed, it decompresses the data using gzip
-// and updates the instance with the decompressed data. It also adds debugging information
-// about the decompression process, including the original and decompressed sizes.
+// is compressed. If the body data is compressed, it decompresses the data with the
+// codec recorded by [wrapper.CompressSafe] in the debug section, falling back to the
+// codec set by [wrapper.WithCompressionCodec] (gzip by default), and updates the
+// instance with the decompressed data. It also adds debugging information about the
+// decompression process, including the original and decompressed sizes.
 // If the body data is not compressed, it returns the original instance without modifications.
 //
 // Returns:
@@ -252,12 +256,16 @@ func (w *wrapper) DecompressSafe() *wrapper {
 	}
 	if s, ok := w.data.(string); ok {
 		originalSize := len(s)
-		w.data = decompress(s)
+		codec := w.CompressionCodec()
+		if recorded, ok := w.debug["compression"].(string); ok && strutil.IsNotEmpty(recorded) {
+			codec = recorded
+		}
+		w.data = decompress(s, codec)
 		decompressed, _ := w.data.(string)
 		// Update the instance with the decompressed data and debugging information.
 		w.
 			WithBody(w.data).
-			WithDebuggingKV("decompression", "gzip").
+			WithDebuggingKV("decompression", codec).
 			WithDebuggingKV("original_size", originalSize).
 			WithDebuggingKV("decompressed_size", len(decompressed))
 	}
@@ -1424,7 +1432,9 @@ func (w *wrapper) Clone() *wrapper {
 			WithApiVersion(w.meta.apiVersion).
 			WithRequestID(w.meta.requestID).
 			WithLocale(w.meta.locale).
-			WithRequestedTime(w.meta.requestedTime)
+			WithRequestedTime(w.meta.requestedTime).
+			WithDeltaCnt(w.meta.deltaCnt).
+			WithDeltaValue(w.meta.deltaValue)
 
 		if w.meta.customFields != nil {
 			customFieldsCopy := make(map[string]any)
@@ -1449,6 +1459,23 @@ func (w *wrapper) Clone() *wrapper {
 		maps.Copy(clone.debug, w.debug)
 	}
 
+	// Clone error chain settings
+	clone.errorChain = w.errorChain
+	clone.errorChainStack = w.errorChainStack
+	clone.envelopeVersion = w.envelopeVersion
+	clone.canonical = w.canonical
+	clone.policies = w.policies
+	clone.codec = w.codec
+	clone.contentEncoding = w.contentEncoding
+	clone.encodingMinSize = w.encodingMinSize
+	clone.bodySeq = w.bodySeq
+	clone.bodyRedact = w.bodyRedact
+
+	// Clone transport headers
+	if w.httpHeaders != nil {
+		clone.httpHeaders = w.httpHeaders.Clone()
+	}
+
 	return clone
 }
 
@@ -1483,6 +1510,17 @@ func (w *wrapper) Reset() *wrapper {
 	w.errors = nil
 	w.pagination = nil
 	w.cachedWrap = nil
+	w.httpHeaders = nil
+	w.errorChain = false
+	w.errorChainStack = false
+	w.envelopeVersion = ""
+	w.canonical = false
+	w.policies = nil
+	w.codec = ""
+	w.contentEncoding = false
+	w.encodingMinSize = 0
+	w.bodySeq = nil
+	w.bodyRedact = nil
 
 	// Reset meta
 	w.meta = defaultMetaValues()
@@ -1604,10 +1642,12 @@ func (w *wrapper) WithMessagef(message string, args ...any) *wrapper {
 // Notes:
 //   - This function does not validate or normalize the input value.
 //   - It simply assigns the value to the `data` field of the [wrapper].
+//   - It replaces a body set with [wrapper.WithBodySeq].
 //   - The value will be marshalled to JSON when the [wrapper] is converted to a string.
 //   - Consider using WithJSONBody instead if you need to normalize the input value.
 func (w *wrapper) WithBody(v any) *wrapper {
 	w.data = v
+	w.bodySeq = nil
 	return w
 }
 
@@ -1822,6 +1862,12 @@ func (w *wrapper) WithErrorf(format string, args ...any) *wrapper {
 // This function wraps the provided error with stack trace information, assigns it
 // to the `errors` field of the [wrapper], and returns the modified instance.
 //
+// When the error carries classification metadata (see [Classify], or any error
+// implementing `Timeout() bool` / `Temporary() bool` such as `net.Error`), the
+// status and the public message are derived from it: an explicit status wins,
+// timeouts map to 504 Gateway Timeout, temporary or retryable errors map to
+// 503 Service Unavailable, and a public message replaces the response message.
+//
 // Parameters:
 //   - err: The error object to be wrapped with stack trace information.
 //
@@ -1829,7 +1875,7 @@ func (w *wrapper) WithErrorf(format string, args ...any) *wrapper {
 //   - A pointer to the modified [wrapper] instance to support method chaining.
 func (w *wrapper) WithErrorAck(err error) *wrapper {
 	w.errors = NewErrorAck(err)
-	return w
+	return w.applyErrorClass(err)
 }
 
 // AppendErrorAck wraps an existing error with an additional message and sets it for the [wrapper] instance.
@@ -2456,11 +2502,19 @@ func (w *wrapper) WithIsLast(v bool) *wrapper {
 // This method concatenates the values of the `statusCode`, `message`, `data`, and [meta] fields
 // into a single string and then computes a hash of that string using the `strutil.MustHash256` function.
 // The resulting hash string can be used for various purposes, such as caching or integrity checks.
+// When canonical output is enabled with [wrapper.WithCanonicalJSON], the hash is the SHA-256 of the
+// RFC 8785 canonical form of those fields, which is stable across processes and services.
 func (w *wrapper) MustHash256() (string, *wrapper) {
 	if !w.Available() {
 		return "", w
 	}
-	h, err := hashy.Hash256(w.StatusCode(), w.message, w.data, w.meta.Respond())
+	var h string
+	var err error
+	if w.IsCanonicalJSON() {
+		h, err = canonicalHash256(w.StatusCode(), w.message, w.data, w.meta.Respond())
+	} else {
+		h, err = hashy.Hash256(w.StatusCode(), w.message, w.data, w.meta.Respond())
+	}
 	if err != nil {
 		return "", New().
 			WithHeader(InternalServerError).
@@ -2668,7 +2722,11 @@ func (w *wrapper) AsStreaming(reader io.Reader) *StreamingWrapper {
 // MustHash256() introduces on every call, and it covers ALL nine fields that
 // build() writes to the response map—the public Hash256() only covers four.
 func (w *wrapper) hashFor() string {
-	h, err := hashy.Hash256(
+	hash256 := hashy.Hash256
+	if w.canonical {
+		hash256 = canonicalHash256
+	}
+	h, err := hash256(
 		w.StatusCode(),
 		w.message,
 		w.data,
@@ -2678,6 +2736,7 @@ func (w *wrapper) hashFor() string {
 		w.debug,
 		w.total,
 		w.path,
+		w.errorChainKey(),
 	)
 	if err != nil {
 		return ""
@@ -2789,11 +2848,15 @@ func (w *wrapper) ReplyPtr() *R {
 //
 // This function uses the `encoding.JSON` utility to generate a JSON representation
 // of the [wrapper] instance. The output is a compact JSON string with no additional
-// whitespace or formatting.
+// whitespace or formatting. When canonical output is enabled with
+// [wrapper.WithCanonicalJSON], the RFC 8785 canonical form is produced instead.
 //
 // Returns:
 //   - A compact JSON string representation of the [wrapper] instance.
 func (w *wrapper) JSON() string {
+	if w.IsCanonicalJSON() {
+		return w.canonicalJSON()
+	}
 	return jsonpass(w.Respond())
 }
 
@@ -3011,7 +3074,9 @@ func (w *wrapper) Slogging(logger ...*slogger.Logger) *wrapper {
 // "replify-dump-*.json" and is removed automatically when Close is called.
 //
 // The serialized content matches [wrapper.JSONPretty] — the full response
-// envelope (status, headers, body, meta, pagination, debug).
+// envelope (status, headers, body, meta, pagination, debug). A body set
+// with [wrapper.WithBodySeq] is encoded into the file as it is produced
+// (see [wrapper.EncodeTo]).
 //
 // Both return values are always non-nil:
 //   - (*Dump, *wrapper) — Dump holds the file; wrapper carries the outcome so
@@ -3034,14 +3099,20 @@ func (w *wrapper) Dump() (*Dump, *wrapper) {
 			WithHeader(InternalServerError).
 			WithMessage("Dump: wrapper is required")
 	}
-	d, err := dumpJSON(w.JSONBytes())
+	var d *sysx.Resource
+	var err error
+	if w.bodySeq != nil {
+		d, err = dumpEncoded(w)
+	} else {
+		d, err = dumpJSON(w.JSONBytes())
+	}
 	if err != nil {
 		return nil, New().
 			WithHeader(InternalServerError).
 			WithErrorAck(err).
 			WithMessage("Dump: failed to create temp file")
 	}
-	return &Dump{syr: d}, New().
+	return &Dump{syr: d, etag: w.ETag()}, New().
 		WithHeader(OK).
 		WithMessagef("Dump: succeeded and written to temp file %s", d.Name())
 }
@@ -3117,7 +3188,7 @@ func (w *wrapper) DumpTo(dst string) (*Dump, *wrapper) {
 			WithMessage("DumpTo: failed to create in-process temp copy")
 	}
 	d.WithName(filepath.Base(dst)) // set the name for better error messages and debugging; the full path is in the wrapper message
-	return &Dump{syr: d, filepath: dst},
+	return &Dump{syr: d, filepath: dst, etag: w.ETag()},
 		New().
 			WithHeader(OK).
 			WithMessagef("DumpTo: succeeded, written to %q", dst)
@@ -3183,7 +3254,7 @@ func (w *wrapper) DumpBody() (*Dump, *wrapper) {
 			WithErrorAck(err).
 			WithMessage("DumpBody: failed to create temp file")
 	}
-	return &Dump{syr: d}, New().
+	return &Dump{syr: d, etag: w.ETag()}, New().
 		WithHeader(OK).
 		WithMessagef("DumpBody: succeeded and written to temp file %s", d.Name())
 }
@@ -3289,7 +3360,7 @@ func (w *wrapper) DumpBodyTo(dst string) (*Dump, *wrapper) {
 			WithMessage("DumpBodyTo: failed to rewind in-process copy")
 	}
 	d.WithName(filepath.Base(dst)) // set the name for better error messages and debugging; the file itself is still the temp copy, not dst
-	return &Dump{syr: d, filepath: dst},
+	return &Dump{syr: d, filepath: dst, etag: w.ETag()},
 		New().
 			WithHeader(OK).
 			WithMessagef("DumpBodyTo: succeeded, written to %q", dst)
@@ -3400,6 +3471,9 @@ func (w *wrapper) build() map[string]any {
 	if w.IsDebuggingPresent() {
 		m["debug"] = w.debug
 	}
+	if w.IsErrorChainPresent() {
+		m["errors"] = w.ErrorTree()
+	}
 	return m
 }
 
@@ -0,0 +1,285 @@
+package replify
+
+import (
+	"bufio"
+	"fmt"
+	"go/build"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/sivaosorg/replify/pkg/match"
+)
+
+// FramesWith resolves the StackTrace into [StackFrame] values, applying the
+// given [StackOptions]: package include/exclude filters, path trimming,
+// folding of repeated frames, the depth limit and optional source snippets.
+//
+// Parameters:
+//   - `opts`: The capture options; nil uses [NewStackOptions].
+//
+// Returns:
+//   - The filtered frames, innermost first.
+//
+// Example:
+//
+//	opts := replify.NewStackOptions()
+//	opts.Include = []string{"github.com/acme/"}
+//	opts.SourceLines = 2
+//	frames := trace.FramesWith(opts)
+func (st StackTrace) FramesWith(opts *StackOptions) []StackFrame {
+	if len(st) == 0 {
+		return nil
+	}
+	if opts == nil {
+		opts = NewStackOptions()
+	}
+	prefixes := opts.trimPrefixes()
+	sources := make(map[string][]string)
+
+	frames := make([]StackFrame, 0, len(st))
+	for _, f := range st {
+		sf := f.StackFrame()
+		if !opts.allows(framePackage(sf.Func)) {
+			continue
+		}
+		if opts.CollapseRepeats && len(frames) > 0 {
+			last := &frames[len(frames)-1]
+			if last.Func == sf.Func && last.Line == sf.Line && last.File == sf.File {
+				last.Count = max(last.Count, 1) + 1
+				continue
+			}
+		}
+		if opts.Depth > 0 && len(frames) == opts.Depth {
+			break
+		}
+		frames = append(frames, sf)
+	}
+
+	for i := range frames {
+		if opts.SourceLines > 0 {
+			frames[i].Source = sourceContext(sources, frames[i].File, frames[i].Line, opts.SourceLines)
+		}
+		frames[i].File = trimFilePrefix(frames[i].File, prefixes)
+	}
+	return frames
+}
+
+// CaptureStack captures the stack of the calling goroutine and resolves it
+// with the given [StackOptions]. The frame of the caller of CaptureStack is
+// the first frame of the result.
+//
+// Parameters:
+//   - `opts`: The capture options; nil uses [NewStackOptions].
+//
+// Returns:
+//   - The filtered frames, innermost first.
+func CaptureStack(opts *StackOptions) []StackFrame {
+	return callersN(3, 128).FramesWith(opts)
+}
+
+// FormatStackFrames renders frames in the same layout as "%+v" on a
+// [StackTrace] (function name, then the file and line indented by a tab),
+// followed by the folded repeat count and source snippet when present.
+//
+// Parameters:
+//   - `frames`: The frames to render.
+//
+// Returns:
+//   - A multi-line string, or an empty string if frames is empty.
+func FormatStackFrames(frames []StackFrame) string {
+	var sb strings.Builder
+	for i, f := range frames {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		fmt.Fprintf(&sb, "%s\n\t%s:%d", f.Func, f.File, f.Line)
+		if f.Count > 1 {
+			fmt.Fprintf(&sb, " (x%d)", f.Count)
+		}
+		for _, src := range f.Source {
+			marker := " "
+			if src.Current {
+				marker = ">"
+			}
+			fmt.Fprintf(&sb, "\n\t%s %5d | %s", marker, src.Line, src.Text)
+		}
+	}
+	return sb.String()
+}
+
+// StackTraceStringWith returns the stack trace of the stored error rendered
+// with the given [StackOptions] (see [FormatStackFrames]).
+//
+// Parameters:
+//   - `opts`: The capture options; nil uses [NewStackOptions].
+//
+// Returns:
+//   - A multi-line string, or an empty string when no stack-aware error is present.
+func (w *wrapper) StackTraceStringWith(opts *StackOptions) string {
+	return FormatStackFrames(w.StackTrace().FramesWith(opts))
+}
+
+// WithStackTraceOptions captures the call stack at the point this method is
+// invoked, filtered by the given [StackOptions], and stores the frames in the
+// debug map under the key "stack_trace".
+//
+// This is the configurable counterpart of [wrapper.WithStackTrace]; frames are
+// stored as [StackFrame] objects so that source snippets survive serialization.
+//
+// Parameters:
+//   - `opts`: The capture options; nil uses [NewStackOptions].
+//
+// Returns:
+//   - A pointer to the modified [wrapper] instance (enabling method chaining).
+//
+// Example:
+//
+//	opts := replify.NewStackOptions()
+//	opts.SourceLines = 3
+//	w := replify.WrapInternalServerError("boom", nil).WithStackTraceOptions(opts)
+func (w *wrapper) WithStackTraceOptions(opts *StackOptions) *wrapper {
+	if !w.Available() {
+		return w
+	}
+	return w.WithDebuggingKV("stack_trace", callersN(3, 128).FramesWith(opts))
+}
+
+// InjectStackTraceOptions extracts the [StackTrace] from the stored error,
+// filters it with the given [StackOptions] and stores the frames in the debug
+// map under the key "error_stack_trace". It is a no-op when no error is set.
+//
+// This is the configurable counterpart of [wrapper.InjectStackTrace].
+//
+// Parameters:
+//   - `opts`: The capture options; nil uses [NewStackOptions].
+//
+// Returns:
+//   - A pointer to the modified [wrapper] instance (enabling method chaining).
+func (w *wrapper) InjectStackTraceOptions(opts *StackOptions) *wrapper {
+	if !w.Available() || !w.IsError() {
+		return w
+	}
+	return w.WithDebuggingKV("error_stack_trace", w.StackTrace().FramesWith(opts))
+}
+
+// allows reports whether a frame of package pkg passes the include/exclude filters.
+func (o *StackOptions) allows(pkg string) bool {
+	for _, pattern := range o.Exclude {
+		if packageMatches(pkg, pattern) {
+			return false
+		}
+	}
+	if len(o.Include) == 0 {
+		return true
+	}
+	for _, pattern := range o.Include {
+		if packageMatches(pkg, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// trimPrefixes returns the user-defined prefixes followed by the GOPATH
+// related ones, longest (most specific) first for the latter.
+func (o *StackOptions) trimPrefixes() []string {
+	prefixes := append([]string(nil), o.TrimPrefixes...)
+	if !o.TrimGOPATH {
+		return prefixes
+	}
+	if modCache := os.Getenv("GOMODCACHE"); modCache != "" {
+		prefixes = append(prefixes, filepath.ToSlash(modCache)+"/")
+	}
+	for _, gopath := range filepath.SplitList(build.Default.GOPATH) {
+		gopath = filepath.ToSlash(gopath)
+		prefixes = append(prefixes, gopath+"/pkg/mod/", gopath+"/src/")
+	}
+	if build.Default.GOROOT != "" {
+		prefixes = append(prefixes, filepath.ToSlash(build.Default.GOROOT)+"/src/")
+	}
+	return prefixes
+}
+
+// callersN captures up to depth program counters, skipping `skip` frames.
+func callersN(skip, depth int) StackTrace {
+	pcs := make([]uintptr, depth)
+	n := runtime.Callers(skip, pcs)
+	st := make(StackTrace, n)
+	for i := range st {
+		st[i] = Frame(pcs[i])
+	}
+	return st
+}
+
+// framePackage extracts the package import path from a fully qualified
+// function name such as "github.com/acme/app/svc.(*Server).Handle".
+func framePackage(fn string) string {
+	slash := strings.LastIndex(fn, "/")
+	dot := strings.Index(fn[slash+1:], ".")
+	if dot < 0 {
+		return fn
+	}
+	return fn[:slash+1+dot]
+}
+
+// packageMatches reports whether pkg matches pattern: a wildcard pattern is
+// matched as a whole, otherwise pattern must equal pkg or be a path prefix of it.
+func packageMatches(pkg, pattern string) bool {
+	if pattern == "" {
+		return false
+	}
+	if strings.ContainsAny(pattern, "*?") {
+		return match.Match(pkg, pattern)
+	}
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(pkg, pattern)
+	}
+	return pkg == pattern || strings.HasPrefix(pkg, pattern+"/")
+}
+
+// trimFilePrefix strips the first matching prefix from file.
+func trimFilePrefix(file string, prefixes []string) string {
+	for _, prefix := range prefixes {
+		if prefix != "" && strings.HasPrefix(file, prefix) {
+			return strings.TrimPrefix(file, prefix)
+		}
+	}
+	return file
+}
+
+// sourceContext returns up to n lines before and after line in file. Files
+// are read once per capture and cached in cache; unreadable files yield nil.
+func sourceContext(cache map[string][]string, file string, line, n int) []SourceLine {
+	lines, ok := cache[file]
+	if !ok {
+		lines = readSourceLines(file)
+		cache[file] = lines
+	}
+	if line <= 0 || line > len(lines) {
+		return nil
+	}
+	from, to := max(1, line-n), min(len(lines), line+n)
+	snippet := make([]SourceLine, 0, to-from+1)
+	for i := from; i <= to; i++ {
+		snippet = append(snippet, SourceLine{Line: i, Text: lines[i-1], Current: i == line})
+	}
+	return snippet
+}
+
+// readSourceLines reads a source file line by line, returning nil if the
+// file is not available (e.g. the binary runs away from its sources).
+func readSourceLines(file string) []string {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
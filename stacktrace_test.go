@@ -0,0 +1,71 @@
+package replify_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sivaosorg/replify"
+)
+
+func recurse(n int, opts *replify.StackOptions) []replify.StackFrame {
+	if n == 0 {
+		return replify.CaptureStack(opts)
+	}
+	return recurse(n-1, opts)
+}
+
+func TestCaptureStack_FilterCollapseAndSource(t *testing.T) {
+	t.Parallel()
+
+	opts := replify.NewStackOptions()
+	opts.Include = []string{"github.com/sivaosorg/replify_test"}
+	opts.SourceLines = 1
+
+	frames := recurse(5, opts)
+	if len(frames) == 0 {
+		t.Fatal("expected at least one frame")
+	}
+	for _, f := range frames {
+		if !strings.HasPrefix(f.Func, "github.com/sivaosorg/replify_test.") {
+			t.Errorf("frame %q should have been filtered out", f.Func)
+		}
+	}
+	if frames[0].Count != 0 || len(frames[0].Source) == 0 {
+		t.Errorf("expected the first frame to carry source context, got %+v", frames[0])
+	}
+	if frames[1].Count != 5 {
+		t.Errorf("expected the recursive frames to be folded, got count %d", frames[1].Count)
+	}
+	if !strings.Contains(replify.FormatStackFrames(frames), "(x5)") {
+		t.Error("expected the folded count in the formatted output")
+	}
+}
+
+func TestInjectStackTraceOptions_Depth(t *testing.T) {
+	t.Parallel()
+
+	opts := replify.NewStackOptions()
+	opts.Depth = 1
+	w := replify.WrapInternalServerError("boom", nil).
+		WithErrorAck(replify.NewError("boom")).
+		InjectStackTraceOptions(opts)
+
+	frames, ok := w.Debugging()["error_stack_trace"].([]replify.StackFrame)
+	if !ok || len(frames) != 1 {
+		t.Fatalf("expected exactly one frame, got %#v", w.Debugging()["error_stack_trace"])
+	}
+}
+
+func TestWithStackTraceOptions_TopFrame(t *testing.T) {
+	t.Parallel()
+
+	want := "github.com/sivaosorg/replify_test.TestWithStackTraceOptions_TopFrame"
+	frames, ok := replify.Wrap(replify.WithStackTraceOptions(nil)).Debugging()["stack_trace"].([]replify.StackFrame)
+	if !ok || len(frames) == 0 || frames[0].Func != want {
+		t.Fatalf("option: top frame = %+v, want %s", frames, want)
+	}
+	frames, ok = replify.New().WithStackTraceOptions(nil).Debugging()["stack_trace"].([]replify.StackFrame)
+	if !ok || len(frames) == 0 || frames[0].Func != want {
+		t.Fatalf("method: top frame = %+v, want %s", frames, want)
+	}
+}
@@ -1,13 +1,12 @@
 package replify
 
 import (
-	"bytes"
-	"compress/flate"
-	"compress/gzip"
 	"context"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
+	"maps"
 	"net/http"
 	"sync"
 	"sync/atomic"
@@ -431,6 +430,33 @@ func (sw *StreamingWrapper) Start(ctx context.Context) *wrapper {
 	sw.isStreaming = true
 	sw.mu.Unlock()
 
+	// Leave the registry tracking the stream, if any, once done
+	defer sw.unregister()
+
+	// Never let an encrypted stream go out in the clear
+	if sw.keys != nil && (!sw.config.Framed || (sw.config.Strategy != StrategyChunked && sw.config.Strategy != StrategyContentDefined)) {
+		sw.mu.Lock()
+		sw.isStreaming = false
+		sw.mu.Unlock()
+		return sw.wrapper.
+			WithStatusCode(http.StatusBadRequest).
+			WithMessage("encryption requires the framed chunked strategy").
+			BindCause()
+	}
+
+	// Restart from the checkpoint of an interrupted transfer, if any
+	if err := sw.restoreCheckpoint(); err != nil {
+		sw.mu.Lock()
+		sw.isStreaming = false
+		sw.mu.Unlock()
+		sw.recordError(err)
+		return sw.wrapper.
+			WithErrorAck(err).
+			WithStatusCode(http.StatusConflict)
+	}
+
+	sw.startManifest()
+
 	// Update status
 	sw.wrapper.
 		WithMessage("Streaming started").
@@ -441,6 +467,11 @@ func (sw *StreamingWrapper) Start(ctx context.Context) *wrapper {
 		ctx = sw.ctx
 	}
 
+	// Write to every destination, when several are set
+	ctx, stopFanout := sw.startFanout(ctx)
+	defer stopFanout()
+	defer sw.releaseBandwidth()
+
 	var streamErr error
 
 	// Check if we're sending (compressing) or receiving (decompressing)
@@ -452,7 +483,7 @@ func (sw *StreamingWrapper) Start(ctx context.Context) *wrapper {
 			streamErr = sw.streamReceiveDirect(ctx)
 		case StrategyBuffered:
 			streamErr = sw.streamReceiveBuffered(ctx)
-		case StrategyChunked:
+		case StrategyChunked, StrategyContentDefined:
 			streamErr = sw.streamReceiveChunked(ctx)
 		default:
 			streamErr = NewErrorf("unknown streaming strategy: %s", string(sw.config.Strategy))
@@ -463,13 +494,20 @@ func (sw *StreamingWrapper) Start(ctx context.Context) *wrapper {
 			streamErr = sw.streamDirect(ctx)
 		case StrategyBuffered:
 			streamErr = sw.streamBuffered(ctx)
-		case StrategyChunked:
+		case StrategyChunked, StrategyContentDefined:
 			streamErr = sw.streamChunked(ctx)
 		default:
 			streamErr = NewErrorf("unknown streaming strategy: %s", string(sw.config.Strategy))
 		}
 	}
 
+	if err := sw.finishFanout(); err != nil {
+		streamErr = err
+	}
+	if streamErr == nil && ctx.Err() == nil {
+		streamErr = sw.completeCheckpoint()
+	}
+
 	sw.mu.Lock()
 	sw.isStreaming = false
 	sw.mu.Unlock()
@@ -488,10 +526,15 @@ func (sw *StreamingWrapper) Start(ctx context.Context) *wrapper {
 	}
 
 	// Success response
+	if ctx.Err() == nil {
+		sw.finishManifest()
+	}
 	if sw.stats.EndTime.IsZero() {
 		sw.stats.EndTime = time.Now()
 	}
+	sw.mu.Lock()
 	sw.progress.Percentage = 100
+	sw.mu.Unlock()
 
 	sw.wrapper.
 		WithStatusCode(http.StatusOK).
@@ -926,6 +969,12 @@ func (sw *StreamingWrapper) WithCompressionType(comp CompressionType) *wrapper {
 			WithMessage("Invalid compression type: cannot be empty").
 			BindCause()
 	}
+	if !isCompressionSupported(comp) {
+		return sw.wrapper.
+			WithStatusCode(http.StatusBadRequest).
+			WithMessagef("Invalid compression type: %q is not a registered codec", string(comp)).
+			BindCause()
+	}
 
 	sw.config.Compression = comp
 	sw.wrapper.WithDebuggingKV("compression_type", string(comp))
@@ -5475,6 +5524,7 @@ func (sw *StreamingWrapper) GetStats() *StreamingStats {
 
 	// Return a copy
 	stats := *sw.stats
+	stats.Destinations = maps.Clone(sw.stats.Destinations)
 	return &stats
 }
 
@@ -6558,7 +6608,7 @@ func (sw *StreamingWrapper) GetStreamingProgress() *StreamProgress {
 //	Scenario                                    Behavior                        Effect
 //	──────────────────────────────────────────────────────────────────────────────
 //	Compression succeeds                        Use compressed data             Normal flow
-//	Compression fails                           recordError, continue           Skip chunk
+//	Compression fails                           recordError, FailedChunks++     Skip chunk
 //	COMP_NONE configured                        No compression attempt          Normal flow
 //	Chunk error set                             chunk.Error = compErr           Track error
 //
@@ -6842,9 +6892,12 @@ func (sw *StreamingWrapper) streamDirect(ctx context.Context) error {
 			return fmt.Errorf("streaming cancelled: %w", ctx.Err())
 		default:
 		}
+		if err := sw.waitIfPaused(ctx); err != nil {
+			return fmt.Errorf("streaming cancelled: %w", err)
+		}
 
 		// Read chunk
-		n, err := sw.reader.Read(buffer)
+		n, err := sw.readChunk(ctx, buffer, sw.currentChunk)
 
 		if n > 0 {
 			chunk := &StreamChunk{
@@ -6854,13 +6907,17 @@ func (sw *StreamingWrapper) streamDirect(ctx context.Context) error {
 				Timestamp:       time.Now(),
 				CompressionType: sw.config.Compression,
 			}
+			raw := chunk.Data
 
 			// Apply compression if configured
 			if sw.config.Compression != CompressNone {
 				compData, compErr := sw.compressChunk(chunk)
 				if compErr != nil {
+					compErr = &ChunkError{Sequence: chunk.SequenceNumber, Op: ChunkOpProcess, Attempt: 1, Err: compErr}
 					sw.recordError(compErr)
+					sw.stats.FailedChunks++
 					chunk.Error = compErr
+					sw.acknowledge(chunk.SequenceNumber, raw, compErr)
 					continue
 				}
 				chunk.Data = compData
@@ -6873,13 +6930,15 @@ func (sw *StreamingWrapper) streamDirect(ctx context.Context) error {
 
 			// Write chunk if writer is set
 			if sw.writer != nil {
-				if _, writeErr := sw.writer.Write(chunk.Data); writeErr != nil {
+				if writeErr := sw.writeChunk(ctx, chunk); writeErr != nil {
 					sw.recordError(writeErr)
 					sw.stats.FailedChunks++
 					chunk.Error = writeErr
+					sw.acknowledge(chunk.SequenceNumber, raw, writeErr)
 					continue
 				}
 			}
+			sw.acknowledge(chunk.SequenceNumber, raw, nil)
 
 			// Update progress
 			sw.updateProgress(chunk)
@@ -7247,7 +7306,7 @@ func (sw *StreamingWrapper) streamDirect(ctx context.Context) error {
 //	Strategy               Concurrency    Throughput    Memory     Complexity    Best For
 //	──────────────────────────────────────────────────────────────────────────────────
 //	STRATEGY_BUFFERED      High (workers) Highest       Medium     Complex       Bulk/high-speed
-//	STRATEGY_CHUNKED       None (serial)  Lower         Low        Simple        Detail/control
+//	STRATEGY_CHUNKED       High (ordered) High          Bounded    Moderate      Detail/control
 //	STRATEGY_DIRECT        None (serial)  Lowest        Very low   Very simple   Simplicity
 //
 // Related Methods and Integration:
@@ -7303,8 +7362,15 @@ func (sw *StreamingWrapper) streamBuffered(ctx context.Context) error {
 				return
 			default:
 			}
+			if err := sw.waitIfPaused(ctx); err != nil {
+				select {
+				case errChan <- err:
+				default:
+				}
+				return
+			}
 
-			n, err := sw.reader.Read(buffer)
+			n, err := sw.readChunk(ctx, buffer, sw.currentChunk)
 
 			if n > 0 {
 				data := make([]byte, n)
@@ -7320,7 +7386,9 @@ func (sw *StreamingWrapper) streamBuffered(ctx context.Context) error {
 
 				select {
 				case chunkChan <- chunk:
+					sw.mu.Lock()
 					sw.currentChunk++
+					sw.mu.Unlock()
 				case <-ctx.Done():
 					return
 				}
@@ -7353,34 +7421,43 @@ func (sw *StreamingWrapper) streamBuffered(ctx context.Context) error {
 				}
 
 				// Process chunk
+				raw := chunk.Data
 				if sw.config.Compression != CompressNone {
 					compData, compErr := sw.compressChunk(chunk)
 					if compErr != nil {
+						compErr = &ChunkError{Sequence: chunk.SequenceNumber, Op: ChunkOpProcess, Attempt: 1, Err: compErr}
 						sw.recordError(compErr)
+						sw.mu.Lock()
 						sw.stats.FailedChunks++
+						sw.mu.Unlock()
 						chunk.Error = compErr
 					} else {
 						chunk.Data = compData
 						chunk.Compressed = true
+						sw.mu.Lock()
 						sw.stats.CompressedBytes += int64(len(compData))
+						sw.mu.Unlock()
 					}
 				}
 
 				chunk.Checksum = sw.calculateChecksum(chunk.Data)
 
 				if sw.writer != nil {
-					if _, writeErr := sw.writer.Write(chunk.Data); writeErr != nil {
+					if writeErr := sw.writeChunk(ctx, chunk); writeErr != nil {
 						sw.recordError(writeErr)
+						sw.mu.Lock()
 						sw.stats.FailedChunks++
+						sw.mu.Unlock()
 						chunk.Error = writeErr
 					}
 				}
+				sw.acknowledge(chunk.SequenceNumber, raw, chunk.Error)
 
 				sw.updateProgress(chunk)
 
 				if sw.config.ThrottleRate > 0 {
 					elapsed := time.Since(sw.stats.StartTime)
-					expectedTime := time.Duration(float64(sw.progress.TransferredBytes) / float64(sw.config.ThrottleRate) * float64(time.Second))
+					expectedTime := time.Duration(float64(atomic.LoadInt64(&sw.progress.TransferredBytes)) / float64(sw.config.ThrottleRate) * float64(time.Second))
 					if elapsed < expectedTime {
 						time.Sleep(expectedTime - elapsed)
 					}
@@ -7407,16 +7484,17 @@ func (sw *StreamingWrapper) streamBuffered(ctx context.Context) error {
 // streamChunked performs chunked streaming with explicit control over chunk boundaries and processing.
 //
 // This function implements the STRATEGY_CHUNKED streaming strategy, which provides fine-grained control over
-// individual chunk processing. Each chunk is explicitly read, optionally compressed, checksum, written, and
-// progressed independently. This strategy is useful for scenarios requiring per-chunk validation, compression
-// verification, or precise error handling at the chunk level. streamChunked reads data in fixed-size chunks from
-// the input reader, applies optional compression, calculates checksums for data integrity, writes compressed data
-// to the output writer, and updates progress metrics after each successful chunk. Chunk errors (read, write,
-// compression) are recorded individually without stopping the entire stream; streaming continues with the next
-// chunk attempt. Context cancellation is checked at the start of each chunk iteration, allowing responsive shutdown.
-// Read and write operations respect configured timeouts (ReadTimeout, WriteTimeout) to prevent indefinite blocking
-// on slow or stalled connections. This is a comprehensive strategy suitable for production scenarios requiring
-// detailed chunk-level diagnostics and fine control over the streaming lifecycle.
+// individual chunk processing. Each chunk is explicitly read, transformed, optionally compressed, checksum,
+// written, and progressed independently. Transformation, compression and checksumming run concurrently on a
+// pool of MaxConcurrentChunks workers (see runChunkPipeline) while chunks are written in sequence order. This
+// strategy is useful for scenarios requiring per-chunk validation, compression verification, or precise error
+// handling at the chunk level. streamChunked reads data in fixed-size chunks from the input reader, applies
+// optional compression, calculates checksums for data integrity, writes compressed data to the output writer,
+// and updates progress metrics after each successful chunk. Chunk errors (transform, write, compression) are
+// recorded individually without stopping the entire stream, except in a framed stream, where the first failed
+// chunk stops the run. Context cancellation stops the reader of the pipeline and the chunks still in flight are
+// not written, allowing responsive shutdown. This is a comprehensive strategy suitable for production scenarios
+// requiring detailed chunk-level diagnostics and fine control over the streaming lifecycle.
 //
 // Parameters:
 //   - ctx: Context for cancellation, timeouts, and coordination.
@@ -7431,8 +7509,10 @@ func (sw *StreamingWrapper) streamBuffered(ctx context.Context) error {
 //     Other errors during critical operations.
 //
 // Behavior:
-//   - Per-chunk: reads, compresses, checksums, writes, and progresses one chunk at a time.
-//   - Error-tolerant: continues streaming despite per-chunk errors.
+//   - Per-chunk: reads, compresses, checksums, writes, and progresses each chunk independently.
+//   - Parallel: up to MaxConcurrentChunks chunks are processed at once; output order is preserved.
+//   - Bounded: at most 2 × MaxConcurrentChunks pooled buffers are in flight (backpressure).
+//   - Error-tolerant: continues streaming despite per-chunk errors; a failed chunk is not written.
 //   - Context-aware: checks ctx.Done() before each chunk.
 //   - Timeout-aware: respects configured ReadTimeout and WriteTimeout values.
 //   - Compression-aware: optionally compresses chunks and tracks compressed bytes.
@@ -7611,7 +7691,7 @@ func (sw *StreamingWrapper) streamBuffered(ctx context.Context) error {
 //
 //	Strategy               Per-Chunk Control    Parallelism    Overhead    Best For
 //	───────────────────────────────────────────────────────────────────────────────
-//	STRATEGY_CHUNKED       Explicit (high)      None (serial)  Moderate    Detail/control
+//	STRATEGY_CHUNKED       Explicit (high)      Yes (ordered)  Moderate    Detail/control
 //	STRATEGY_BUFFERED      Implicit             Yes (parallel) Low         Throughput
 //	STRATEGY_DIRECT        Minimal              None (serial)  Minimal     Simplicity
 //
@@ -7685,71 +7765,41 @@ func (sw *StreamingWrapper) streamBuffered(ctx context.Context) error {
 //   - GetStats: Query final statistics including failed chunks
 //   - Start: Entry point that calls streamChunked
 func (sw *StreamingWrapper) streamChunked(ctx context.Context) error {
-	sw.stats.StartTime = time.Now()
-	buffer := make([]byte, sw.config.ChunkSize)
-
-	chunkNumber := int64(0)
-	for {
-		select {
-		case <-ctx.Done():
-			return fmt.Errorf("streaming cancelled: %w", ctx.Err())
-		default:
+	sw.startFrames()
+	if err := sw.startSeal(ctx); err != nil {
+		sw.recordError(err)
+		return err
+	}
+	sw.cdc = nil
+	if sw.config.Strategy == StrategyContentDefined {
+		sw.cdc = NewCDCChunker(sw.reader, int(sw.config.ChunkSize))
+	}
+	err := sw.runChunkPipeline(ctx, func(chunk *StreamChunk) error {
+		if err := sw.applyTransforms(chunk); err != nil {
+			return err
 		}
-
-		// Read chunk (ReadTimeout is configuration for timeout behavior)
-		n, err := sw.reader.Read(buffer)
-
-		if n > 0 {
-			chunk := &StreamChunk{
-				SequenceNumber:  chunkNumber,
-				Data:            buffer[:n],
-				Size:            int64(n),
-				Timestamp:       time.Now(),
-				CompressionType: sw.config.Compression,
-			}
-
-			// Compress if needed
-			if sw.config.Compression != CompressNone {
-				compData, compErr := sw.compressChunk(chunk)
-				if compErr != nil {
-					sw.recordError(compErr)
-					sw.stats.FailedChunks++
-					chunk.Error = compErr
-				} else {
-					chunk.Data = compData
-					chunk.Compressed = true
-					sw.stats.CompressedBytes += int64(len(compData))
-				}
-			}
-
+		if sw.dedupChunk(chunk) {
+			sw.sealChunk(chunk)
 			chunk.Checksum = sw.calculateChecksum(chunk.Data)
-
-			if sw.writer != nil {
-				// Write chunk (WriteTimeout is configuration for timeout behavior)
-				if _, writeErr := sw.writer.Write(chunk.Data); writeErr != nil {
-					chunk.Error = writeErr
-					sw.recordError(writeErr)
-					sw.stats.FailedChunks++
-				}
-			}
-
-			sw.updateProgress(chunk)
-			chunkNumber++
-		}
-
-		if err == io.EOF {
-			break
+			return nil
 		}
-
-		if err != nil {
-			sw.recordError(err)
-			sw.stats.FailedChunks++
-			return fmt.Errorf("read error: %w", err)
+		if sw.config.Compression != CompressNone {
+			compData, err := sw.compressChunk(chunk)
+			if err != nil {
+				return err
+			}
+			chunk.Data = compData
+			chunk.Compressed = true
+			atomic.AddInt64(&sw.stats.CompressedBytes, int64(len(compData)))
 		}
+		sw.sealChunk(chunk)
+		chunk.Checksum = sw.calculateChecksum(chunk.Data)
+		return nil
+	})
+	if err != nil {
+		return err
 	}
-
-	sw.stats.EndTime = time.Now()
-	return nil
+	return sw.finishFrames(ctx)
 }
 
 // streamReceiveDirect performs direct receiving with decompression of streamed data.
@@ -7843,7 +7893,7 @@ func (sw *StreamingWrapper) streamReceiveDirect(ctx context.Context) error {
 		}
 
 		// Read compressed chunk
-		n, err := sw.reader.Read(buffer)
+		n, err := sw.readChunk(ctx, buffer, sw.currentChunk)
 
 		if n > 0 {
 			chunk := &StreamChunk{
@@ -7858,6 +7908,7 @@ func (sw *StreamingWrapper) streamReceiveDirect(ctx context.Context) error {
 			if sw.config.Compression != CompressNone {
 				decData, decErr := sw.decompressChunk(chunk)
 				if decErr != nil {
+					decErr = &ChunkError{Sequence: chunk.SequenceNumber, Op: ChunkOpProcess, Attempt: 1, Err: decErr}
 					sw.recordError(decErr)
 					sw.stats.FailedChunks++
 					chunk.Error = decErr
@@ -7872,13 +7923,14 @@ func (sw *StreamingWrapper) streamReceiveDirect(ctx context.Context) error {
 
 			// Write decompressed chunk if writer is set
 			if sw.writer != nil {
-				if _, writeErr := sw.writer.Write(chunk.Data); writeErr != nil {
+				if writeErr := sw.writeChunk(ctx, chunk); writeErr != nil {
 					sw.recordError(writeErr)
 					sw.stats.FailedChunks++
 					chunk.Error = writeErr
 					continue
 				}
 			}
+			sw.acknowledge(chunk.SequenceNumber, chunk.Data, nil)
 
 			// Update progress
 			sw.updateProgress(chunk)
@@ -7931,7 +7983,8 @@ func (sw *StreamingWrapper) streamReceiveDirect(ctx context.Context) error {
 //   - error: nil if streaming completed successfully, error otherwise.
 //
 // Behavior:
-//   - Per-chunk: reads, decompresses, checksums, writes, and progresses one chunk at a time.
+//   - Per-chunk: reads, decompresses, checksums, writes, and progresses each chunk independently.
+//   - Parallel: up to MaxConcurrentChunks chunks are decompressed at once; output order is preserved.
 //   - Error-tolerant: continues streaming despite per-chunk errors.
 //   - Context-aware: checks ctx.Done() before each chunk.
 //   - Decompression-capable: reverses compression using configured algorithm.
@@ -7969,71 +8022,45 @@ func (sw *StreamingWrapper) streamReceiveDirect(ctx context.Context) error {
 //   - streamReceiveBuffered: Buffered receive strategy
 //   - decompressChunk: Decompression function used here
 func (sw *StreamingWrapper) streamReceiveChunked(ctx context.Context) error {
-	sw.stats.StartTime = time.Now()
-	buffer := make([]byte, sw.config.ChunkSize)
-
-	chunkNumber := int64(0)
-	for {
-		select {
-		case <-ctx.Done():
-			return fmt.Errorf("streaming cancelled: %w", ctx.Err())
-		default:
+	sw.startFrames()
+	err := sw.runChunkPipeline(ctx, func(chunk *StreamChunk) error {
+		// Verify the frame before decoding its payload
+		if err := sw.verifyFrame(chunk); err != nil {
+			return err
+		}
+		if err := sw.openChunk(chunk); err != nil {
+			return err
 		}
 
-		// Read compressed chunk
-		n, err := sw.reader.Read(buffer)
-
-		if n > 0 {
-			chunk := &StreamChunk{
-				SequenceNumber:  chunkNumber,
-				Data:            buffer[:n],
-				Size:            int64(n),
-				Timestamp:       time.Now(),
-				CompressionType: sw.config.Compression,
-			}
-
-			// Decompress chunk
-			if sw.config.Compression != CompressNone {
-				decData, decErr := sw.decompressChunk(chunk)
-				if decErr != nil {
-					sw.recordError(decErr)
-					sw.stats.FailedChunks++
-					chunk.Error = decErr
-				} else {
-					chunk.Data = decData
-					chunk.Compressed = false
-				}
-			}
-
-			// Calculate checksum on decompressed data
-			chunk.Checksum = sw.calculateChecksum(chunk.Data)
+		// References are resolved in sequence order by the pipeline
+		if chunk.Deduplicated {
+			return nil
+		}
 
-			// Write decompressed chunk if writer is set
-			if sw.writer != nil {
-				if _, writeErr := sw.writer.Write(chunk.Data); writeErr != nil {
-					chunk.Error = writeErr
-					sw.recordError(writeErr)
-					sw.stats.FailedChunks++
-				}
+		// Decompress chunk
+		if chunk.Compressed {
+			decData, err := sw.decompressChunk(chunk)
+			if err != nil {
+				return err
 			}
-
-			sw.updateProgress(chunk)
-			chunkNumber++
+			chunk.Data = decData
+			chunk.Compressed = false
 		}
-
-		if err == io.EOF {
-			break
+		if err := sw.storeChunk(chunk); err != nil {
+			return err
 		}
-
-		if err != nil {
-			sw.recordError(err)
-			sw.stats.FailedChunks++
-			return fmt.Errorf("read error: %w", err)
+		if err := sw.applyTransforms(chunk); err != nil {
+			return err
 		}
-	}
 
-	sw.stats.EndTime = time.Now()
-	return nil
+		// Calculate checksum on decompressed data
+		chunk.Checksum = sw.calculateChecksum(chunk.Data)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return sw.finishFrames(ctx)
 }
 
 // streamReceiveBuffered performs buffered receiving with concurrent decompression.
@@ -8185,7 +8212,7 @@ func (sw *StreamingWrapper) streamReceiveBuffered(ctx context.Context) error {
 			default:
 			}
 
-			n, err := sw.reader.Read(buffer)
+			n, err := sw.readChunk(ctx, buffer, sw.currentChunk)
 
 			if n > 0 {
 				data := make([]byte, n)
@@ -8237,6 +8264,7 @@ func (sw *StreamingWrapper) streamReceiveBuffered(ctx context.Context) error {
 				if sw.config.Compression != CompressNone {
 					decData, decErr := sw.decompressChunk(chunk)
 					if decErr != nil {
+						decErr = &ChunkError{Sequence: chunk.SequenceNumber, Op: ChunkOpProcess, Attempt: 1, Err: decErr}
 						sw.recordError(decErr)
 						sw.stats.FailedChunks++
 						chunk.Error = decErr
@@ -8251,12 +8279,13 @@ func (sw *StreamingWrapper) streamReceiveBuffered(ctx context.Context) error {
 
 				// Write decompressed chunk
 				if sw.writer != nil {
-					if _, writeErr := sw.writer.Write(chunk.Data); writeErr != nil {
+					if writeErr := sw.writeChunk(ctx, chunk); writeErr != nil {
 						sw.recordError(writeErr)
 						sw.stats.FailedChunks++
 						chunk.Error = writeErr
 					}
 				}
+				sw.acknowledge(chunk.SequenceNumber, chunk.Data, chunk.Error)
 
 				sw.updateProgress(chunk)
 
@@ -8290,7 +8319,8 @@ func (sw *StreamingWrapper) streamReceiveBuffered(ctx context.Context) error {
 // compressChunk compresses chunk data using the configured compression algorithm before transmission.
 //
 // This function applies compression to chunk data to reduce bandwidth consumption and transfer time. It supports
-// multiple compression algorithms (GZIP, DEFLATE) with automatic selection based on the streaming configuration.
+// every codec of the codec registry (see RegisterCodec): the built-in GZIP, DEFLATE (zlib) and FLATE (raw DEFLATE)
+// codecs and any codec registered by the application, selected by name from the streaming configuration.
 // For uncompressed transfers (COMP_NONE), the original data is returned unchanged. compressChunk is called during
 // chunk processing to compress data before transmission, reducing network payload size and improving throughput on
 // bandwidth-limited connections. The compression process creates appropriate writers (gzip.Writer or flate.Writer)
@@ -8330,7 +8360,9 @@ func (sw *StreamingWrapper) streamReceiveBuffered(ctx context.Context) error {
 //	──────────────────────────────────────────────────────────────────────────────
 //	COMP_NONE           No-op (return data)         N/A                Uncompressed/pre-compressed
 //	COMP_GZIP           compress/gzip.NewWriter     RFC 1952           Text, JSON, logs, general
-//	COMP_DEFLATE        compress/flate.NewWriter    RFC 1951           Speed, mixed content
+//	COMP_DEFLATE        compress/zlib.NewWriter     RFC 1950           HTTP "deflate" coding
+//	COMP_FLATE          compress/flate.NewWriter    RFC 1951           Speed, mixed content
+//	Registered          Codec.NewWriter             Codec-defined      Application codecs
 //	Unknown             Fallback (no-op)            N/A                Unknown/unsupported
 //
 // Compression Process Flow:
@@ -8688,42 +8720,17 @@ func (sw *StreamingWrapper) compressChunk(chunk *StreamChunk) ([]byte, error) {
 	if chunk == nil {
 		return nil, errors.New("chunk is nil")
 	}
-
-	var buf bytes.Buffer
-
-	switch sw.config.Compression {
-	case CompressGzip:
-		gzipWriter := gzip.NewWriter(&buf)
-		if _, err := gzipWriter.Write(chunk.Data); err != nil {
-			return nil, fmt.Errorf("gzip compression failed: %w", err)
-		}
-		if err := gzipWriter.Close(); err != nil {
-			return nil, fmt.Errorf("gzip close failed: %w", err)
-		}
-
-	case CompressFlate:
-		deflateWriter, err := flate.NewWriter(&buf, flate.DefaultCompression)
-		if err != nil {
-			return nil, fmt.Errorf("flate writer creation failed: %w", err)
-		}
-		if _, err := deflateWriter.Write(chunk.Data); err != nil {
-			return nil, fmt.Errorf("flate compression failed: %w", err)
-		}
-		if err := deflateWriter.Close(); err != nil {
-			return nil, fmt.Errorf("flate close failed: %w", err)
-		}
-
-	default:
+	name := string(sw.config.Compression)
+	if _, _, ok := LookupCodec(name); !ok {
 		return chunk.Data, nil
 	}
-
-	return buf.Bytes(), nil
+	return CompressBytes(name, chunk.Data)
 }
 
 // decompressChunk decompresses chunk data using the configured compression algorithm.
 //
 // This function reverses the compression applied to chunk data, restoring the original uncompressed content.
-// It supports multiple compression algorithms (GZIP, DEFLATE) with automatic selection based on the chunk's
+// It supports every codec of the codec registry (see RegisterCodec) with automatic selection based on the chunk's
 // CompressionType field. For uncompressed data (COMP_NONE), the original data is returned unchanged. decompressChunk
 // is called during chunk processing to restore data that was compressed during transfer. The decompression process
 // creates appropriate readers (gzip.Reader or deflate.Reader) that decode the compressed bytes into a buffer and
@@ -9066,44 +9073,17 @@ func (sw *StreamingWrapper) decompressChunk(chunk *StreamChunk) ([]byte, error)
 	if chunk == nil {
 		return nil, errors.New("chunk is nil")
 	}
-
-	switch chunk.CompressionType {
-	case CompressGzip:
-		gzipReader, err := gzip.NewReader(bytes.NewReader(chunk.Data))
-		if err != nil {
-			return nil, fmt.Errorf("gzip decompression failed: %w", err)
-		}
-		defer gzipReader.Close()
-
-		var buf bytes.Buffer
-		if _, err := io.Copy(&buf, gzipReader); err != nil {
-			return nil, fmt.Errorf("gzip copy failed: %w", err)
-		}
-		return buf.Bytes(), nil
-
-	case CompressFlate:
-		deflateReader := flate.NewReader(bytes.NewReader(chunk.Data))
-		defer deflateReader.Close()
-
-		var buf bytes.Buffer
-		if _, err := io.Copy(&buf, deflateReader); err != nil {
-			return nil, fmt.Errorf("flate copy failed: %w", err)
-		}
-		return buf.Bytes(), nil
-
-	default:
+	name := string(chunk.CompressionType)
+	if _, _, ok := LookupCodec(name); !ok {
 		return chunk.Data, nil
 	}
+	return DecompressBytes(name, chunk.Data)
 }
 
-// calculateChecksum calculates simple CRC32-like checksum
-// for given data slice.
+// calculateChecksum calculates the CRC-32 (IEEE) checksum
+// of the given data slice.
 func (sw *StreamingWrapper) calculateChecksum(data []byte) uint32 {
-	var crc uint32
-	for _, b := range data {
-		crc = ((crc >> 8) | ((crc & 0xFF) << 24)) ^ uint32(b)
-	}
-	return crc
+	return crc32.ChecksumIEEE(data)
 }
 
 // updateProgress updates streaming progress metrics after successful chunk transfer.
@@ -9497,6 +9477,16 @@ func (sw *StreamingWrapper) updateProgress(chunk *StreamChunk) {
 		}
 	}
 
+	// An archive is measured on the content of its entries
+	if sw.archive != nil {
+		archive := sw.archive.progress()
+		sw.progress.Archive = archive
+		if archive.TotalBytes > 0 && archive.Bytes > 0 {
+			sw.progress.Percentage = int(min(archive.Bytes*100/archive.TotalBytes, 100))
+			sw.progress.EstimatedTimeRemaining = time.Duration(float64(elapsed) * float64(archive.TotalBytes-archive.Bytes) / float64(archive.Bytes))
+		}
+	}
+
 	sw.stats.TotalChunks = sw.currentChunk
 	sw.stats.TotalBytes = sw.progress.TransferredBytes
 	if sw.currentChunk > 0 {
@@ -9894,7 +9884,7 @@ func (sw *StreamingWrapper) updateProgress(chunk *StreamChunk) {
 //   - Cancel: Stops streaming and callback invocation
 func (sw *StreamingWrapper) fireCallback(err error) {
 	if sw.callback != nil {
-		sw.callback(sw.progress, err)
+		sw.callback(sw.GetProgress(), err)
 	}
 }
 
@@ -9911,7 +9901,7 @@ func (sw *StreamingWrapper) fireCallback(err error) {
 // callbacks; applications configure R-type callbacks via the high-level WithCallbackR() API.
 func (sw *StreamingWrapper) fireHook(w *R) {
 	if sw.hook != nil {
-		sw.hook(sw.progress, w)
+		sw.hook(sw.GetProgress(), w)
 	}
 }
 
@@ -0,0 +1,373 @@
+package replify
+
+import (
+	"context"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sivaosorg/replify/pkg/strutil"
+	"github.com/sivaosorg/replify/pkg/sysx"
+)
+
+// Pause suspends the streaming operation before its next chunk.
+//
+// The chunk in flight is completed; no further chunk is read until
+// [StreamingWrapper.Resume] is called. Cancelling the streaming context
+// while paused stops the operation. Pausing before [StreamingWrapper.Start]
+// holds the stream before its first chunk. Pause is idempotent and, like
+// [StreamingWrapper.Resume], safe to call from any goroutine.
+//
+// Returns:
+//   - A pointer to the underlying [wrapper] instance, allowing for method chaining.
+//   - If the streaming wrapper is nil, returns a new wrapper with an error message.
+//
+// Example:
+//
+//	streaming := replify.New().WithStreaming(file, nil)
+//	streaming.WithWriter(conn)
+//	go streaming.Start(ctx)
+//	streaming.Pause()   // e.g. while the peer is congested
+//	streaming.Resume()
+func (sw *StreamingWrapper) Pause() *wrapper {
+	if sw == nil {
+		return respondStreamBadRequestDefault()
+	}
+	sw.mu.Lock()
+	if sw.resumeCh == nil {
+		sw.resumeCh = make(chan struct{})
+	}
+	sw.mu.Unlock()
+	return sw.wrapper
+}
+
+// Resume continues a streaming operation suspended by [StreamingWrapper.Pause].
+// Resuming a stream that is not paused has no effect.
+//
+// Returns:
+//   - A pointer to the underlying [wrapper] instance, allowing for method chaining.
+//   - If the streaming wrapper is nil, returns a new wrapper with an error message.
+func (sw *StreamingWrapper) Resume() *wrapper {
+	if sw == nil {
+		return respondStreamBadRequestDefault()
+	}
+	sw.mu.Lock()
+	if sw.resumeCh != nil {
+		close(sw.resumeCh)
+		sw.resumeCh = nil
+	}
+	sw.mu.Unlock()
+	return sw.wrapper
+}
+
+// IsPaused reports whether the streaming operation is paused.
+//
+// Returns:
+//   - `true` between a call to [StreamingWrapper.Pause] and the matching [StreamingWrapper.Resume].
+func (sw *StreamingWrapper) IsPaused() bool {
+	if sw == nil {
+		return false
+	}
+	sw.mu.RLock()
+	defer sw.mu.RUnlock()
+	return sw.resumeCh != nil
+}
+
+// WithCheckpointFile enables checkpointing and persists the checkpoint to
+// the given file after every acknowledged chunk.
+//
+// If the file already holds a checkpoint, typically left by a transfer
+// interrupted by cancellation or a process restart, the next
+// [StreamingWrapper.Start] resumes from it: the reader, which must then be
+// an io.ReadSeeker, is verified against the checkpoint checksum and
+// positioned at the checkpoint offset. The file is removed once the
+// transfer completes successfully. Checkpoints are recorded by the sending
+// strategies only.
+//
+// Parameters:
+//   - `path`: The checkpoint file path.
+//
+// Returns:
+//   - A pointer to the underlying [wrapper] instance, allowing for method chaining.
+//   - If the streaming wrapper is nil, returns a new wrapper with an error message.
+//   - If the existing file cannot be read, the [wrapper] carries the error.
+//
+// Example:
+//
+//	file, _ := os.Open("backup.tar")
+//	streaming := replify.New().WithStreaming(file, nil)
+//	streaming.WithWriter(dst)
+//	streaming.WithCheckpointFile("backup.tar.ckpt")
+//	result := streaming.Start(ctx) // continues where the previous run stopped
+func (sw *StreamingWrapper) WithCheckpointFile(path string) *wrapper {
+	if sw == nil {
+		return respondStreamBadRequestDefault()
+	}
+	if strutil.IsEmpty(path) {
+		return sw.wrapper.
+			WithStatusCode(http.StatusBadRequest).
+			WithMessage("checkpoint file path is required").
+			BindCause()
+	}
+	cp := &streamCheckpointer{path: path}
+	if sysx.FileExists(path) {
+		state, err := LoadStreamCheckpoint(path)
+		if err != nil {
+			return sw.wrapper.
+				WithStatusCode(http.StatusBadRequest).
+				WithErrorAck(err)
+		}
+		cp.state = state
+	}
+	sw.mu.Lock()
+	sw.checkpoint = cp
+	sw.mu.Unlock()
+	sw.wrapper.WithDebuggingKV("checkpoint_file", path)
+	return sw.wrapper
+}
+
+// WithCheckpoint enables checkpointing and makes the next
+// [StreamingWrapper.Start] resume from the given checkpoint, e.g. one kept
+// by the caller from [StreamingWrapper.Checkpoint]. A zero checkpoint starts
+// from the beginning. The checkpoint file set by
+// [StreamingWrapper.WithCheckpointFile], if any, keeps being updated.
+//
+// Parameters:
+//   - `checkpoint`: The checkpoint to resume from.
+//
+// Returns:
+//   - A pointer to the underlying [wrapper] instance, allowing for method chaining.
+//   - If the streaming wrapper is nil, returns a new wrapper with an error message.
+func (sw *StreamingWrapper) WithCheckpoint(checkpoint StreamCheckpoint) *wrapper {
+	if sw == nil {
+		return respondStreamBadRequestDefault()
+	}
+	sw.mu.Lock()
+	if sw.checkpoint == nil {
+		sw.checkpoint = &streamCheckpointer{}
+	}
+	cp := sw.checkpoint
+	sw.mu.Unlock()
+	cp.mu.Lock()
+	cp.state = checkpoint
+	cp.mu.Unlock()
+	return sw.wrapper
+}
+
+// Checkpoint returns the current checkpoint of the streaming operation.
+//
+// Returns:
+//   - The [StreamCheckpoint] of the acknowledged chunks.
+//   - `false` if checkpointing is not enabled.
+func (sw *StreamingWrapper) Checkpoint() (StreamCheckpoint, bool) {
+	if sw == nil {
+		return StreamCheckpoint{}, false
+	}
+	sw.mu.RLock()
+	cp := sw.checkpoint
+	sw.mu.RUnlock()
+	if cp == nil {
+		return StreamCheckpoint{}, false
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return cp.state, true
+}
+
+// Metadata describes the streaming operation.
+//
+// The stream is always pausable; it is resumable when its reader is an
+// io.ReadSeeker, the requirement for restarting from a checkpoint.
+//
+// Returns:
+//   - The [StreamingMetadata] of the streaming operation.
+func (sw *StreamingWrapper) Metadata() StreamingMetadata {
+	if sw == nil {
+		return StreamingMetadata{}
+	}
+	sw.mu.RLock()
+	defer sw.mu.RUnlock()
+	_, seekable := sw.reader.(io.ReadSeeker)
+	return StreamingMetadata{
+		Strategy:           sw.config.Strategy,
+		CompressionType:    sw.config.Compression,
+		ChunkSize:          sw.config.ChunkSize,
+		TotalChunks:        sw.progress.TotalChunks,
+		EstimatedTotalSize: sw.progress.TotalBytes,
+		StartedAt:          sw.stats.StartTime,
+		CompletedAt:        sw.stats.EndTime,
+		IsPausable:         true,
+		IsResumable:        seekable,
+	}
+}
+
+// LoadStreamCheckpoint reads a checkpoint persisted by
+// [StreamingWrapper.WithCheckpointFile].
+//
+// Parameters:
+//   - `path`: The checkpoint file path.
+//
+// Returns:
+//   - The decoded [StreamCheckpoint].
+//   - An error if the file cannot be read or decoded.
+func LoadStreamCheckpoint(path string) (StreamCheckpoint, error) {
+	var cp StreamCheckpoint
+	data, err := sysx.ReadBytes(path)
+	if err != nil {
+		return cp, NewErrorf("load stream checkpoint: %v", err)
+	}
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return cp, NewErrorf("load stream checkpoint %s: %v", path, err)
+	}
+	if cp.Offset < 0 {
+		return cp, NewErrorf("load stream checkpoint %s: negative offset %d", path, cp.Offset)
+	}
+	return cp, nil
+}
+
+// waitIfPaused blocks while the stream is paused.
+func (sw *StreamingWrapper) waitIfPaused(ctx context.Context) error {
+	sw.mu.RLock()
+	ch := sw.resumeCh
+	sw.mu.RUnlock()
+	if ch == nil {
+		return nil
+	}
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// restoreCheckpoint positions the reader at the checkpoint offset after
+// verifying that the source bytes before it still match the checksum.
+func (sw *StreamingWrapper) restoreCheckpoint() error {
+	cp := sw.checkpoint
+	if cp == nil {
+		return nil
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.pending, cp.halted = nil, false
+	state := cp.state
+	if state.Offset == 0 {
+		return nil
+	}
+	if sw.config.IsReceiving {
+		return NewError("stream checkpoints are only supported when sending")
+	}
+	seeker, ok := sw.reader.(io.ReadSeeker)
+	if !ok {
+		return NewErrorf("cannot resume from offset %d: reader is not an io.ReadSeeker", state.Offset)
+	}
+	if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+		return NewErrorf("cannot resume from offset %d: %v", state.Offset, err)
+	}
+	hash := crc32.NewIEEE()
+	if _, err := io.CopyN(hash, seeker, state.Offset); err != nil {
+		return NewErrorf("cannot resume from offset %d: %v", state.Offset, err)
+	}
+	if hash.Sum32() != state.Checksum {
+		return NewErrorf("cannot resume from offset %d: source does not match the checkpoint checksum", state.Offset)
+	}
+
+	sw.mu.Lock()
+	sw.currentChunk = state.Sequence + 1
+	sw.progress.CurrentChunk = sw.currentChunk
+	sw.progress.TransferredBytes = state.Offset
+	sw.mu.Unlock()
+	sw.wrapper.
+		WithDebuggingKV("resumed_from_offset", state.Offset).
+		WithDebuggingKV("resumed_from_chunk", state.Sequence)
+	return nil
+}
+
+// acknowledge records the outcome of a written chunk. Successful chunks
+// are digested into the manifest, and extend the checkpoint once every chunk
+// before them has been acknowledged; a failed chunk freezes the checkpoint
+// for the rest of the run, so that a later resume retransmits it. raw is the
+// uncompressed chunk data: the source data when sending, the data written
+// when receiving.
+func (sw *StreamingWrapper) acknowledge(seq int64, raw []byte, err error) {
+	if err == nil {
+		sw.recordDigest(seq, raw)
+	}
+	cp := sw.checkpoint
+	if cp == nil {
+		return
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if cp.halted {
+		return
+	}
+	if err != nil {
+		cp.halted, cp.pending = true, nil
+		return
+	}
+
+	next := int64(0)
+	if cp.state.Offset > 0 {
+		next = cp.state.Sequence + 1
+	}
+	if seq != next {
+		if cp.pending == nil {
+			cp.pending = make(map[int64][]byte)
+		}
+		cp.pending[seq] = append([]byte(nil), raw...)
+		return
+	}
+	for {
+		cp.state.Sequence = seq
+		cp.state.Offset += int64(len(raw))
+		cp.state.Checksum = crc32.Update(cp.state.Checksum, crc32.IEEETable, raw)
+		seq++
+		var ok bool
+		if raw, ok = cp.pending[seq]; !ok {
+			break
+		}
+		delete(cp.pending, seq)
+	}
+	cp.state.ChunkSize = sw.config.ChunkSize
+	cp.state.Strategy = sw.config.Strategy
+	cp.state.Compression = sw.config.Compression
+	cp.state.UpdatedAt = time.Now()
+	if perr := cp.persist(); perr != nil {
+		sw.recordError(NewErrorf("persist stream checkpoint: %v", perr))
+	}
+}
+
+// completeCheckpoint removes the checkpoint file of a transfer that
+// finished successfully. When a chunk failed, the file is kept so that the
+// transfer can be resumed, and the failure is returned.
+func (sw *StreamingWrapper) completeCheckpoint() error {
+	cp := sw.checkpoint
+	if cp == nil {
+		return nil
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if cp.halted || sw.stats.FailedChunks > 0 {
+		return NewErrorf("stream checkpoint kept at offset %d: %d chunk(s) failed", cp.state.Offset, max(sw.stats.FailedChunks, 1))
+	}
+	if strutil.IsNotEmpty(cp.path) {
+		_ = sysx.RemoveFileIfExist(cp.path)
+	}
+	return nil
+}
+
+// persist writes the checkpoint to its file, if any.
+func (cp *streamCheckpointer) persist() error {
+	if strutil.IsEmpty(cp.path) {
+		return nil
+	}
+	data, err := json.Marshal(cp.state)
+	if err != nil {
+		return err
+	}
+	return sysx.AtomicWriteBytes(cp.path, data)
+}
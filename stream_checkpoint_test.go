@@ -0,0 +1,216 @@
+package replify_test
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sivaosorg/replify"
+)
+
+// cancellingWriter collects what it receives and cancels a context after a
+// number of writes, simulating an interrupted transfer.
+type cancellingWriter struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	writes int
+	after  int
+	cancel context.CancelFunc
+}
+
+func (w *cancellingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writes++
+	if w.cancel != nil && w.writes == w.after {
+		w.cancel()
+	}
+	return w.buf.Write(p)
+}
+
+func (w *cancellingWriter) Bytes() []byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]byte(nil), w.buf.Bytes()...)
+}
+
+// failingWriter collects what it receives, except for one write that fails.
+type failingWriter struct {
+	buf    bytes.Buffer
+	writes int
+	fail   int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.writes == w.fail {
+		return 0, errBroken
+	}
+	return w.buf.Write(p)
+}
+
+func checkpointSource(n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(37)).Read(data)
+	return data
+}
+
+func newCheckpointStream(data []byte, strategy replify.StreamingStrategy) *replify.StreamingWrapper {
+	config := replify.NewStreamConfig()
+	config.ChunkSize = 1024
+	config.Strategy = strategy
+	config.UseBufferPool = false
+	return replify.New().WithStreaming(bytes.NewReader(data), config)
+}
+
+func TestStreamingCheckpointResume(t *testing.T) {
+	t.Parallel()
+
+	data := checkpointSource(10 * 1024)
+	path := filepath.Join(t.TempDir(), "transfer.ckpt")
+
+	// First run, interrupted after the fourth chunk.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	first := &cancellingWriter{after: 4, cancel: cancel}
+	sw := newCheckpointStream(data, replify.StrategyDirect)
+	sw.WithWriter(first)
+	if w := sw.WithCheckpointFile(path); w.IsError() {
+		t.Fatalf("WithCheckpointFile: %v", w.Error())
+	}
+	if w := sw.Start(ctx); !w.IsError() {
+		t.Fatal("expected the interrupted transfer to fail")
+	}
+	cp, err := replify.LoadStreamCheckpoint(path)
+	if err != nil {
+		t.Fatalf("LoadStreamCheckpoint: %v", err)
+	}
+	if cp.Offset != 4096 || cp.Sequence != 3 {
+		t.Fatalf("checkpoint = offset %d, sequence %d; want 4096, 3", cp.Offset, cp.Sequence)
+	}
+	if got, _ := sw.Checkpoint(); got.Checksum != cp.Checksum {
+		t.Errorf("Checkpoint() checksum = %d, want %d", got.Checksum, cp.Checksum)
+	}
+
+	// Second run, as after a process restart: resumes from the file.
+	second := &cancellingWriter{}
+	sw = newCheckpointStream(data, replify.StrategyChunked)
+	sw.WithWriter(second)
+	sw.WithCheckpointFile(path)
+	if w := sw.Start(context.Background()); w.IsError() {
+		t.Fatalf("resumed transfer failed: %v", w.Error())
+	}
+	if got := append(first.Bytes(), second.Bytes()...); !bytes.Equal(got, data) {
+		t.Fatalf("resumed output has %d bytes, want %d identical bytes", len(got), len(data))
+	}
+	if len(second.Bytes()) != len(data)-4096 {
+		t.Errorf("second run wrote %d bytes, want %d", len(second.Bytes()), len(data)-4096)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("checkpoint file should be removed after completion, stat err = %v", err)
+	}
+}
+
+func TestStreamingCheckpointKeptOnFailedChunk(t *testing.T) {
+	t.Parallel()
+
+	data := checkpointSource(4 * 1024)
+	path := filepath.Join(t.TempDir(), "transfer.ckpt")
+	sw := newCheckpointStream(data, replify.StrategyDirect)
+	sw.WithWriter(&failingWriter{fail: 2})
+	sw.WithCheckpointFile(path)
+	w := sw.Start(context.Background())
+	if !w.IsError() || sw.GetStats().FailedChunks != 1 {
+		t.Fatalf("Start = %d %q with %d failed chunks, want a failure", w.StatusCode(), w.Message(), sw.GetStats().FailedChunks)
+	}
+	cp, err := replify.LoadStreamCheckpoint(path)
+	if err != nil {
+		t.Fatalf("checkpoint file should be kept: %v", err)
+	}
+	if cp.Offset != 1024 {
+		t.Errorf("checkpoint offset = %d, want 1024", cp.Offset)
+	}
+}
+
+func TestStreamingCheckpointRejectsChangedSource(t *testing.T) {
+	t.Parallel()
+
+	data := checkpointSource(4 * 1024)
+	sw := newCheckpointStream(data, replify.StrategyDirect)
+	sw.WithWriter(&cancellingWriter{})
+	sw.WithCheckpoint(replify.StreamCheckpoint{Sequence: 1, Offset: 2048, Checksum: 42})
+	w := sw.Start(context.Background())
+	if w.StatusCode() != http.StatusConflict {
+		t.Fatalf("StatusCode() = %d, want %d", w.StatusCode(), http.StatusConflict)
+	}
+	if !strings.Contains(w.Error(), "checksum") {
+		t.Errorf("Error() = %q, want a checksum mismatch", w.Error())
+	}
+
+	sw = replify.New().WithStreaming(strings.NewReader("abc"), nil)
+	sw.WithCheckpoint(replify.StreamCheckpoint{Offset: 1})
+	if sw.Metadata().IsResumable != true {
+		t.Error("a strings.Reader source should be resumable")
+	}
+	sw = replify.New().WithStreaming(bytes.NewBufferString("abc"), nil)
+	sw.WithCheckpoint(replify.StreamCheckpoint{Offset: 1})
+	if w := sw.Start(context.Background()); !strings.Contains(w.Error(), "io.ReadSeeker") {
+		t.Errorf("Error() = %q, want a seekable reader error", w.Error())
+	}
+}
+
+func TestStreamingPauseResume(t *testing.T) {
+	t.Parallel()
+
+	data := checkpointSource(8 * 1024)
+	out := &cancellingWriter{}
+	sw := newCheckpointStream(data, replify.StrategyChunked)
+	sw.WithWriter(out)
+	sw.Pause()
+	if !sw.IsPaused() {
+		t.Fatal("IsPaused() = false after Pause")
+	}
+
+	done := make(chan bool, 1)
+	go func() { done <- sw.Start(context.Background()).IsSuccess() }()
+	time.Sleep(30 * time.Millisecond)
+	if n := len(out.Bytes()); n != 0 {
+		t.Fatalf("paused stream wrote %d bytes", n)
+	}
+
+	sw.Resume()
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Fatalf("resumed stream failed: %v", sw.Errors())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not finish after Resume")
+	}
+	if sw.IsPaused() {
+		t.Error("IsPaused() = true after Resume")
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Error("output differs from the source")
+	}
+}
+
+func TestStreamingPauseCancel(t *testing.T) {
+	t.Parallel()
+
+	sw := newCheckpointStream(checkpointSource(2048), replify.StrategyDirect)
+	sw.WithWriter(&cancellingWriter{})
+	sw.Pause()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if w := sw.Start(ctx); !w.IsError() {
+		t.Fatal("expected a paused stream to stop when its context is cancelled")
+	}
+}
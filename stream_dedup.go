@@ -0,0 +1,277 @@
+package replify
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"sync/atomic"
+	"time"
+)
+
+// ErrChunkMissing reports a deduplicated chunk that the receiver does not
+// hold. It is wrapped with the digest concerned; test for it with errors.Is.
+var ErrChunkMissing = errors.New("deduplicated chunk missing")
+
+// WithChunkIndex enables chunk deduplication on the sending side of a
+// framed stream.
+//
+// The content of every chunk, after the transforms, is digested with
+// SHA-256, and a chunk whose digest the index holds is sent as a reference
+// frame carrying only the digest, which the receiver resolves from its
+// [ChunkStore]; other chunks are sent as usual, then added to the index.
+// Deduplicated chunks and the bytes they saved are counted in
+// StreamingStats.DedupChunks and DedupBytes. Deduplication works best with
+// [StrategyContentDefined], whose chunk boundaries survive insertions.
+// Framing is enabled, as with [StreamingWrapper.WithFraming].
+//
+// Parameters:
+//   - `index`: The chunks the receiver holds; nil disables deduplication.
+//
+// Returns:
+//   - A pointer to the underlying [wrapper] instance, allowing for method chaining.
+//   - If the streaming wrapper is nil, returns a new wrapper with an error message.
+//
+// Example:
+//
+//	// Sender: the receiver already holds the chunks of the last export
+//	streaming := replify.New().WithStreaming(export, nil)
+//	streaming.WithStreamingStrategy(replify.StrategyContentDefined)
+//	streaming.WithChunkIndex(replify.NewChunkIndexFromManifest(lastManifest))
+//	streaming.WithWriter(conn)
+//
+//	// Receiver
+//	receiving := replify.New().WithStreaming(conn, nil)
+//	receiving.WithReceiveMode(true)
+//	receiving.WithChunkStore(store)
+//	receiving.WithWriter(output)
+func (sw *StreamingWrapper) WithChunkIndex(index ChunkIndex) *wrapper {
+	if sw == nil {
+		return respondStreamBadRequestDefault()
+	}
+	sw.index = index
+	if index != nil {
+		sw.WithFraming(true)
+	}
+	sw.wrapper.WithDebuggingKV("deduplicated", index != nil)
+	return sw.wrapper
+}
+
+// WithChunkStore enables chunk deduplication on the receiving side of a
+// framed stream.
+//
+// Every chunk received is put in the store by the SHA-256 digest of its
+// content, after decompression and before the transforms, and the
+// reference frames sent for the chunks of [StreamingWrapper.WithChunkIndex]
+// are resolved from it. A reference to a chunk the store does not hold, or
+// whose content does not match its digest, fails its chunk with
+// [ErrChunkMissing]. Framing is enabled, as with
+// [StreamingWrapper.WithFraming].
+//
+// Parameters:
+//   - `store`: The store of the chunks received; nil disables it.
+//
+// Returns:
+//   - A pointer to the underlying [wrapper] instance, allowing for method chaining.
+//   - If the streaming wrapper is nil, returns a new wrapper with an error message.
+//
+// Example:
+//
+//	store := replify.NewMemoryChunkStore()
+//	receiving := replify.New().WithStreaming(conn, nil)
+//	receiving.WithReceiveMode(true)
+//	receiving.WithChunkStore(store)
+//	receiving.WithWriter(output)
+func (sw *StreamingWrapper) WithChunkStore(store ChunkStore) *wrapper {
+	if sw == nil {
+		return respondStreamBadRequestDefault()
+	}
+	sw.store = store
+	if store != nil {
+		sw.WithFraming(true)
+	}
+	sw.wrapper.WithDebuggingKV("chunk_store", store != nil)
+	return sw.wrapper
+}
+
+// Has reports whether the index holds the chunk.
+func (idx *chunkIndexSet) Has(digest string) bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	_, ok := idx.digests[digest]
+	return ok
+}
+
+// Add adds the chunk to the index.
+func (idx *chunkIndexSet) Add(digest string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.digests[digest] = struct{}{}
+}
+
+// Has reports whether the store holds the chunk.
+func (s *MemoryChunkStore) Has(digest string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.chunks[digest]
+	return ok
+}
+
+// Add does nothing: the store holds a chunk once it is put.
+func (s *MemoryChunkStore) Add(digest string) {}
+
+// Get returns the content of a chunk, or an error wrapping
+// [ErrChunkMissing] if the store does not hold it.
+func (s *MemoryChunkStore) Get(digest string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	data, ok := s.chunks[digest]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrChunkMissing, digest)
+	}
+	return data, nil
+}
+
+// Put stores a copy of the content of a chunk.
+func (s *MemoryChunkStore) Put(digest string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.chunks[digest]; ok {
+		return nil
+	}
+	s.chunks[digest] = append([]byte(nil), data...)
+	s.bytes += int64(len(data))
+	return nil
+}
+
+// Len returns the number of chunks in the store.
+func (s *MemoryChunkStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.chunks)
+}
+
+// Bytes returns the total size of the chunks in the store.
+func (s *MemoryChunkStore) Bytes() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.bytes
+}
+
+// Digests returns the digests of the chunks in the store, in no particular order.
+func (s *MemoryChunkStore) Digests() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	digests := make([]string, 0, len(s.chunks))
+	for d := range maps.Keys(s.chunks) {
+		digests = append(digests, d)
+	}
+	return digests
+}
+
+// dedupChunk replaces an outgoing chunk by a reference when the receiver
+// holds it. It reports whether the chunk was replaced.
+func (sw *StreamingWrapper) dedupChunk(chunk *StreamChunk) bool {
+	if sw.index == nil || sw.framer == nil {
+		return false
+	}
+	sum := sha256.Sum256(chunk.Data)
+	chunk.Digest = hex.EncodeToString(sum[:])
+	if !sw.index.Has(chunk.Digest) {
+		return false
+	}
+	atomic.AddInt64(&sw.stats.DedupChunks, 1)
+	atomic.AddInt64(&sw.stats.DedupBytes, int64(len(chunk.Data)))
+	chunk.Data = sum[:]
+	chunk.Deduplicated = true
+	return true
+}
+
+// storeChunk puts the content of an incoming chunk in the chunk store.
+func (sw *StreamingWrapper) storeChunk(chunk *StreamChunk) error {
+	if sw.store == nil {
+		return nil
+	}
+	sum := sha256.Sum256(chunk.Data)
+	chunk.Digest = hex.EncodeToString(sum[:])
+	if err := sw.store.Put(chunk.Digest, chunk.Data); err != nil {
+		return fmt.Errorf("store chunk %d: %w", chunk.SequenceNumber, err)
+	}
+	return nil
+}
+
+// resolveChunk replaces an incoming reference by the content of its chunk.
+// It runs in sequence order, so that a reference can name a chunk received
+// earlier in the same stream. A failure is set as the error of the chunk.
+func (sw *StreamingWrapper) resolveChunk(chunk *StreamChunk) {
+	if !chunk.Deduplicated || !sw.config.IsReceiving || chunk.Error != nil {
+		return
+	}
+	if err := sw.resolveRef(chunk); err != nil {
+		chunk.Error = &ChunkError{Sequence: chunk.SequenceNumber, Op: ChunkOpProcess, Attempt: 1, Err: err}
+		return
+	}
+	atomic.AddInt64(&sw.stats.DedupChunks, 1)
+	atomic.AddInt64(&sw.stats.DedupBytes, chunk.Size)
+}
+
+// resolveRef looks up the chunk a reference names, and processes it as a
+// received chunk.
+func (sw *StreamingWrapper) resolveRef(chunk *StreamChunk) error {
+	if len(chunk.Data) != sha256.Size {
+		return fmt.Errorf("%w: frame %d: reference of %d bytes", ErrFrameCorrupt, chunk.SequenceNumber, len(chunk.Data))
+	}
+	chunk.Digest = hex.EncodeToString(chunk.Data)
+	if sw.store == nil {
+		return fmt.Errorf("%w: %s (no chunk store)", ErrChunkMissing, chunk.Digest)
+	}
+	data, err := sw.store.Get(chunk.Digest)
+	if err != nil {
+		return err
+	}
+	if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != chunk.Digest {
+		return fmt.Errorf("%w: %s (stored content does not match)", ErrChunkMissing, chunk.Digest)
+	}
+	chunk.Data = append([]byte(nil), data...)
+	chunk.Size = int64(len(data))
+	if err := sw.applyTransforms(chunk); err != nil {
+		return err
+	}
+	chunk.Checksum = sw.calculateChecksum(chunk.Data)
+	return nil
+}
+
+// nextCDCChunk reads the next content-defined chunk numbered seq, into buf
+// when it fits. It returns a nil chunk when nothing was read.
+func (sw *StreamingWrapper) nextCDCChunk(buf []byte, seq int64) (*StreamChunk, error) {
+	data, err := sw.cdc.Next()
+	if len(data) == 0 {
+		if err != nil && err != io.EOF {
+			err = &ChunkError{Sequence: seq, Op: ChunkOpRead, Attempt: 1, Err: err}
+		}
+		return nil, err
+	}
+	if cap(buf) >= len(data) {
+		buf = buf[:len(data)]
+	} else {
+		buf = make([]byte, len(data))
+	}
+	copy(buf, data)
+	return &StreamChunk{
+		SequenceNumber:  seq,
+		Data:            buf,
+		Size:            int64(len(buf)),
+		Timestamp:       time.Now(),
+		CompressionType: sw.config.Compression,
+	}, nil
+}
+
+// chunkKind returns the kind of the frame carrying a chunk.
+func chunkKind(chunk *StreamChunk) byte {
+	if chunk.Deduplicated {
+		return frameKindRef
+	}
+	return frameKindData
+}
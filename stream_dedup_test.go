@@ -0,0 +1,147 @@
+package replify_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/sivaosorg/replify"
+)
+
+// randomSource returns n pseudo-random bytes, the same for a given seed.
+func randomSource(seed uint64, n int) []byte {
+	r := rand.New(rand.NewPCG(seed, seed))
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(r.Uint32())
+	}
+	return data
+}
+
+// cdcDigests returns the digests of the content-defined chunks of data.
+func cdcDigests(t *testing.T, data []byte) map[string]bool {
+	t.Helper()
+	chunker := replify.NewCDCChunker(bytes.NewReader(data), 1024)
+	digests := make(map[string]bool)
+	var total int
+	for {
+		chunk, err := chunker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		if len(chunk) > 4096 {
+			t.Fatalf("chunk of %d bytes, want at most 4096", len(chunk))
+		}
+		total += len(chunk)
+		sum := sha256.Sum256(chunk)
+		digests[hex.EncodeToString(sum[:])] = true
+	}
+	if total != len(data) {
+		t.Fatalf("chunks hold %d bytes, want %d", total, len(data))
+	}
+	return digests
+}
+
+// dedupSend streams data with content-defined chunks, deduplicated against index.
+func dedupSend(t *testing.T, data []byte, index replify.ChunkIndex) ([]byte, *replify.StreamingStats) {
+	t.Helper()
+	var wire bytes.Buffer
+	sw := replify.New().WithStreaming(bytes.NewReader(data), nil)
+	sw.WithChunkSize(1024)
+	sw.WithStreamingStrategy(replify.StrategyContentDefined)
+	sw.WithChunkIndex(index)
+	sw.WithWriter(&wire)
+	if w := sw.Start(context.Background()); w.IsError() {
+		t.Fatalf("send: %v", w.Error())
+	}
+	return wire.Bytes(), sw.GetStats()
+}
+
+// dedupReceive receives a deduplicated stream into store.
+func dedupReceive(wire []byte, store replify.ChunkStore) (*replify.StreamingWrapper, []byte) {
+	var out bytes.Buffer
+	sw := replify.New().WithStreaming(bytes.NewReader(wire), nil)
+	sw.WithReceiveMode(true)
+	sw.WithChunkStore(store)
+	sw.WithWriter(&out)
+	sw.Start(context.Background())
+	return sw, out.Bytes()
+}
+
+func TestCDCChunkerBoundaries(t *testing.T) {
+	t.Parallel()
+
+	data := randomSource(1, 64<<10)
+	edited := append(append(append([]byte(nil), data[:20000]...), []byte("inserted bytes")...), data[20000:]...)
+	before, after := cdcDigests(t, data), cdcDigests(t, edited)
+
+	var shared int
+	for d := range before {
+		if after[d] {
+			shared++
+		}
+	}
+	if len(before) < 8 || shared < len(before)-3 {
+		t.Errorf("%d of %d chunks survive an insertion, want all but those around it", shared, len(before))
+	}
+}
+
+func TestStreamingDedupRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	data := randomSource(2, 48<<10)
+	store := replify.NewMemoryChunkStore()
+
+	wire, stats := dedupSend(t, data, store)
+	if stats.DedupChunks != 0 {
+		t.Errorf("first transfer DedupChunks = %d, want 0", stats.DedupChunks)
+	}
+	sw, out := dedupReceive(wire, store)
+	if sw.HasErrors() || !bytes.Equal(out, data) {
+		t.Fatalf("first transfer: %v, got %d bytes, want %d", sw.Errors(), len(out), len(data))
+	}
+
+	// The second transfer only sends the chunks around the edit
+	edited := bytes.Clone(data)
+	copy(edited[30000:], "changed")
+	wire2, stats := dedupSend(t, edited, store)
+	if stats.DedupChunks == 0 || stats.DedupBytes < int64(len(data))/2 {
+		t.Errorf("DedupChunks = %d, DedupBytes = %d, want most of %d bytes", stats.DedupChunks, stats.DedupBytes, len(data))
+	}
+	if len(wire2) >= len(wire)/2 {
+		t.Errorf("second transfer of %d bytes, want well under %d", len(wire2), len(wire))
+	}
+	sw, out = dedupReceive(wire2, store)
+	if sw.HasErrors() || !bytes.Equal(out, edited) {
+		t.Fatalf("second transfer: %v, got %d bytes, want %d", sw.Errors(), len(out), len(edited))
+	}
+	if got := sw.GetStats(); got.DedupChunks != stats.DedupChunks || got.DedupBytes != stats.DedupBytes {
+		t.Errorf("receiver dedup %d chunks / %d bytes, sender %d / %d", got.DedupChunks, got.DedupBytes, stats.DedupChunks, stats.DedupBytes)
+	}
+}
+
+func TestStreamingDedupMissingChunk(t *testing.T) {
+	t.Parallel()
+
+	data := randomSource(3, 16<<10)
+	digests := make([]string, 0)
+	for d := range cdcDigests(t, data) {
+		digests = append(digests, d)
+	}
+	wire, _ := dedupSend(t, data, replify.NewChunkIndex(digests...))
+
+	sw, out := dedupReceive(wire, replify.NewMemoryChunkStore())
+	if !hasError(sw, replify.ErrChunkMissing) {
+		t.Errorf("errors = %v, want ErrChunkMissing", sw.Errors())
+	}
+	if len(out) != 0 {
+		t.Errorf("wrote %d bytes of unresolved chunks", len(out))
+	}
+}
@@ -0,0 +1,226 @@
+package replify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sivaosorg/replify/pkg/strutil"
+)
+
+// WithDestination adds a named destination to the stream, which is then
+// written to every destination at once, e.g. a file, an HTTP response and a
+// hash.
+//
+// Each chunk is written to the destinations concurrently, after compression
+// and framing, and to all of them before the next chunk: the slowest
+// destination, or the one with the lowest throttle rate, paces the stream.
+// A destination with a throttle rate never receives data faster than it,
+// whatever the rate of the others and of [StreamingWrapper.WithThrottleRate].
+// When a DestinationDrop destination fails, it is dropped, its failure is
+// recorded in [StreamingWrapper.Errors] as a [*DestinationError], and the
+// stream goes on; it fails when no destination is left. When a
+// DestinationFailAll destination fails, the stream stops and Start reports
+// its [*DestinationError]. A writer set with [StreamingWrapper.WithWriter] is
+// kept as a fail-all destination named "writer". The statistics of each
+// destination are reported in StreamingStats.Destinations. Destinations are
+// not closed by the stream.
+//
+// Parameters:
+//   - `dest`: The destination; Name must be unique and Writer set. An empty
+//     Policy selects DestinationFailAll.
+//
+// Returns:
+//   - A pointer to the underlying [wrapper] instance, allowing for method chaining.
+//   - If the streaming wrapper is nil, returns a new wrapper with an error message.
+//
+// Example:
+//
+//	digest := sha256.New()
+//	streaming := replify.New().WithStreaming(export, nil)
+//	streaming.WithDestination(replify.StreamDestination{Name: "file", Writer: file})
+//	streaming.WithDestination(replify.StreamDestination{
+//	    Name:         "client",
+//	    Writer:       rw,
+//	    ThrottleRate: 5 << 20,
+//	    Policy:       replify.DestinationDrop,
+//	})
+//	streaming.WithDestination(replify.StreamDestination{Name: "sha256", Writer: digest})
+//	streaming.Start(ctx)
+//	log.Println(streaming.GetStats().Destinations["client"].Dropped)
+func (sw *StreamingWrapper) WithDestination(dest StreamDestination) *wrapper {
+	if sw == nil {
+		return respondStreamBadRequestDefault()
+	}
+	if dest.Policy == "" {
+		dest.Policy = DestinationFailAll
+	}
+	var invalid string
+	switch {
+	case strutil.IsEmpty(dest.Name):
+		invalid = "destination name is required"
+	case dest.Name == fanoutWriterName || slices.ContainsFunc(sw.destinations, func(d StreamDestination) bool { return d.Name == dest.Name }):
+		invalid = fmt.Sprintf("duplicate destination %q", dest.Name)
+	case dest.Writer == nil:
+		invalid = fmt.Sprintf("destination %q has no writer", dest.Name)
+	case dest.ThrottleRate < 0:
+		invalid = fmt.Sprintf("invalid throttle rate of destination %q: %d (must be >= 0)", dest.Name, dest.ThrottleRate)
+	case dest.Policy != DestinationFailAll && dest.Policy != DestinationDrop:
+		invalid = fmt.Sprintf("unknown policy of destination %q: %s", dest.Name, dest.Policy)
+	}
+	if invalid != "" {
+		return sw.wrapper.
+			WithStatusCode(http.StatusBadRequest).
+			WithMessage(invalid).
+			BindCause()
+	}
+	sw.destinations = append(sw.destinations, dest)
+	sw.wrapper.WithDebuggingKV("destinations", len(sw.destinations))
+	return sw.wrapper
+}
+
+// Error returns the error message.
+func (e *DestinationError) Error() string {
+	return fmt.Sprintf("destination %q (%s): %v", e.Name, e.Policy, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *DestinationError) Unwrap() error {
+	return e.Err
+}
+
+// startFanout replaces the writer with the fan-out writer of the run, when
+// destinations are set. It returns the context of the run, canceled when a
+// fail-all destination fails, and the function releasing it.
+func (sw *StreamingWrapper) startFanout(ctx context.Context) (context.Context, context.CancelFunc) {
+	sw.fanout = nil
+	if len(sw.destinations) == 0 {
+		return ctx, func() {}
+	}
+	dests := sw.destinations
+	if sw.writer != nil {
+		dests = slices.Concat([]StreamDestination{{Name: fanoutWriterName, Writer: sw.writer, Policy: DestinationFailAll}}, dests)
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	sw.fanout = newStreamFanout(sw, ctx, cancel, sw.writer, dests)
+	sw.writer = sw.fanout
+	sw.fanout.publish()
+	return ctx, func() { cancel(nil) }
+}
+
+// finishFanout restores the writer and returns the failure of the run, if a
+// fail-all destination failed or every destination was dropped.
+func (sw *StreamingWrapper) finishFanout() error {
+	f := sw.fanout
+	if f == nil {
+		return nil
+	}
+	sw.writer = f.writer
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.publish()
+	return f.err
+}
+
+// Write writes p to every live destination concurrently. Calls are
+// serialized, so that chunks written by concurrent workers reach every
+// destination whole and in the same order.
+func (f *streamFanout) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return 0, f.err
+	}
+	live := slices.DeleteFunc(slices.Clone(f.targets), func(t *fanoutTarget) bool { return t.stats.Dropped })
+	errs := make([]error, len(live))
+	if len(live) == 1 {
+		errs[0] = live[0].write(f.ctx, p)
+	} else {
+		var wg sync.WaitGroup
+		for i, t := range live {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = t.write(f.ctx, p)
+			}()
+		}
+		wg.Wait()
+	}
+
+	var failed error
+	remaining := len(live)
+	for i, t := range live {
+		if errs[i] == nil {
+			continue
+		}
+		e := &DestinationError{Name: t.dest.Name, Policy: t.dest.Policy, Err: errs[i]}
+		t.stats.Err = e
+		if t.dest.Policy == DestinationDrop {
+			t.stats.Dropped = true
+			remaining--
+			f.sw.recordError(e)
+			continue
+		}
+		if failed == nil {
+			failed = e
+		}
+	}
+	f.publish()
+	if failed == nil && remaining == 0 {
+		failed = NewError("every destination was dropped")
+	}
+	if failed != nil {
+		f.err = failed
+		f.cancel(failed)
+		return 0, failed
+	}
+	return len(p), nil
+}
+
+// publish copies the destination statistics to the stream statistics.
+func (f *streamFanout) publish() {
+	stats := make(map[string]DestinationStats, len(f.targets))
+	for _, t := range f.targets {
+		stats[t.dest.Name] = t.stats
+	}
+	f.sw.mu.Lock()
+	f.sw.stats.Destinations = stats
+	f.sw.mu.Unlock()
+}
+
+// write writes p to the destination, then waits as long as its throttle
+// rate requires.
+func (t *fanoutTarget) write(ctx context.Context, p []byte) error {
+	begin := time.Now()
+	n, err := t.dest.Writer.Write(p)
+	t.stats.WriteTime += time.Since(begin)
+	t.stats.BytesWritten += int64(max(n, 0))
+	if err == nil && n < len(p) {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		return err
+	}
+	t.stats.Writes++
+
+	if t.dest.ThrottleRate <= 0 {
+		return nil
+	}
+	due := t.start.Add(time.Duration(float64(t.stats.BytesWritten) / float64(t.dest.ThrottleRate) * float64(time.Second)))
+	wait := time.Until(due)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		t.stats.ThrottleTime += wait
+	case <-ctx.Done():
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package replify_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sivaosorg/replify"
+)
+
+var errBroken = errors.New("broken destination")
+
+// brokenWriter accepts `limit` bytes, then fails every write.
+type brokenWriter struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+func (w *brokenWriter) Write(p []byte) (int, error) {
+	if w.buf.Len()+len(p) > w.limit {
+		return 0, errBroken
+	}
+	return w.buf.Write(p)
+}
+
+func TestStreamingFanout(t *testing.T) {
+	t.Parallel()
+
+	data := checkpointSource(10*1024 + 100)
+	for _, strategy := range []replify.StreamingStrategy{replify.StrategyDirect, replify.StrategyChunked} {
+		var file, legacy bytes.Buffer
+		digest := sha256.New()
+		sw := newCheckpointStream(data, strategy)
+		sw.WithWriter(&legacy)
+		sw.WithDestination(replify.StreamDestination{Name: "file", Writer: &file})
+		sw.WithDestination(replify.StreamDestination{Name: "sha256", Writer: digest})
+		if w := sw.Start(context.Background()); w.IsError() {
+			t.Fatalf("%s: Start: %v", strategy, w.Error())
+		}
+		want := sha256.Sum256(data)
+		if !bytes.Equal(file.Bytes(), data) || !bytes.Equal(legacy.Bytes(), data) || !bytes.Equal(digest.Sum(nil), want[:]) {
+			t.Errorf("%s: destinations differ from the source", strategy)
+		}
+		stats := sw.GetStats().Destinations
+		if len(stats) != 3 {
+			t.Fatalf("%s: %d destination stats, want 3", strategy, len(stats))
+		}
+		for name, s := range stats {
+			if s.BytesWritten != int64(len(data)) || s.Writes != 11 || s.Dropped || s.Err != nil {
+				t.Errorf("%s: %s stats = %+v", strategy, name, s)
+			}
+		}
+	}
+}
+
+func TestStreamingFanoutDrop(t *testing.T) {
+	t.Parallel()
+
+	data := checkpointSource(8 * 1024)
+	var file bytes.Buffer
+	client := &brokenWriter{limit: 3 * 1024}
+	sw := newCheckpointStream(data, replify.StrategyChunked)
+	sw.WithDestination(replify.StreamDestination{Name: "file", Writer: &file})
+	sw.WithDestination(replify.StreamDestination{Name: "client", Writer: client, Policy: replify.DestinationDrop})
+	if w := sw.Start(context.Background()); w.IsError() {
+		t.Fatalf("Start: %v", w.Error())
+	}
+	if !bytes.Equal(file.Bytes(), data) || client.buf.Len() != 3*1024 {
+		t.Errorf("file = %d bytes, client = %d bytes", file.Len(), client.buf.Len())
+	}
+	s := sw.GetStats().Destinations["client"]
+	var de *replify.DestinationError
+	if !s.Dropped || !errors.As(s.Err, &de) || de.Name != "client" || !errors.Is(s.Err, errBroken) {
+		t.Errorf("client stats = %+v", s)
+	}
+	if !errors.As(sw.Errors()[0], &de) {
+		t.Errorf("errors = %v", sw.Errors())
+	}
+
+	// The stream fails when every destination is dropped.
+	sw = newCheckpointStream(data, replify.StrategyDirect)
+	sw.WithDestination(replify.StreamDestination{Name: "client", Writer: &brokenWriter{limit: 1024}, Policy: replify.DestinationDrop})
+	if w := sw.Start(context.Background()); !w.IsError() {
+		t.Error("expected an error when every destination is dropped")
+	}
+}
+
+func TestStreamingFanoutFailAll(t *testing.T) {
+	t.Parallel()
+
+	data := checkpointSource(8 * 1024)
+	for _, strategy := range []replify.StreamingStrategy{replify.StrategyDirect, replify.StrategyChunked} {
+		var file bytes.Buffer
+		sw := newCheckpointStream(data, strategy)
+		sw.WithDestination(replify.StreamDestination{Name: "file", Writer: &file})
+		sw.WithDestination(replify.StreamDestination{Name: "archive", Writer: &brokenWriter{limit: 2 * 1024}})
+		w := sw.Start(context.Background())
+		var de *replify.DestinationError
+		if !w.IsError() || !errors.As(w.Cause(), &de) || de.Name != "archive" {
+			t.Errorf("%s: Start = %v", strategy, w.Error())
+		}
+		if file.Len() >= len(data) {
+			t.Errorf("%s: file received %d bytes after the failure", strategy, file.Len())
+		}
+	}
+}
+
+func TestStreamingFanoutThrottle(t *testing.T) {
+	t.Parallel()
+
+	data := checkpointSource(8 * 1024)
+	var fast, slow bytes.Buffer
+	sw := newCheckpointStream(data, replify.StrategyDirect)
+	sw.WithDestination(replify.StreamDestination{Name: "fast", Writer: &fast})
+	sw.WithDestination(replify.StreamDestination{Name: "slow", Writer: &slow, ThrottleRate: 32 * 1024})
+	begin := time.Now()
+	if w := sw.Start(context.Background()); w.IsError() {
+		t.Fatalf("Start: %v", w.Error())
+	}
+	if elapsed := time.Since(begin); elapsed < 200*time.Millisecond {
+		t.Errorf("elapsed = %s, want about 250ms at 32 KiB/s", elapsed)
+	}
+	stats := sw.GetStats().Destinations
+	if stats["slow"].ThrottleTime == 0 || stats["fast"].ThrottleTime != 0 {
+		t.Errorf("throttle times: slow %s, fast %s", stats["slow"].ThrottleTime, stats["fast"].ThrottleTime)
+	}
+	if !bytes.Equal(slow.Bytes(), data) || !bytes.Equal(fast.Bytes(), data) {
+		t.Error("destinations differ from the source")
+	}
+}
+
+func TestStreamingDestinationValidation(t *testing.T) {
+	t.Parallel()
+
+	sw := replify.New().WithStreaming(bytes.NewReader(nil), nil)
+	if w := sw.WithDestination(replify.StreamDestination{Name: "a", Writer: &bytes.Buffer{}}); w.IsError() {
+		t.Fatalf("valid destination rejected: %v", w.Error())
+	}
+	for _, dest := range []replify.StreamDestination{
+		{Writer: &bytes.Buffer{}},
+		{Name: "a", Writer: &bytes.Buffer{}},
+		{Name: "writer", Writer: &bytes.Buffer{}},
+		{Name: "b"},
+		{Name: "c", Writer: &bytes.Buffer{}, ThrottleRate: -1},
+		{Name: "d", Writer: &bytes.Buffer{}, Policy: "retry"},
+	} {
+		sw := replify.New().WithStreaming(bytes.NewReader(nil), nil)
+		sw.WithDestination(replify.StreamDestination{Name: "a", Writer: &bytes.Buffer{}})
+		if w := sw.WithDestination(dest); !w.IsError() {
+			t.Errorf("destination %+v accepted", dest)
+		}
+	}
+}
+
+func TestStreamingFanoutBuffered(t *testing.T) {
+	t.Parallel()
+
+	data := checkpointSource(64 * 1024)
+	var file, client bytes.Buffer
+	sw := newCheckpointStream(data, replify.StrategyBuffered)
+	sw.WithMaxConcurrentChunks(8)
+	sw.WithDestination(replify.StreamDestination{Name: "file", Writer: &file})
+	sw.WithDestination(replify.StreamDestination{Name: "client", Writer: &client})
+	if w := sw.Start(context.Background()); w.IsError() {
+		t.Fatalf("Start: %v", w.Error())
+	}
+	if file.Len() != len(data) || !bytes.Equal(file.Bytes(), client.Bytes()) {
+		t.Errorf("destinations hold %d and %d bytes, want %d identical bytes", file.Len(), client.Len(), len(data))
+	}
+	for name, s := range sw.GetStats().Destinations {
+		if s.BytesWritten != int64(len(data)) || s.Writes != 64 {
+			t.Errorf("%s stats = %+v", name, s)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package replify
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"slices"
+	"time"
+)
+
+// Errors reported when parsing a framed stream. They are wrapped with the
+// frame concerned; test for them with errors.Is.
+var (
+	// ErrFrameTruncated reports a framed stream that ends inside a frame or before its trailer.
+	ErrFrameTruncated = errors.New("framed stream truncated")
+
+	// ErrFrameCorrupt reports a malformed frame header.
+	ErrFrameCorrupt = errors.New("malformed stream frame")
+
+	// ErrFrameSequence reports a frame out of sequence: lost, duplicated or reordered.
+	ErrFrameSequence = errors.New("stream frame out of sequence")
+
+	// ErrFrameChecksum reports a frame payload that does not match its checksum.
+	ErrFrameChecksum = errors.New("stream frame checksum mismatch")
+
+	// ErrFrameTrailer reports a trailer that does not match the frames received.
+	ErrFrameTrailer = errors.New("stream trailer mismatch")
+
+	// ErrFrameSeal reports a stream sealed when it should not be, or the reverse,
+	// or whose key cannot be obtained.
+	ErrFrameSeal = errors.New("stream frame sealing mismatch")
+
+	// ErrFrameAuth reports a sealed frame that fails authentication.
+	ErrFrameAuth = errors.New("stream frame authentication failed")
+)
+
+// WithFraming enables or disables the self-describing frame format of the
+// chunked strategy, and selects [StrategyChunked] when enabling it, unless
+// [StrategyContentDefined] is selected.
+//
+// When sending, every chunk is written as a frame, and the stream is closed
+// by a trailer frame carrying a [StreamTrailer]. When receiving, the frames
+// are parsed and verified: a payload that does not match its checksum
+// ([ErrFrameChecksum]), a malformed header ([ErrFrameCorrupt]), a frame out
+// of sequence ([ErrFrameSequence]), a stream that ends before its trailer
+// ([ErrFrameTruncated]) or a trailer that does not match the frames
+// received ([ErrFrameTrailer]) fails the stream. Unlike an unframed
+// stream, a framed stream is never written with a hole: the first chunk
+// that fails stops the run, sending or receiving, and a sender stopped this
+// way writes no trailer. Compressed frames name their codec, so the
+// receiver decompresses them whatever its own compression type.
+//
+// Frame layout (integers are big-endian):
+//
+//	Offset  Size  Field
+//	──────────────────────────────────────────────────────────────
+//	0       4     Magic "RPF1"
+//	4       1     Kind: 0x01 data, 0x02 trailer, 0x03 seal, 0x04 reference
+//	5       1     Flags: 0x01 payload compressed, 0x02 payload sealed
+//	6       1     Codec name length n (0 when uncompressed)
+//	7       1     Reserved, 0
+//	8       8     Sequence number (trailer: number of data frames)
+//	16      4     Payload size
+//	20      4     CRC-32 (IEEE) of the payload
+//	24      n     Codec name, as registered with RegisterCodec
+//	24+n    size  Payload
+//
+// The trailer payload is 12 bytes: the total payload size of the data
+// frames (8 bytes) and the CRC-32 of their checksums in sequence order
+// (4 bytes). Seal frames open encrypted streams; see
+// [StreamingWrapper.WithEncryption]. Reference frames stand for a chunk
+// the receiver holds and carry its SHA-256 digest; see
+// [StreamingWrapper.WithChunkIndex].
+//
+// Parameters:
+//   - `enabled`: Whether chunks are framed.
+//
+// Returns:
+//   - A pointer to the underlying [wrapper] instance, allowing for method chaining.
+//   - If the streaming wrapper is nil, returns a new wrapper with an error message.
+//
+// Example:
+//
+//	// Sender
+//	streaming := replify.New().WithStreaming(file, nil)
+//	streaming.WithCompressionType(replify.CompressGzip)
+//	streaming.WithFraming(true)
+//	streaming.WithWriter(conn)
+//
+//	// Receiver
+//	receiving := replify.New().WithStreaming(conn, nil)
+//	receiving.WithReceiveMode(true)
+//	receiving.WithFraming(true)
+//	receiving.WithWriter(output)
+func (sw *StreamingWrapper) WithFraming(enabled bool) *wrapper {
+	if sw == nil {
+		return respondStreamBadRequestDefault()
+	}
+	sw.config.Framed = enabled
+	if enabled && sw.config.Strategy != StrategyContentDefined {
+		sw.config.Strategy = StrategyChunked
+	}
+	sw.wrapper.WithDebuggingKV("framed", enabled)
+	return sw.wrapper
+}
+
+// Trailer returns the trailer written, or received and verified, by the
+// last framed run. Call it once Start has returned.
+//
+// Returns:
+//   - The trailer of the stream.
+//   - `false` if the last run was not framed or did not complete.
+func (sw *StreamingWrapper) Trailer() (StreamTrailer, bool) {
+	if sw == nil {
+		return StreamTrailer{}, false
+	}
+	sw.mu.RLock()
+	defer sw.mu.RUnlock()
+	if sw.framer == nil || sw.framer.trailer == nil {
+		return StreamTrailer{}, false
+	}
+	return *sw.framer.trailer, true
+}
+
+// startFrames resets the frame state at the start of a chunked run.
+func (sw *StreamingWrapper) startFrames() {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.framer = nil
+	if sw.config.Framed {
+		sw.framer = newStreamFramer()
+	}
+}
+
+// finishFrames closes a framed run: it writes the trailer when sending,
+// and checks the received trailer when receiving.
+func (sw *StreamingWrapper) finishFrames(ctx context.Context) error {
+	f := sw.framer
+	if f == nil {
+		return nil
+	}
+	summary := f.summary()
+	if sw.config.IsReceiving {
+		if f.trailer == nil {
+			return fmt.Errorf("%w: no trailer", ErrFrameTruncated)
+		}
+		if *f.trailer != summary {
+			return fmt.Errorf("%w: trailer %+v, received %+v", ErrFrameTrailer, *f.trailer, summary)
+		}
+		return nil
+	}
+
+	payload := make([]byte, frameTrailerSize)
+	binary.BigEndian.PutUint64(payload, uint64(summary.PayloadBytes))
+	binary.BigEndian.PutUint32(payload[8:], summary.Checksum)
+	var flags byte
+	if f.aead != nil {
+		flags = frameFlagSealed
+		aad := sealAAD(frameKindTrailer, flags, "", summary.Frames)
+		payload = f.aead.Seal(nil, sealNonce(frameKindTrailer, summary.Frames), payload, aad)
+	}
+	trailer := &StreamChunk{
+		SequenceNumber: summary.Frames,
+		Data:           appendFrame(nil, frameKindTrailer, flags, "", summary.Frames, payload),
+	}
+	if err := sw.writeChunk(ctx, trailer); err != nil {
+		sw.recordError(err)
+		return fmt.Errorf("write trailer: %w", err)
+	}
+	sw.mu.Lock()
+	f.trailer = &summary
+	sw.mu.Unlock()
+	return nil
+}
+
+// emitChunk writes a processed chunk, as a frame when framing is enabled.
+func (sw *StreamingWrapper) emitChunk(ctx context.Context, chunk *StreamChunk) error {
+	f := sw.framer
+	if f == nil || sw.config.IsReceiving {
+		return sw.writeChunk(ctx, chunk)
+	}
+	codec, flags := f.frameInfo(chunk)
+	if len(codec) > 255 {
+		return &ChunkError{Sequence: chunk.SequenceNumber, Op: ChunkOpWrite, Attempt: 1, Err: fmt.Errorf("codec name %q too long for a frame", codec)}
+	}
+	frame := *chunk
+	frame.Data = appendFrame(nil, chunkKind(chunk), flags, codec, chunk.SequenceNumber, chunk.Data)
+	if err := sw.writeChunk(ctx, &frame); err != nil {
+		return err
+	}
+	if sw.index != nil && !chunk.Deduplicated && chunk.Digest != "" {
+		sw.index.Add(chunk.Digest)
+	}
+	f.observe(chunk.Checksum, int64(len(chunk.Data)))
+	return nil
+}
+
+// readFrame reads the next data frame of a framed stream, expected to carry
+// chunk seq. The payload is read into buf when it fits. It returns io.EOF
+// once the trailer frame has been read.
+func (sw *StreamingWrapper) readFrame(buf []byte, seq int64) (*StreamChunk, error) {
+	var head [frameHeaderSize]byte
+	if _, err := io.ReadFull(sw.reader, head[:]); err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("%w: no trailer after frame %d", ErrFrameTruncated, seq-1)
+		}
+		return nil, frameReadError(err, seq)
+	}
+	if string(head[:4]) != frameMagic || head[7] != 0 {
+		return nil, fmt.Errorf("%w: bad header for frame %d", ErrFrameCorrupt, seq)
+	}
+	kind, flags, nameLen := head[4], head[5], int(head[6])
+	sequence := int64(binary.BigEndian.Uint64(head[8:16]))
+	size := int64(binary.BigEndian.Uint32(head[16:20]))
+	checksum := binary.BigEndian.Uint32(head[20:24])
+	if size > frameMaxPayload {
+		return nil, fmt.Errorf("%w: frame %d payload of %d bytes", ErrFrameCorrupt, seq, size)
+	}
+
+	name := make([]byte, nameLen)
+	if _, err := io.ReadFull(sw.reader, name); err != nil {
+		return nil, frameReadError(err, seq)
+	}
+	payload := buf
+	if int64(cap(payload)) < size {
+		payload = make([]byte, size)
+	}
+	payload = payload[:size]
+	if _, err := io.ReadFull(sw.reader, payload); err != nil {
+		return nil, frameReadError(err, seq)
+	}
+
+	switch kind {
+	case frameKindSeal:
+		if err := sw.openSeal(seq, payload, checksum); err != nil {
+			return nil, err
+		}
+		return sw.readFrame(buf, seq)
+	case frameKindTrailer:
+		if err := sw.checkSealed(kind, flags, sequence); err != nil {
+			return nil, err
+		}
+		if sw.calculateChecksum(payload) != checksum {
+			return nil, fmt.Errorf("%w: trailer", ErrFrameChecksum)
+		}
+		if aead := sw.framer.aead; aead != nil {
+			plain, err := aead.Open(nil, sealNonce(kind, sequence), payload, sealAAD(kind, flags, "", sequence))
+			if err != nil {
+				return nil, fmt.Errorf("%w: trailer", ErrFrameAuth)
+			}
+			payload = plain
+		}
+		if len(payload) != frameTrailerSize {
+			return nil, fmt.Errorf("%w: trailer payload of %d bytes", ErrFrameCorrupt, len(payload))
+		}
+		sw.mu.Lock()
+		sw.framer.trailer = &StreamTrailer{
+			Frames:       sequence,
+			PayloadBytes: int64(binary.BigEndian.Uint64(payload[:8])),
+			Checksum:     binary.BigEndian.Uint32(payload[8:]),
+		}
+		sw.mu.Unlock()
+		return nil, io.EOF
+	case frameKindData, frameKindRef:
+		if err := sw.checkSealed(kind, flags, seq); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown kind 0x%02x of frame %d", ErrFrameCorrupt, kind, seq)
+	}
+	if sequence != seq {
+		return nil, fmt.Errorf("%w: got frame %d, want %d", ErrFrameSequence, sequence, seq)
+	}
+	sw.framer.observe(checksum, size)
+
+	chunk := &StreamChunk{
+		SequenceNumber:  seq,
+		Data:            payload,
+		Size:            size,
+		Checksum:        checksum,
+		Timestamp:       time.Now(),
+		Compressed:      flags&frameFlagCompressed != 0,
+		CompressionType: CompressNone,
+		Deduplicated:    kind == frameKindRef,
+	}
+	if chunk.Compressed {
+		chunk.CompressionType = CompressionType(name)
+	}
+	return chunk, nil
+}
+
+// verifyFrame checks a received frame payload against its checksum, and
+// that its codec is registered.
+func (sw *StreamingWrapper) verifyFrame(chunk *StreamChunk) error {
+	if sw.framer == nil {
+		return nil
+	}
+	if sw.calculateChecksum(chunk.Data) != chunk.Checksum {
+		return fmt.Errorf("%w: frame %d", ErrFrameChecksum, chunk.SequenceNumber)
+	}
+	if chunk.Compressed {
+		if _, _, ok := LookupCodec(string(chunk.CompressionType)); !ok {
+			return fmt.Errorf("frame %d: unknown codec %q", chunk.SequenceNumber, string(chunk.CompressionType))
+		}
+	}
+	return nil
+}
+
+// frameReadError reports a failed read within a frame.
+func frameReadError(err error, seq int64) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: inside frame %d", ErrFrameTruncated, seq)
+	}
+	return &ChunkError{Sequence: seq, Op: ChunkOpRead, Attempt: 1, Err: err}
+}
+
+// appendFrame appends a frame carrying payload to dst.
+func appendFrame(dst []byte, kind, flags byte, codec string, seq int64, payload []byte) []byte {
+	dst = slices.Grow(dst, frameHeaderSize+len(codec)+len(payload))
+	dst = append(dst, frameMagic...)
+	dst = append(dst, kind, flags, byte(len(codec)), 0)
+	dst = binary.BigEndian.AppendUint64(dst, uint64(seq))
+	dst = binary.BigEndian.AppendUint32(dst, uint32(len(payload)))
+	dst = binary.BigEndian.AppendUint32(dst, crc32.ChecksumIEEE(payload))
+	dst = append(dst, codec...)
+	return append(dst, payload...)
+}
+
+// frameInfo returns the codec name and flags of the frame carrying a chunk.
+func (f *streamFramer) frameInfo(chunk *StreamChunk) (string, byte) {
+	var codec string
+	var flags byte
+	if chunk.Compressed {
+		codec, flags = string(chunk.CompressionType), frameFlagCompressed
+	}
+	if f.aead != nil {
+		flags |= frameFlagSealed
+	}
+	return codec, flags
+}
+
+// observe records a data frame in the trailer being accumulated.
+func (f *streamFramer) observe(checksum uint32, size int64) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], checksum)
+	f.digest.Write(b[:])
+	f.frames++
+	f.payload += size
+}
+
+// summary returns the trailer of the frames observed so far.
+func (f *streamFramer) summary() StreamTrailer {
+	return StreamTrailer{Frames: f.frames, PayloadBytes: f.payload, Checksum: f.digest.Sum32()}
+}
@@ -0,0 +1,175 @@
+package replify_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/sivaosorg/replify"
+)
+
+// framedSend streams data as gzip frames of 1 KiB chunks.
+func framedSend(t *testing.T, data []byte) ([]byte, replify.StreamTrailer) {
+	t.Helper()
+	var wire bytes.Buffer
+	sw := replify.New().WithStreaming(bytes.NewReader(data), nil)
+	sw.WithChunkSize(1024)
+	sw.WithCompressionType(replify.CompressGzip)
+	sw.WithFraming(true)
+	sw.WithWriter(&wire)
+	if w := sw.Start(context.Background()); w.IsError() {
+		t.Fatalf("send: %v", w.Error())
+	}
+	trailer, ok := sw.Trailer()
+	if !ok {
+		t.Fatal("sender has no trailer")
+	}
+	return wire.Bytes(), trailer
+}
+
+// framedReceive parses a framed stream; the receiver has no compression
+// configured, so the frames must name their codec.
+func framedReceive(wire []byte) (*replify.StreamingWrapper, []byte) {
+	var out bytes.Buffer
+	sw := replify.New().WithStreaming(bytes.NewReader(wire), nil)
+	sw.WithChunkSize(1024)
+	sw.WithReceiveMode(true)
+	sw.WithFraming(true)
+	sw.WithWriter(&out)
+	sw.Start(context.Background())
+	return sw, out.Bytes()
+}
+
+// splitFrames splits a framed stream into its frames.
+func splitFrames(wire []byte) [][]byte {
+	var frames [][]byte
+	for len(wire) >= 24 {
+		n := 24 + int(wire[6]) + int(binary.BigEndian.Uint32(wire[16:20]))
+		frames = append(frames, wire[:n])
+		wire = wire[n:]
+	}
+	return frames
+}
+
+func hasError(sw *replify.StreamingWrapper, target error) bool {
+	for _, err := range sw.Errors() {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestStreamingFramedRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	data := bytes.Repeat(checkpointSource(512), 16)
+	wire, sent := framedSend(t, data)
+	if sent.Frames != 8 {
+		t.Errorf("Frames = %d, want 8", sent.Frames)
+	}
+	if frames := splitFrames(wire); len(frames) != 9 || string(frames[0][:4]) != "RPF1" {
+		t.Fatalf("got %d frames, want 8 data frames and a trailer", len(frames))
+	}
+
+	sw, out := framedReceive(wire)
+	if sw.HasErrors() {
+		t.Fatalf("receive: %v", sw.Errors())
+	}
+	if !bytes.Equal(out, data) {
+		t.Fatal("received data differs")
+	}
+	if got, ok := sw.Trailer(); !ok || got != sent {
+		t.Errorf("receiver trailer = %+v, want %+v", got, sent)
+	}
+}
+
+func TestStreamingFramedTruncated(t *testing.T) {
+	t.Parallel()
+
+	wire, _ := framedSend(t, checkpointSource(4*1024))
+	frames := splitFrames(wire)
+	cuts := map[string]int{
+		"before trailer": len(wire) - len(frames[len(frames)-1]),
+		"inside frame":   len(frames[0]) + 10,
+		"empty":          0,
+	}
+	for name, cut := range cuts {
+		sw, _ := framedReceive(wire[:cut])
+		if !sw.GetWrapper().IsError() || !hasError(sw, replify.ErrFrameTruncated) {
+			t.Errorf("%s: errors = %v, want ErrFrameTruncated", name, sw.Errors())
+		}
+	}
+}
+
+func TestStreamingFramedReordered(t *testing.T) {
+	t.Parallel()
+
+	wire, _ := framedSend(t, checkpointSource(4*1024))
+	frames := splitFrames(wire)
+	frames[1], frames[2] = frames[2], frames[1]
+	sw, _ := framedReceive(bytes.Join(frames, nil))
+	if !hasError(sw, replify.ErrFrameSequence) {
+		t.Errorf("errors = %v, want ErrFrameSequence", sw.Errors())
+	}
+}
+
+func TestStreamingFramedCorruptPayload(t *testing.T) {
+	t.Parallel()
+
+	data := checkpointSource(8 * 1024)
+	wire, _ := framedSend(t, data)
+	corrupt := bytes.Clone(wire)
+	second := splitFrames(corrupt)[1]
+	second[len(second)-1] ^= 0xFF
+
+	var out bytes.Buffer
+	sw := replify.New().WithStreaming(bytes.NewReader(corrupt), nil)
+	sw.WithChunkSize(1024)
+	sw.WithReceiveMode(true)
+	sw.WithFraming(true)
+	sw.WithWriter(&out)
+	if w := sw.Start(context.Background()); !w.IsError() {
+		t.Fatalf("Start = %d %q, want a failure", w.StatusCode(), w.Message())
+	}
+	if !hasError(sw, replify.ErrFrameChecksum) {
+		t.Errorf("errors = %v, want ErrFrameChecksum", sw.Errors())
+	}
+	if got := sw.GetStats().FailedChunks; got != 1 {
+		t.Errorf("FailedChunks = %d, want 1", got)
+	}
+	if !bytes.Equal(out.Bytes(), data[:1024]) {
+		t.Errorf("wrote %d bytes, want only the 1024 bytes before the corrupt frame", out.Len())
+	}
+}
+
+func TestStreamingFramedSendStopsOnFailedChunk(t *testing.T) {
+	t.Parallel()
+
+	errTransform := errors.New("transform failed")
+	var wire bytes.Buffer
+	sw := replify.New().WithStreaming(bytes.NewReader(checkpointSource(4*1024)), nil)
+	sw.WithChunkSize(1024)
+	sw.WithFraming(true)
+	sw.WithWriter(&wire)
+	sw.WithTransform(func(c *replify.StreamChunk) error {
+		if c.SequenceNumber == 1 {
+			return errTransform
+		}
+		return nil
+	})
+	if w := sw.Start(context.Background()); !w.IsError() {
+		t.Fatalf("Start = %d %q, want a failure", w.StatusCode(), w.Message())
+	}
+	if !hasError(sw, errTransform) {
+		t.Errorf("errors = %v, want the transform error", sw.Errors())
+	}
+	if _, ok := sw.Trailer(); ok {
+		t.Error("a halted sender must not write a trailer")
+	}
+	if frames := splitFrames(wire.Bytes()); len(frames) != 1 {
+		t.Errorf("sent %d frames, want only the frame before the failed chunk", len(frames))
+	}
+}
@@ -0,0 +1,276 @@
+package replify
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"slices"
+	"time"
+)
+
+// WithManifest enables or disables the integrity manifest of the streaming
+// runs.
+//
+// With the manifest enabled, every chunk transferred successfully is digested
+// with SHA-256: the source data when sending, the data written when
+// receiving. When the run completes, the [StreamManifest] listing the chunk
+// digests and their Merkle root is attached to the wrapper meta under
+// "manifest", and is returned by [StreamingWrapper.Manifest]. A run in
+// which a chunk failed has no manifest. Store it with
+// the transferred data and check the data later with [VerifyManifest].
+//
+// The Merkle tree follows the domain separation of RFC 6962: a leaf is
+// SHA-256(0x00 || chunk digest), a node SHA-256(0x01 || left || right), and
+// the last node of an odd level is promoted unchanged.
+//
+// Parameters:
+//   - `enabled`: Whether the manifest is built.
+//
+// Returns:
+//   - A pointer to the underlying [wrapper] instance, allowing for method chaining.
+//   - If the streaming wrapper is nil, returns a new wrapper with an error message.
+//
+// Example:
+//
+//	streaming := replify.New().WithStreaming(file, nil)
+//	streaming.WithManifest(true)
+//	streaming.WithWriter(conn)
+//	if result := streaming.Start(ctx); result.IsSuccess() {
+//	    manifest, _ := streaming.Manifest()
+//	    os.WriteFile("export.manifest.json", []byte(manifest.JSON()), 0o644)
+//	}
+func (sw *StreamingWrapper) WithManifest(enabled bool) *wrapper {
+	if sw == nil {
+		return respondStreamBadRequestDefault()
+	}
+	sw.config.Manifest = enabled
+	sw.wrapper.WithDebuggingKV("manifest", enabled)
+	return sw.wrapper
+}
+
+// Manifest returns the manifest of the last completed run. Call it once
+// Start has returned.
+//
+// Returns:
+//   - The manifest of the run.
+//   - `false` if the manifest is disabled or the last run did not complete.
+func (sw *StreamingWrapper) Manifest() (*StreamManifest, bool) {
+	if sw == nil {
+		return nil, false
+	}
+	sw.mu.RLock()
+	mb := sw.manifest
+	sw.mu.RUnlock()
+	if mb == nil {
+		return nil, false
+	}
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	return mb.result, mb.result != nil
+}
+
+// JSON returns the JSON representation of the manifest.
+func (m *StreamManifest) JSON() string {
+	return jsonpass(m)
+}
+
+// VerifyManifest checks data against a [StreamManifest] and identifies the
+// chunks that differ.
+//
+// The data is read sequentially from the offset of the first chunk of the
+// manifest; the bytes before it are skipped. A chunk whose digest differs,
+// including a chunk cut short by the end of the data, is reported as
+// corrupt, and the chunks past the end of the data as missing.
+//
+// Parameters:
+//   - `r`: The data, typically the received file.
+//   - `manifest`: The manifest of the transfer.
+//
+// Returns:
+//   - The verification report; Valid is true when the data matches exactly.
+//   - An error if the manifest is nil or uses another algorithm, or reading fails.
+//
+// Example:
+//
+//	report, err := replify.VerifyManifest(file, manifest)
+//	if err == nil && !report.Valid {
+//	    log.Printf("corrupt chunks: %v, missing: %v", report.Corrupt, report.Missing)
+//	}
+func VerifyManifest(r io.Reader, manifest *StreamManifest) (*ManifestReport, error) {
+	if manifest == nil {
+		return nil, NewError("VerifyManifest: manifest is nil")
+	}
+	if manifest.Algorithm != manifestAlgorithm {
+		return nil, NewErrorf("VerifyManifest: unsupported algorithm %q", manifest.Algorithm)
+	}
+	if r == nil {
+		return nil, NewError("VerifyManifest: reader is nil")
+	}
+
+	report := &ManifestReport{}
+	if len(manifest.Chunks) > 0 && manifest.Chunks[0].Offset > 0 {
+		if _, err := io.CopyN(io.Discard, r, manifest.Chunks[0].Offset); err != nil && err != io.EOF {
+			return nil, NewErrorf("VerifyManifest: %v", err)
+		}
+	}
+
+	var buf []byte
+	digests := make([][]byte, 0, len(manifest.Chunks))
+	eof := false
+	for _, chunk := range manifest.Chunks {
+		if eof {
+			report.Missing = append(report.Missing, chunk.Sequence)
+			continue
+		}
+		if int64(cap(buf)) < chunk.Size {
+			buf = make([]byte, chunk.Size)
+		}
+		n, err := io.ReadFull(r, buf[:chunk.Size])
+		switch err {
+		case nil:
+		case io.EOF, io.ErrUnexpectedEOF:
+			eof = true
+		default:
+			return nil, NewErrorf("VerifyManifest: chunk %d: %v", chunk.Sequence, err)
+		}
+		if n == 0 && eof {
+			report.Missing = append(report.Missing, chunk.Sequence)
+			continue
+		}
+		sum := sha256.Sum256(buf[:n])
+		digests = append(digests, sum[:])
+		if hex.EncodeToString(sum[:]) != chunk.SHA256 {
+			report.Corrupt = append(report.Corrupt, chunk.Sequence)
+		}
+	}
+	if !eof {
+		extra, err := io.Copy(io.Discard, r)
+		if err != nil {
+			return nil, NewErrorf("VerifyManifest: %v", err)
+		}
+		report.ExtraBytes = extra
+	}
+
+	report.MerkleRoot = hex.EncodeToString(merkleRoot(digests))
+	report.Valid = len(report.Corrupt) == 0 && len(report.Missing) == 0 &&
+		report.ExtraBytes == 0 && report.MerkleRoot == manifest.MerkleRoot
+	return report, nil
+}
+
+// startManifest resets the manifest at the start of a run.
+func (sw *StreamingWrapper) startManifest() {
+	var first, base int64
+	if cp, ok := sw.Checkpoint(); ok && cp.Offset > 0 {
+		first, base = cp.Sequence+1, cp.Offset
+	}
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.manifest = nil
+	if sw.config.Manifest {
+		sw.manifest = newManifestBuilder(first, base)
+	}
+}
+
+// recordDigest adds the digest of a transferred chunk to the manifest.
+func (sw *StreamingWrapper) recordDigest(seq int64, data []byte) {
+	mb := sw.manifest
+	if mb == nil {
+		return
+	}
+	sum := sha256.Sum256(data)
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.chunks[seq] = ManifestChunk{Sequence: seq, Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:])}
+}
+
+// finishManifest builds the manifest of a completed run and attaches it to
+// the wrapper meta. A run in which a chunk failed has no manifest: the
+// failure is recorded instead.
+func (sw *StreamingWrapper) finishManifest() {
+	mb := sw.manifest
+	if mb == nil {
+		return
+	}
+	manifest, err := mb.build(sw.stats.FailedChunks)
+	if err != nil {
+		sw.recordError(err)
+		sw.wrapper.WithDebuggingKV("manifest_error", err.Error())
+		return
+	}
+	sw.wrapper.
+		WithCustomFieldKV(manifestMetaKey, manifest).
+		WithDebuggingKV("merkle_root", manifest.MerkleRoot)
+}
+
+// build assembles the manifest from the collected digests. It refuses to
+// when chunks failed or the digested sequence has a gap, since the offsets
+// and the Merkle root would then describe data that was not transferred.
+func (mb *manifestBuilder) build(failed int64) (*StreamManifest, error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	if failed > 0 {
+		return nil, NewErrorf("stream manifest incomplete: %d chunk(s) failed", failed)
+	}
+	seqs := make([]int64, 0, len(mb.chunks))
+	for seq := range mb.chunks {
+		seqs = append(seqs, seq)
+	}
+	slices.Sort(seqs)
+	for i, seq := range seqs {
+		if want := mb.first + int64(i); seq != want {
+			return nil, NewErrorf("stream manifest incomplete: chunk %d missing", want)
+		}
+	}
+
+	manifest := &StreamManifest{
+		Algorithm: manifestAlgorithm,
+		Chunks:    make([]ManifestChunk, 0, len(seqs)),
+		CreatedAt: time.Now(),
+	}
+	digests := make([][]byte, 0, len(seqs))
+	offset := mb.base
+	for _, seq := range seqs {
+		chunk := mb.chunks[seq]
+		chunk.Offset = offset
+		offset += chunk.Size
+		manifest.TotalBytes += chunk.Size
+		manifest.Chunks = append(manifest.Chunks, chunk)
+		digest, _ := hex.DecodeString(chunk.SHA256)
+		digests = append(digests, digest)
+	}
+	manifest.MerkleRoot = hex.EncodeToString(merkleRoot(digests))
+	mb.result = manifest
+	return manifest, nil
+}
+
+// merkleRoot returns the Merkle root over chunk digests; the root of no
+// chunks is the digest of empty data.
+func merkleRoot(digests [][]byte) []byte {
+	if len(digests) == 0 {
+		sum := sha256.Sum256(nil)
+		return sum[:]
+	}
+	level := make([][]byte, len(digests))
+	for i, digest := range digests {
+		h := sha256.New()
+		h.Write([]byte{merkleLeafPrefix})
+		h.Write(digest)
+		level[i] = h.Sum(nil)
+	}
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			h := sha256.New()
+			h.Write([]byte{merkleNodePrefix})
+			h.Write(level[i])
+			h.Write(level[i+1])
+			next = append(next, h.Sum(nil))
+		}
+		level = next
+	}
+	return level[0]
+}
@@ -0,0 +1,144 @@
+package replify_test
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/sivaosorg/replify"
+)
+
+func manifestSend(t *testing.T, data []byte, strategy replify.StreamingStrategy) (*replify.StreamingWrapper, *replify.StreamManifest) {
+	t.Helper()
+	sw := newCheckpointStream(data, strategy)
+	sw.WithCompressionType(replify.CompressGzip)
+	sw.WithManifest(true)
+	sw.WithWriter(&bytes.Buffer{})
+	if w := sw.Start(context.Background()); w.IsError() {
+		t.Fatalf("%s: Start: %v", strategy, w.Error())
+	}
+	manifest, ok := sw.Manifest()
+	if !ok {
+		t.Fatalf("%s: no manifest", strategy)
+	}
+	return sw, manifest
+}
+
+func TestStreamingManifest(t *testing.T) {
+	t.Parallel()
+
+	data := checkpointSource(10*1024 + 100)
+	var root string
+	for _, strategy := range []replify.StreamingStrategy{replify.StrategyDirect, replify.StrategyChunked} {
+		sw, manifest := manifestSend(t, data, strategy)
+		if len(manifest.Chunks) != 11 || manifest.TotalBytes != int64(len(data)) {
+			t.Errorf("%s: %d chunks, %d bytes", strategy, len(manifest.Chunks), manifest.TotalBytes)
+		}
+		if last := manifest.Chunks[10]; last.Offset != 10*1024 || last.Size != 100 {
+			t.Errorf("%s: last chunk = %+v", strategy, last)
+		}
+		if root == "" {
+			root = manifest.MerkleRoot
+		} else if manifest.MerkleRoot != root {
+			t.Errorf("%s: Merkle root differs between strategies", strategy)
+		}
+		if attached, ok := sw.GetWrapper().Meta().OnCustom("manifest").(*replify.StreamManifest); !ok || attached != manifest {
+			t.Errorf("%s: manifest not attached to meta", strategy)
+		}
+
+		report, err := replify.VerifyManifest(bytes.NewReader(data), manifest)
+		if err != nil || !report.Valid || report.MerkleRoot != root {
+			t.Errorf("%s: VerifyManifest = %+v, %v", strategy, report, err)
+		}
+	}
+}
+
+func TestStreamingManifestReceive(t *testing.T) {
+	t.Parallel()
+
+	data := bytes.Repeat(checkpointSource(512), 12)
+	wire, _ := framedSend(t, data)
+	_, sent := manifestSend(t, data, replify.StrategyChunked)
+
+	sw := replify.New().WithStreaming(bytes.NewReader(wire), nil)
+	sw.WithChunkSize(1024)
+	sw.WithReceiveMode(true)
+	sw.WithFraming(true)
+	sw.WithManifest(true)
+	sw.WithWriter(&bytes.Buffer{})
+	if w := sw.Start(context.Background()); w.IsError() {
+		t.Fatalf("receive: %v", w.Error())
+	}
+	received, ok := sw.Manifest()
+	if !ok || received.MerkleRoot != sent.MerkleRoot {
+		t.Errorf("receiver root = %s, sender root = %s", received.MerkleRoot, sent.MerkleRoot)
+	}
+}
+
+func TestVerifyManifestCorruption(t *testing.T) {
+	t.Parallel()
+
+	data := checkpointSource(8 * 1024)
+	_, manifest := manifestSend(t, data, replify.StrategyDirect)
+
+	corrupt := bytes.Clone(data)
+	corrupt[2*1024+7] ^= 1
+	corrupt[5*1024] ^= 1
+	report, err := replify.VerifyManifest(bytes.NewReader(corrupt), manifest)
+	if err != nil || report.Valid || !slices.Equal(report.Corrupt, []int64{2, 5}) || len(report.Missing) != 0 {
+		t.Errorf("corrupt: %+v, %v", report, err)
+	}
+
+	report, _ = replify.VerifyManifest(bytes.NewReader(data[:5*1024+10]), manifest)
+	if report.Valid || !slices.Equal(report.Corrupt, []int64{5}) || !slices.Equal(report.Missing, []int64{6, 7}) {
+		t.Errorf("truncated: %+v", report)
+	}
+
+	report, _ = replify.VerifyManifest(bytes.NewReader(append(bytes.Clone(data), 'x')), manifest)
+	if report.Valid || report.ExtraBytes != 1 || len(report.Corrupt) != 0 {
+		t.Errorf("extra bytes: %+v", report)
+	}
+
+	if _, err := replify.VerifyManifest(bytes.NewReader(data), nil); err == nil {
+		t.Error("expected an error for a nil manifest")
+	}
+}
+
+func TestStreamingManifestIncomplete(t *testing.T) {
+	t.Parallel()
+
+	data := checkpointSource(4 * 1024)
+	sw := newCheckpointStream(data, replify.StrategyDirect)
+	sw.WithManifest(true)
+	sw.WithWriter(&failingWriter{fail: 2})
+	sw.Start(context.Background())
+	if manifest, ok := sw.Manifest(); ok {
+		t.Fatalf("manifest of a run with a failed chunk: %d chunks", len(manifest.Chunks))
+	}
+	if sw.GetWrapper().Meta().OnCustom("manifest") != nil {
+		t.Error("manifest attached to meta despite the failed chunk")
+	}
+
+	// A run resumed from a checkpoint starts its sequence after it.
+	first := newCheckpointStream(data, replify.StrategyDirect)
+	first.WithCheckpoint(replify.StreamCheckpoint{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	first.WithWriter(&cancellingWriter{after: 2, cancel: cancel})
+	first.Start(ctx)
+	cp, ok := first.Checkpoint()
+	if !ok || cp.Offset != 2048 {
+		t.Fatalf("checkpoint = %+v", cp)
+	}
+	resumed := newCheckpointStream(data, replify.StrategyDirect)
+	resumed.WithManifest(true)
+	resumed.WithWriter(&bytes.Buffer{})
+	resumed.WithCheckpoint(cp)
+	if w := resumed.Start(context.Background()); w.IsError() {
+		t.Fatalf("resumed: %v", w.Error())
+	}
+	if manifest, ok := resumed.Manifest(); !ok || len(manifest.Chunks) != 2 || manifest.Chunks[0].Offset != 2048 {
+		t.Errorf("resumed manifest = %+v", manifest)
+	}
+}
@@ -0,0 +1,250 @@
+package replify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WithTransform registers a transformation applied to every chunk by the
+// chunked strategy ([StrategyChunked]).
+//
+// Transforms run on the worker goroutines of the chunk pipeline, up to
+// MaxConcurrentChunks chunks at a time, so they must be safe for concurrent
+// use. They receive the uncompressed data: before compression when sending,
+// after decompression when receiving. Chunks are still written in sequence
+// order. A transform should replace chunk.Data rather than modify it in
+// place, since the source bytes back the stream checkpoint.
+//
+// Parameters:
+//   - `transform`: The transformation; transforms run in registration order.
+//
+// Returns:
+//   - A pointer to the underlying [wrapper] instance, allowing for method chaining.
+//   - If the streaming wrapper is nil, returns a new wrapper with an error message.
+//
+// Example:
+//
+//	streaming := replify.New().WithStreaming(file, nil)
+//	streaming.WithStreamingStrategy(replify.StrategyChunked)
+//	streaming.WithTransform(func(c *replify.StreamChunk) error {
+//	    c.Data = bytes.ToUpper(c.Data)
+//	    return nil
+//	})
+func (sw *StreamingWrapper) WithTransform(transform StreamTransform) *wrapper {
+	if sw == nil {
+		return respondStreamBadRequestDefault()
+	}
+	if transform == nil {
+		return sw.wrapper
+	}
+	sw.transforms = append(sw.transforms, transform)
+	sw.wrapper.WithDebuggingKV("transforms", len(sw.transforms))
+	return sw.wrapper
+}
+
+// runChunkPipeline streams the reader through a pool of MaxConcurrentChunks
+// workers that run process on each chunk, and writes the processed chunks
+// in sequence order from the calling goroutine.
+//
+// At most twice MaxConcurrentChunks chunks are in flight: the reader takes a
+// pooled buffer for each chunk and blocks until the writer releases one, so
+// a slow writer applies backpressure to the reader instead of growing
+// memory. Besides progress, the pipeline maintains AverageLatency (mean time
+// from read to write of a chunk) and PeakBandwidth. A failed chunk is
+// skipped, except in a framed stream: the frames guarantee the integrity of
+// the output, so the first failed chunk stops the run, sending or receiving.
+func (sw *StreamingWrapper) runChunkPipeline(ctx context.Context, process func(*StreamChunk) error) error {
+	workers := max(sw.config.MaxConcurrentChunks, 1)
+	window := 2 * workers
+	size := sw.config.ChunkSize
+	if sw.cdc != nil {
+		size = int64(sw.cdc.max)
+	}
+	pool := sw.bufferPool
+	if pool == nil || pool.size != size {
+		pool = NewBufferPool(size, window)
+	}
+	slots := make(chan struct{}, window)
+	jobs := make(chan *pipelineJob, window)
+	order := make(chan *pipelineJob, window)
+	readErr := make(chan error, 1)
+	ctx, halt := context.WithCancelCause(ctx)
+	defer halt(nil)
+	var fatal error
+
+	sw.stats.StartTime = time.Now()
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if err := process(job.chunk); err != nil {
+					job.chunk.Error = &ChunkError{Sequence: job.chunk.SequenceNumber, Op: ChunkOpProcess, Attempt: 1, Err: err}
+				}
+				close(job.done)
+			}
+		}()
+	}
+
+	// Reader: numbers the chunks and feeds both the workers and the writer.
+	go func() {
+		defer close(order)
+		defer close(jobs)
+		seq := sw.currentChunk
+		for {
+			if err := sw.waitIfPaused(ctx); err != nil {
+				readErr <- err
+				return
+			}
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				readErr <- ctx.Err()
+				return
+			}
+
+			buf := pool.Get()
+			chunk, err := sw.nextChunk(ctx, buf, seq)
+			if chunk != nil {
+				job := &pipelineJob{chunk: chunk, raw: chunk.Data, buf: buf, done: make(chan struct{})}
+				seq++
+				jobs <- job
+				order <- job
+			} else {
+				pool.Put(buf)
+				<-slots
+			}
+
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	// Writer: emits the chunks in order as their processing completes.
+	meter := newBandwidthMeter(sw.stats.StartTime)
+	var latency time.Duration
+	var measured int64
+	for job := range order {
+		<-job.done
+		chunk := job.chunk
+		if ctx.Err() == nil {
+			sw.resolveChunk(chunk)
+			if chunk.Error != nil {
+				sw.recordError(chunk.Error)
+				sw.stats.FailedChunks++
+			} else if sw.writer != nil {
+				if writeErr := sw.emitChunk(ctx, chunk); writeErr != nil {
+					chunk.Error = writeErr
+					sw.recordError(writeErr)
+					sw.stats.FailedChunks++
+				}
+			}
+			raw := job.raw
+			if sw.config.IsReceiving {
+				raw = chunk.Data
+			}
+			sw.acknowledge(chunk.SequenceNumber, raw, chunk.Error)
+			if chunk.Error != nil && sw.framer != nil {
+				fatal = chunk.Error
+				halt(fatal)
+			}
+
+			now := time.Now()
+			latency += now.Sub(chunk.Timestamp)
+			measured++
+			sw.mu.Lock()
+			sw.currentChunk = chunk.SequenceNumber + 1
+			sw.stats.AverageLatency = latency / time.Duration(measured)
+			sw.stats.PeakBandwidth = meter.observe(int64(len(chunk.Data)), now)
+			sw.mu.Unlock()
+			sw.updateProgress(chunk)
+
+			if sw.config.ThrottleRate > 0 {
+				elapsed := time.Since(sw.stats.StartTime)
+				expectedTime := time.Duration(float64(atomic.LoadInt64(&sw.progress.TransferredBytes)) / float64(sw.config.ThrottleRate) * float64(time.Second))
+				if elapsed < expectedTime {
+					time.Sleep(expectedTime - elapsed)
+				}
+			}
+		}
+		pool.Put(job.buf)
+		<-slots
+	}
+	wg.Wait()
+
+	sw.stats.EndTime = time.Now()
+	sw.mu.Lock()
+	// A run shorter than one window has no completed window: its peak is its average.
+	sw.stats.PeakBandwidth = max(sw.stats.PeakBandwidth, sw.stats.AverageBandwidth)
+	sw.mu.Unlock()
+
+	if fatal != nil {
+		return fmt.Errorf("framed stream failed: %w", fatal)
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("streaming cancelled: %w", ctx.Err())
+	}
+	select {
+	case err := <-readErr:
+		sw.recordError(err)
+		sw.stats.FailedChunks++
+		return fmt.Errorf("read error: %w", err)
+	default:
+	}
+	return nil
+}
+
+// nextChunk reads the chunk numbered seq from the source, into buf when it
+// fits. It returns a nil chunk when nothing was read.
+func (sw *StreamingWrapper) nextChunk(ctx context.Context, buf []byte, seq int64) (*StreamChunk, error) {
+	if sw.framer != nil && sw.config.IsReceiving {
+		return sw.readFrame(buf, seq)
+	}
+	if sw.cdc != nil {
+		return sw.nextCDCChunk(buf, seq)
+	}
+	n, err := sw.readChunk(ctx, buf, seq)
+	if n == 0 {
+		return nil, err
+	}
+	return &StreamChunk{
+		SequenceNumber:  seq,
+		Data:            buf[:n],
+		Size:            int64(n),
+		Timestamp:       time.Now(),
+		Compressed:      sw.config.IsReceiving && sw.config.Compression != CompressNone,
+		CompressionType: sw.config.Compression,
+	}, err
+}
+
+// applyTransforms runs the registered transforms on a chunk.
+func (sw *StreamingWrapper) applyTransforms(chunk *StreamChunk) error {
+	for _, transform := range sw.transforms {
+		if err := transform(chunk); err != nil {
+			return fmt.Errorf("transform chunk %d: %w", chunk.SequenceNumber, err)
+		}
+	}
+	return nil
+}
+
+// observe records n bytes written at t and returns the peak bandwidth, in
+// bytes/second, of the completed windows.
+func (m *bandwidthMeter) observe(n int64, t time.Time) int64 {
+	m.bytes += n
+	if elapsed := t.Sub(m.start); elapsed >= m.window {
+		m.peak = max(m.peak, int64(float64(m.bytes)/elapsed.Seconds()))
+		m.start, m.bytes = t, 0
+	}
+	return m.peak
+}
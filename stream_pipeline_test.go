@@ -0,0 +1,148 @@
+package replify_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sivaosorg/replify"
+)
+
+// countingReader counts the Read calls that returned data.
+type countingReader struct {
+	r     io.Reader
+	reads atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 {
+		c.reads.Add(1)
+	}
+	return n, err
+}
+
+// gateWriter blocks every write until the gate is opened.
+type gateWriter struct {
+	gate chan struct{}
+	mu   sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (g *gateWriter) Write(p []byte) (int, error) {
+	<-g.gate
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.buf.Write(p)
+}
+
+func invert(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i, c := range b {
+		out[i] = ^c
+	}
+	return out
+}
+
+func TestStreamingChunkPipelineOrder(t *testing.T) {
+	t.Parallel()
+
+	data := checkpointSource(32 * 1024)
+	var out bytes.Buffer
+	sw := newCheckpointStream(data, replify.StrategyChunked)
+	sw.WithWriter(&out)
+	sw.WithMaxConcurrentChunks(4)
+
+	var running, peak atomic.Int64
+	sw.WithTransform(func(c *replify.StreamChunk) error {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		// Earlier chunks take longer, so they finish out of order.
+		time.Sleep(time.Duration(32-c.SequenceNumber%32) * 200 * time.Microsecond)
+		c.Data = invert(c.Data)
+		return nil
+	})
+
+	if w := sw.Start(context.Background()); w.IsError() {
+		t.Fatalf("Start: %v", w.Error())
+	}
+	if !bytes.Equal(out.Bytes(), invert(data)) {
+		t.Fatal("pipeline output is out of order or incomplete")
+	}
+	if p := peak.Load(); p < 2 || p > 4 {
+		t.Errorf("peak concurrency = %d, want 2..4", p)
+	}
+
+	stats := sw.GetStats()
+	if stats.TotalChunks != 32 {
+		t.Errorf("TotalChunks = %d, want 32", stats.TotalChunks)
+	}
+	if stats.AverageLatency <= 0 {
+		t.Errorf("AverageLatency = %s, want > 0", stats.AverageLatency)
+	}
+	if stats.PeakBandwidth <= 0 || stats.PeakBandwidth < stats.AverageBandwidth {
+		t.Errorf("PeakBandwidth = %d, AverageBandwidth = %d", stats.PeakBandwidth, stats.AverageBandwidth)
+	}
+}
+
+func TestStreamingChunkPipelineBackpressure(t *testing.T) {
+	t.Parallel()
+
+	src := &countingReader{r: bytes.NewReader(checkpointSource(64 * 1024))}
+	out := &gateWriter{gate: make(chan struct{})}
+	sw := replify.New().WithStreaming(src, nil)
+	sw.WithChunkSize(1024)
+	sw.WithStreamingStrategy(replify.StrategyChunked)
+	sw.WithMaxConcurrentChunks(2)
+	sw.WithWriter(out)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sw.Start(context.Background())
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if reads := src.reads.Load(); reads > 4 {
+		t.Errorf("reader ran %d chunks ahead of a blocked writer, want at most 4", reads)
+	}
+	close(out.gate)
+	<-done
+	if got := out.buf.Len(); got != 64*1024 {
+		t.Errorf("wrote %d bytes, want %d", got, 64*1024)
+	}
+}
+
+func TestStreamingChunkPipelineFailedChunk(t *testing.T) {
+	t.Parallel()
+
+	data := checkpointSource(4 * 1024)
+	var out bytes.Buffer
+	sw := newCheckpointStream(data, replify.StrategyChunked)
+	sw.WithWriter(&out)
+	sw.WithTransform(func(c *replify.StreamChunk) error {
+		if c.SequenceNumber == 2 {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	sw.Start(context.Background())
+
+	if !sw.HasErrors() || sw.GetStats().FailedChunks != 1 {
+		t.Fatalf("FailedChunks = %d, errors = %v", sw.GetStats().FailedChunks, sw.Errors())
+	}
+	want := append(append([]byte(nil), data[:2048]...), data[3072:]...)
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Error("a failed chunk must be skipped without reordering the others")
+	}
+}
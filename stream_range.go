@@ -0,0 +1,139 @@
+package replify
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/sivaosorg/replify/pkg/strutil"
+)
+
+// ServeRange streams the source to an HTTP response, honoring Range
+// requests.
+//
+// Ranges apply when the source is an io.ReadSeeker sent as is: not in
+// receive mode, and without compression, framing or transforms, which all
+// change the bytes on the wire. A single range is streamed through Start,
+// with its throttling, progress tracking and hooks, as a 206 Partial Content
+// response; several ranges are copied directly as multipart/byteranges. An
+// unsatisfiable Range is answered with 416. If-Range is validated against
+// the ETag transport header of the wrapper (see [wrapper.WithHTTPHeader]);
+// without it, a conditional range request receives the whole source. The
+// Content-Type transport header, application/octet-stream by default, types
+// the response. Other sources are streamed whole with "Accept-Ranges: none".
+//
+// Parameters:
+//   - `rw`: The response writer; it becomes the writer of the stream.
+//   - `r`: The request; its context bounds the streaming.
+//
+// Returns:
+//   - A pointer to the underlying [wrapper] instance, with the outcome of the transfer.
+//   - If the streaming wrapper is nil, returns a new wrapper with an error message.
+//
+// Example:
+//
+//	func download(rw http.ResponseWriter, r *http.Request) {
+//	    f, _ := os.Open("backup.tar")
+//	    defer f.Close()
+//	    streaming := replify.New().
+//	        WithHTTPHeader(replify.HeaderETag, `"backup-2026-10-18"`).
+//	        WithStreaming(f, nil)
+//	    streaming.WithThrottleRate(10 << 20)
+//	    streaming.ServeRange(rw, r)
+//	}
+func (sw *StreamingWrapper) ServeRange(rw http.ResponseWriter, r *http.Request) *wrapper {
+	if sw == nil {
+		return respondStreamBadRequestDefault()
+	}
+	if rw == nil || r == nil {
+		return sw.wrapper.
+			WithStatusCode(http.StatusBadRequest).
+			WithMessage("response writer and request are required").
+			BindCause()
+	}
+	header := rw.Header()
+	contentType := sw.wrapper.HTTPHeader(HeaderContentType)
+	if strutil.IsEmpty(contentType) {
+		contentType = MediaTypeApplicationOctetStream.String()
+	}
+	header.Set(HeaderContentType.String(), contentType)
+	sw.WithWriter(rw)
+
+	seeker, seekable := sw.reader.(io.ReadSeeker)
+	if !seekable || sw.config.IsReceiving || sw.config.Compression != CompressNone || sw.config.Framed || len(sw.transforms) > 0 {
+		header.Set(HeaderAcceptRanges.String(), "none")
+		rw.WriteHeader(http.StatusOK)
+		return sw.Start(r.Context())
+	}
+
+	size, err := seeker.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = seeker.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		http.Error(rw, "source is not readable", http.StatusInternalServerError)
+		return sw.wrapper.
+			WithErrorAck(err).
+			WithStatusCode(http.StatusInternalServerError)
+	}
+	header.Set(HeaderAcceptRanges.String(), rangeUnit)
+	etag := sw.wrapper.HTTPHeader(HeaderETag)
+	if strutil.IsNotEmpty(etag) {
+		header.Set(HeaderETag.String(), etag)
+	}
+
+	ranges, err := requestedRanges(r, size, etag)
+	switch {
+	case errors.Is(err, ErrRangeUnsatisfiable):
+		header.Set(HeaderContentRange.String(), fmt.Sprintf("%s */%d", rangeUnit, size))
+		rw.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		return sw.wrapper.
+			WithStatusCode(http.StatusRequestedRangeNotSatisfiable).
+			WithMessagef("Range %q not satisfiable for %d bytes", r.Header.Get(HeaderRange.String()), size)
+	case len(ranges) > 1:
+		if err := writeRanges(rw, r, seeker, size, contentType, ranges, false); err != nil {
+			sw.recordError(err)
+			return sw.wrapper.
+				WithErrorAck(err).
+				WithStatusCode(http.StatusInternalServerError)
+		}
+		return sw.wrapper.
+			WithStatusCode(http.StatusPartialContent).
+			WithMessagef("Served %d ranges", len(ranges)).
+			WithDebuggingKV("ranges", len(ranges))
+	}
+
+	status, br := http.StatusOK, ByteRange{Start: 0, Length: size}
+	if len(ranges) == 1 {
+		status, br = http.StatusPartialContent, ranges[0]
+		header.Set(HeaderContentRange.String(), br.ContentRange(size))
+	}
+	header.Set(HeaderContentLength.String(), strconv.FormatInt(br.Length, 10))
+	rw.WriteHeader(status)
+	if r.Method == http.MethodHead {
+		return sw.wrapper.WithStatusCode(status)
+	}
+
+	if br.Start > 0 || br.Length < size {
+		if ra, ok := seeker.(io.ReaderAt); ok {
+			sw.reader = io.NewSectionReader(ra, br.Start, br.Length)
+		} else {
+			if _, err := seeker.Seek(br.Start, io.SeekStart); err != nil {
+				sw.recordError(err)
+				return sw.wrapper.
+					WithErrorAck(err).
+					WithStatusCode(http.StatusInternalServerError)
+			}
+			sw.reader = io.LimitReader(seeker, br.Length)
+		}
+		sw.wrapper.WithDebuggingKV("range", br.ContentRange(size))
+	}
+	sw.WithTotalBytes(br.Length)
+	result := sw.Start(r.Context())
+	if status == http.StatusPartialContent && !result.IsError() {
+		result.WithStatusCode(status)
+	}
+	return result
+}
@@ -0,0 +1,150 @@
+package replify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// WithRetryPolicy sets how failed chunk reads and writes are retried.
+//
+// Before each retry the [StreamingCallback] receives a [*ChunkError] with
+// Retrying set, the hook is notified and StreamProgress.Retries is
+// incremented; StreamingStats.RetriedChunks counts the chunks that needed at
+// least one retry. A chunk that still fails after MaxAttempts is recorded
+// in [StreamingWrapper.Errors] as a [*ChunkError].
+//
+// Parameters:
+//   - `policy`: The retry policy; nil disables retries.
+//
+// Returns:
+//   - A pointer to the underlying [wrapper] instance, allowing for method chaining.
+//   - If the streaming wrapper is nil, returns a new wrapper with an error message.
+//
+// Example:
+//
+//	policy := replify.NewChunkRetryPolicy()
+//	policy.Retryable = func(err error) bool { return errors.Is(err, syscall.ECONNRESET) }
+//	streaming.WithRetryPolicy(policy)
+func (sw *StreamingWrapper) WithRetryPolicy(policy *ChunkRetryPolicy) *wrapper {
+	if sw == nil {
+		return respondStreamBadRequestDefault()
+	}
+	sw.retry = policy
+	if policy != nil {
+		sw.wrapper.WithDebuggingKV("retry_max_attempts", policy.attempts())
+	}
+	return sw.wrapper
+}
+
+// Error returns the error message.
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("chunk %d: %s failed (attempt %d): %v", e.Sequence, e.Op, e.Attempt, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ChunkError) Unwrap() error {
+	return e.Err
+}
+
+// attempts returns the number of attempts per chunk.
+func (p *ChunkRetryPolicy) attempts() int {
+	if p == nil {
+		return 1
+	}
+	if p.MaxAttempts <= 0 {
+		return 3
+	}
+	return p.MaxAttempts
+}
+
+// allows reports whether the failed attempt may be retried.
+func (p *ChunkRetryPolicy) allows(err error, attempt int) bool {
+	if p == nil || attempt >= p.attempts() {
+		return false
+	}
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// readChunk reads the next chunk of the source into buf, retrying failed
+// reads per the retry policy when the source is an io.Seeker.
+func (sw *StreamingWrapper) readChunk(ctx context.Context, buf []byte, seq int64) (int, error) {
+	for attempt := 1; ; attempt++ {
+		n, err := sw.reader.Read(buf)
+		if err == nil || err == io.EOF {
+			return n, err
+		}
+		e := &ChunkError{Sequence: seq, Op: ChunkOpRead, Attempt: attempt, Err: err}
+		seeker, seekable := sw.reader.(io.Seeker)
+		if !seekable || !sw.retry.allows(err, attempt) {
+			return n, e
+		}
+		if _, serr := seeker.Seek(-int64(n), io.SeekCurrent); serr != nil {
+			return n, e
+		}
+		if werr := sw.waitRetry(ctx, e); werr != nil {
+			return 0, e
+		}
+	}
+}
+
+// writeChunk writes the chunk data once granted by the bandwidth limiter,
+// retrying failed writes per the retry policy with the bytes not yet
+// accepted by the writer.
+func (sw *StreamingWrapper) writeChunk(ctx context.Context, chunk *StreamChunk) error {
+	if sw.writer == nil {
+		return nil
+	}
+	if err := sw.waitBandwidth(ctx, len(chunk.Data)); err != nil {
+		return &ChunkError{Sequence: chunk.SequenceNumber, Op: ChunkOpWrite, Attempt: 1, Err: err}
+	}
+	data := chunk.Data
+	for attempt := 1; ; attempt++ {
+		n, err := sw.writer.Write(data)
+		data = data[min(max(n, 0), len(data)):]
+		if err == nil && len(data) == 0 {
+			return nil
+		}
+		if err == nil {
+			err = io.ErrShortWrite
+		}
+		e := &ChunkError{Sequence: chunk.SequenceNumber, Op: ChunkOpWrite, Attempt: attempt, Err: err}
+		if !sw.retry.allows(err, attempt) {
+			return e
+		}
+		if werr := sw.waitRetry(ctx, e); werr != nil {
+			return e
+		}
+	}
+}
+
+// waitRetry notifies the callback and hook of a retry, synchronously and in
+// order, then waits for the backoff delay of the failed attempt.
+func (sw *StreamingWrapper) waitRetry(ctx context.Context, e *ChunkError) error {
+	e.Retrying = true
+	sw.mu.Lock()
+	sw.progress.Retries++
+	if e.Attempt == 1 {
+		sw.stats.RetriedChunks++
+	}
+	sw.mu.Unlock()
+	notified := *e
+	sw.fireCallback(&notified)
+	sw.fireHook(sw.wrapper.ReplyPtr())
+
+	timer := time.NewTimer(sw.retry.Backoff.Delay(e.Attempt))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		e.Retrying = false
+		return nil
+	case <-ctx.Done():
+		e.Retrying = false
+		return ctx.Err()
+	}
+}
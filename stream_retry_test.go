@@ -0,0 +1,154 @@
+package replify_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sivaosorg/replify"
+)
+
+var errFlaky = errors.New("flaky")
+
+// flakyWriter accepts half of a write and fails it, `failures` times.
+type flakyWriter struct {
+	buf      bytes.Buffer
+	failures int
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	if w.failures > 0 && len(p) > 1 {
+		w.failures--
+		n, _ := w.buf.Write(p[:len(p)/2])
+		return n, errFlaky
+	}
+	return w.buf.Write(p)
+}
+
+// flakyReader returns part of a read along with an error, once.
+type flakyReader struct {
+	*bytes.Reader
+	failed bool
+}
+
+func (r *flakyReader) Read(p []byte) (int, error) {
+	if !r.failed && int64(r.Len()) < r.Size()/2 {
+		r.failed = true
+		n, _ := r.Reader.Read(p[:len(p)/3])
+		return n, errFlaky
+	}
+	return r.Reader.Read(p)
+}
+
+func fastRetryPolicy(attempts int) *replify.ChunkRetryPolicy {
+	policy := replify.NewChunkRetryPolicy()
+	policy.MaxAttempts = attempts
+	policy.Backoff = replify.Backoff{Initial: time.Millisecond, Max: time.Millisecond, Multiplier: 1}
+	return policy
+}
+
+func TestStreamingRetryWrite(t *testing.T) {
+	t.Parallel()
+
+	data := checkpointSource(8 * 1024)
+	out := &flakyWriter{failures: 2}
+	sw := newCheckpointStream(data, replify.StrategyChunked)
+	sw.WithWriter(out)
+	sw.WithRetryPolicy(fastRetryPolicy(3))
+
+	var mu sync.Mutex
+	var retries []*replify.ChunkError
+	sw.WithCallback(func(_ *replify.StreamProgress, err error) {
+		var ce *replify.ChunkError
+		if errors.As(err, &ce) {
+			mu.Lock()
+			retries = append(retries, ce)
+			mu.Unlock()
+		}
+	})
+
+	if w := sw.Start(context.Background()); w.IsError() || sw.HasErrors() {
+		t.Fatalf("Start: %v %v", w.Error(), sw.Errors())
+	}
+	if !bytes.Equal(out.buf.Bytes(), data) {
+		t.Fatal("retried writes must resume after the accepted bytes")
+	}
+	if got := sw.GetStats().RetriedChunks; got != 1 {
+		t.Errorf("RetriedChunks = %d, want 1", got)
+	}
+	if got := sw.GetProgress().Retries; got != 2 {
+		t.Errorf("Retries = %d, want 2", got)
+	}
+
+	// Retries are notified before Start returns, in order.
+	mu.Lock()
+	defer mu.Unlock()
+	if len(retries) != 2 {
+		t.Fatalf("callback saw %d retries, want 2", len(retries))
+	}
+	for i, ce := range retries {
+		if !ce.Retrying || ce.Op != replify.ChunkOpWrite || ce.Sequence != 0 || ce.Attempt != i+1 {
+			t.Errorf("unexpected retry notification %+v", *ce)
+		}
+	}
+}
+
+func TestStreamingRetryExhausted(t *testing.T) {
+	t.Parallel()
+
+	out := &flakyWriter{failures: 100}
+	sw := newCheckpointStream(checkpointSource(2048), replify.StrategyDirect)
+	sw.WithWriter(out)
+	sw.WithRetryPolicy(fastRetryPolicy(2))
+	sw.Start(context.Background())
+
+	var ce *replify.ChunkError
+	if errs := sw.Errors(); len(errs) == 0 || !errors.As(errs[0], &ce) {
+		t.Fatalf("Errors() = %v, want a *ChunkError", errs)
+	}
+	if ce.Op != replify.ChunkOpWrite || ce.Attempt != 2 || ce.Retrying || !errors.Is(ce, errFlaky) {
+		t.Errorf("unexpected chunk error %+v", *ce)
+	}
+	if got := sw.GetStats().FailedChunks; got != 2 {
+		t.Errorf("FailedChunks = %d, want 2", got)
+	}
+}
+
+func TestStreamingRetryRead(t *testing.T) {
+	t.Parallel()
+
+	data := checkpointSource(8 * 1024)
+	for _, strategy := range []replify.StreamingStrategy{replify.StrategyDirect, replify.StrategyChunked} {
+		var out bytes.Buffer
+		config := replify.NewStreamConfig()
+		config.ChunkSize = 1024
+		config.Strategy = strategy
+		sw := replify.New().WithStreaming(&flakyReader{Reader: bytes.NewReader(data)}, config)
+		sw.WithWriter(&out)
+		sw.WithRetryPolicy(fastRetryPolicy(2))
+		if w := sw.Start(context.Background()); w.IsError() {
+			t.Fatalf("%s: Start: %v", strategy, w.Error())
+		}
+		if !bytes.Equal(out.Bytes(), data) {
+			t.Errorf("%s: output differs after a retried read", strategy)
+		}
+		if got := sw.GetStats().RetriedChunks; got != 1 {
+			t.Errorf("%s: RetriedChunks = %d, want 1", strategy, got)
+		}
+	}
+
+	// Without a policy the read error is surfaced as a structured error.
+	sw := replify.New().WithStreaming(&flakyReader{Reader: bytes.NewReader(data)}, nil)
+	sw.WithWriter(io.Discard)
+	if w := sw.Start(context.Background()); !w.IsError() {
+		t.Fatal("expected the read error to fail the stream")
+	}
+	var ce *replify.ChunkError
+	if !errors.As(sw.Errors()[0], &ce) || ce.Op != replify.ChunkOpRead {
+		t.Errorf("Errors() = %v, want a read *ChunkError", sw.Errors())
+	}
+}
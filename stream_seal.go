@@ -0,0 +1,205 @@
+package replify
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+// WithEncryption seals the stream with AES-256-GCM, or opens a sealed
+// stream in receive mode, with keys from the given provider.
+//
+// Sealing builds on the frame format (see [StreamingWrapper.WithFraming]),
+// which it enables. The sender takes the current key of the provider,
+// derives a key for the stream with HKDF-SHA256 from it and a random salt,
+// and opens the stream with a seal frame (kind 0x03) carrying the salt and
+// the key identifier. Every data frame payload, and the trailer, is then
+// sealed after compression, with a nonce derived from the frame kind and
+// sequence number and the frame kind, flags, codec and sequence number as
+// additional data, and flagged 0x02. The receiver fetches the key by its
+// identifier and opens the frames before decompressing them: a frame that
+// was altered, moved to another position or taken from another stream fails
+// its chunk with [ErrFrameAuth], and the sealed trailer authenticates the
+// end of the stream. A receiver with a key provider rejects a stream that
+// is not sealed with [ErrFrameSeal], and Start refuses to send an
+// encrypted stream with another strategy than the framed chunked one.
+//
+// Parameters:
+//   - `keys`: The key provider; nil disables encryption.
+//
+// Returns:
+//   - A pointer to the underlying [wrapper] instance, allowing for method chaining.
+//   - If the streaming wrapper is nil, returns a new wrapper with an error message.
+//
+// Example:
+//
+//	keys := replify.NewStaticKeyProvider("2026-10", key)
+//
+//	// Sender
+//	streaming := replify.New().WithStreaming(export, nil)
+//	streaming.WithCompressionType(replify.CompressGzip)
+//	streaming.WithEncryption(keys)
+//	streaming.WithWriter(conn)
+//
+//	// Receiver
+//	receiving := replify.New().WithStreaming(conn, nil)
+//	receiving.WithReceiveMode(true)
+//	receiving.WithEncryption(keys)
+//	receiving.WithWriter(output)
+func (sw *StreamingWrapper) WithEncryption(keys KeyProvider) *wrapper {
+	if sw == nil {
+		return respondStreamBadRequestDefault()
+	}
+	sw.keys = keys
+	if keys != nil {
+		sw.WithFraming(true)
+	}
+	sw.wrapper.WithDebuggingKV("encrypted", keys != nil)
+	return sw.wrapper
+}
+
+// CurrentKey returns the key of the provider.
+func (p *staticKeyProvider) CurrentKey() (string, []byte, error) {
+	return p.id, p.key, nil
+}
+
+// Key returns the key of the provider if id names it.
+func (p *staticKeyProvider) Key(id string) ([]byte, error) {
+	if id != p.id {
+		return nil, NewErrorf("unknown key %q", id)
+	}
+	return p.key, nil
+}
+
+// startSeal derives the key of a sealed stream and writes its seal frame.
+func (sw *StreamingWrapper) startSeal(ctx context.Context) error {
+	if sw.keys == nil {
+		return nil
+	}
+	if sw.framer == nil {
+		return NewError("encryption requires framing")
+	}
+	id, key, err := sw.keys.CurrentKey()
+	if err != nil {
+		return fmt.Errorf("seal: current key: %w", err)
+	}
+	salt := make([]byte, sealSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return fmt.Errorf("seal: %w", err)
+	}
+	aead, err := newStreamCipher(key, salt)
+	if err != nil {
+		return fmt.Errorf("seal: key %q: %w", id, err)
+	}
+	frame := &StreamChunk{Data: appendFrame(nil, frameKindSeal, 0, "", 0, append(salt, id...))}
+	if err := sw.writeChunk(ctx, frame); err != nil {
+		return fmt.Errorf("write seal frame: %w", err)
+	}
+	sw.framer.aead = aead
+	return nil
+}
+
+// openSeal derives the key of a sealed stream from its seal frame.
+func (sw *StreamingWrapper) openSeal(seq int64, payload []byte, checksum uint32) error {
+	f := sw.framer
+	if f.aead != nil || f.frames > 0 {
+		return fmt.Errorf("%w: unexpected seal frame before frame %d", ErrFrameCorrupt, seq)
+	}
+	if sw.keys == nil {
+		return fmt.Errorf("%w: the stream is sealed but no key provider is set", ErrFrameSeal)
+	}
+	if sw.calculateChecksum(payload) != checksum {
+		return fmt.Errorf("%w: seal frame", ErrFrameChecksum)
+	}
+	if len(payload) < sealSaltSize {
+		return fmt.Errorf("%w: seal frame of %d bytes", ErrFrameCorrupt, len(payload))
+	}
+	id := string(payload[sealSaltSize:])
+	key, err := sw.keys.Key(id)
+	if err != nil {
+		return fmt.Errorf("%w: key %q: %w", ErrFrameSeal, id, err)
+	}
+	if f.aead, err = newStreamCipher(key, payload[:sealSaltSize]); err != nil {
+		return fmt.Errorf("%w: key %q: %w", ErrFrameSeal, id, err)
+	}
+	return nil
+}
+
+// checkSealed verifies that a frame is sealed exactly when the stream is
+// expected to be.
+func (sw *StreamingWrapper) checkSealed(kind, flags byte, seq int64) error {
+	sealed := flags&frameFlagSealed != 0
+	switch {
+	case sw.keys != nil && sw.framer.aead == nil:
+		return fmt.Errorf("%w: frame %d of an unsealed stream", ErrFrameSeal, seq)
+	case sealed != (sw.framer.aead != nil):
+		return fmt.Errorf("%w: frame %d (kind 0x%02x, flags 0x%02x)", ErrFrameSeal, seq, kind, flags)
+	}
+	return nil
+}
+
+// sealChunk seals the payload of an outgoing data or reference frame.
+func (sw *StreamingWrapper) sealChunk(chunk *StreamChunk) {
+	f := sw.framer
+	if f == nil || f.aead == nil {
+		return
+	}
+	codec, flags := f.frameInfo(chunk)
+	kind := chunkKind(chunk)
+	aad := sealAAD(kind, flags, codec, chunk.SequenceNumber)
+	chunk.Data = f.aead.Seal(nil, sealNonce(kind, chunk.SequenceNumber), chunk.Data, aad)
+}
+
+// openChunk opens the payload of an incoming sealed data or reference frame.
+func (sw *StreamingWrapper) openChunk(chunk *StreamChunk) error {
+	f := sw.framer
+	if f == nil || f.aead == nil {
+		return nil
+	}
+	codec, flags := f.frameInfo(chunk)
+	kind := chunkKind(chunk)
+	aad := sealAAD(kind, flags, codec, chunk.SequenceNumber)
+	plain, err := f.aead.Open(nil, sealNonce(kind, chunk.SequenceNumber), chunk.Data, aad)
+	if err != nil {
+		return fmt.Errorf("%w: frame %d", ErrFrameAuth, chunk.SequenceNumber)
+	}
+	chunk.Data = plain
+	return nil
+}
+
+// newStreamCipher derives the AES-256-GCM cipher of a stream from a
+// provided key and the stream salt.
+func newStreamCipher(key, salt []byte) (cipher.AEAD, error) {
+	if len(key) != sealKeySize {
+		return nil, NewErrorf("AES-256 key must be %d bytes, got %d", sealKeySize, len(key))
+	}
+	streamKey, err := hkdf.Key(sha256.New, key, salt, sealInfo, sealKeySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(streamKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealNonce returns the GCM nonce of a frame: its kind, then its sequence number.
+func sealNonce(kind byte, seq int64) []byte {
+	nonce := make([]byte, 12)
+	nonce[3] = kind
+	binary.BigEndian.PutUint64(nonce[4:], uint64(seq))
+	return nonce
+}
+
+// sealAAD returns the additional data authenticated with a frame payload.
+func sealAAD(kind, flags byte, codec string, seq int64) []byte {
+	aad := append(make([]byte, 0, 11+len(codec)), kind, flags, byte(len(codec)))
+	aad = append(aad, codec...)
+	return binary.BigEndian.AppendUint64(aad, uint64(seq))
+}
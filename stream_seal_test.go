@@ -0,0 +1,132 @@
+package replify_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+
+	"github.com/sivaosorg/replify"
+)
+
+var sealKey = bytes.Repeat([]byte{0x42}, 32)
+
+func sealedSend(t *testing.T, data []byte, compression replify.CompressionType) []byte {
+	t.Helper()
+	var wire bytes.Buffer
+	sw := replify.New().WithStreaming(bytes.NewReader(data), nil)
+	sw.WithChunkSize(1024)
+	sw.WithCompressionType(compression)
+	sw.WithEncryption(replify.NewStaticKeyProvider("k1", sealKey))
+	sw.WithWriter(&wire)
+	if w := sw.Start(context.Background()); w.IsError() {
+		t.Fatalf("send: %v", w.Error())
+	}
+	return wire.Bytes()
+}
+
+func sealedReceive(wire []byte, keys replify.KeyProvider) (*replify.StreamingWrapper, []byte) {
+	var out bytes.Buffer
+	sw := replify.New().WithStreaming(bytes.NewReader(wire), nil)
+	sw.WithChunkSize(1024)
+	sw.WithReceiveMode(true)
+	sw.WithEncryption(keys)
+	sw.WithWriter(&out)
+	sw.Start(context.Background())
+	return sw, out.Bytes()
+}
+
+// refreshChecksum recomputes the CRC-32 of a frame after its payload changed.
+func refreshChecksum(frame []byte) {
+	payload := frame[24+int(frame[6]):]
+	binary.BigEndian.PutUint32(frame[20:24], crc32.ChecksumIEEE(payload))
+}
+
+func TestStreamingSealedRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	data := bytes.Repeat([]byte("account=1234-5678;"), 400)
+	for _, compression := range []replify.CompressionType{replify.CompressNone, replify.CompressGzip} {
+		wire := sealedSend(t, data, compression)
+		if bytes.Contains(wire, []byte("account=")) {
+			t.Errorf("%s: plaintext visible on the wire", compression)
+		}
+		sw, out := sealedReceive(wire, replify.NewStaticKeyProvider("k1", sealKey))
+		if sw.HasErrors() || !bytes.Equal(out, data) {
+			t.Errorf("%s: round trip failed: %v", compression, sw.Errors())
+		}
+	}
+}
+
+func TestStreamingSealedTampering(t *testing.T) {
+	t.Parallel()
+
+	data := checkpointSource(4 * 1024)
+	keys := replify.NewStaticKeyProvider("k1", sealKey)
+	frames := splitFrames(sealedSend(t, data, replify.CompressNone))
+	// frames[0] is the seal frame, frames[1..4] the data frames.
+
+	tampered := bytes.Clone(bytes.Join(frames, nil))
+	first := splitFrames(tampered)[1]
+	first[30] ^= 1
+	refreshChecksum(first)
+	if sw, _ := sealedReceive(tampered, keys); !hasError(sw, replify.ErrFrameAuth) {
+		t.Errorf("altered payload: errors = %v, want ErrFrameAuth", sw.Errors())
+	}
+
+	// Swapping payloads between frames, with valid checksums, fails authentication.
+	swapped := splitFrames(bytes.Clone(bytes.Join(frames, nil)))
+	p1, p2 := swapped[1][24:], swapped[2][24:]
+	tmp := bytes.Clone(p1)
+	copy(p1, p2)
+	copy(p2, tmp)
+	refreshChecksum(swapped[1])
+	refreshChecksum(swapped[2])
+	if sw, _ := sealedReceive(bytes.Join(swapped, nil), keys); !hasError(sw, replify.ErrFrameAuth) || sw.GetStats().FailedChunks != 1 {
+		t.Errorf("swapped payloads: errors = %v", sw.Errors())
+	}
+
+	wrongKey := replify.NewStaticKeyProvider("k1", bytes.Repeat([]byte{0x24}, 32))
+	if sw, _ := sealedReceive(bytes.Join(frames, nil), wrongKey); !hasError(sw, replify.ErrFrameAuth) {
+		t.Errorf("wrong key: errors = %v, want ErrFrameAuth", sw.Errors())
+	}
+	unknownKey := replify.NewStaticKeyProvider("k2", sealKey)
+	if sw, _ := sealedReceive(bytes.Join(frames, nil), unknownKey); !hasError(sw, replify.ErrFrameSeal) {
+		t.Errorf("unknown key: errors = %v, want ErrFrameSeal", sw.Errors())
+	}
+}
+
+func TestStreamingSealedPolicy(t *testing.T) {
+	t.Parallel()
+
+	// A receiver expecting a sealed stream rejects a plain one.
+	plain, _ := framedSend(t, checkpointSource(2048))
+	if sw, _ := sealedReceive(plain, replify.NewStaticKeyProvider("k1", sealKey)); !hasError(sw, replify.ErrFrameSeal) {
+		t.Errorf("downgrade: errors = %v, want ErrFrameSeal", sw.Errors())
+	}
+
+	// A receiver without keys rejects a sealed stream.
+	sealed := sealedSend(t, checkpointSource(2048), replify.CompressNone)
+	if sw, _ := framedReceive(sealed); !hasError(sw, replify.ErrFrameSeal) {
+		t.Errorf("no keys: errors = %v, want ErrFrameSeal", sw.Errors())
+	}
+
+	// Encryption is never silently dropped by another strategy.
+	var out bytes.Buffer
+	sw := replify.New().WithStreaming(bytes.NewReader([]byte("secret")), nil)
+	sw.WithEncryption(replify.NewStaticKeyProvider("k1", sealKey))
+	sw.WithStreamingStrategy(replify.StrategyDirect)
+	sw.WithWriter(&out)
+	if w := sw.Start(context.Background()); !w.IsError() || out.Len() != 0 {
+		t.Error("expected an encrypted direct stream to be refused")
+	}
+
+	// Keys must be AES-256 keys.
+	sw = replify.New().WithStreaming(bytes.NewReader([]byte("secret")), nil)
+	sw.WithEncryption(replify.NewStaticKeyProvider("short", []byte("0123456789abcdef")))
+	sw.WithWriter(&out)
+	if w := sw.Start(context.Background()); !w.IsError() || out.Len() != 0 {
+		t.Error("expected a 16-byte key to be refused")
+	}
+}
@@ -2,8 +2,15 @@ package replify
 
 import (
 	"context"
+	"crypto/cipher"
+	"encoding/json"
+	"hash"
 	"io"
+	"io/fs"
+	"iter"
+	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sivaosorg/replify/pkg/sysx"
@@ -87,6 +94,354 @@ type Frame uintptr
 //	fmt.Println(trace) // Prints the stack trace with the frames
 type StackTrace []Frame
 
+// StackFrame is the serializable form of a single [Frame]: the fully
+// qualified function name, the source file and the line number.
+type StackFrame struct {
+	Func string `json:"func"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+
+	// Count is the number of consecutive identical frames folded into this
+	// one when [StackOptions].CollapseRepeats is enabled (0 or 1 means no folding).
+	Count int `json:"count,omitempty"`
+
+	// Source holds the lines surrounding Line when [StackOptions].SourceLines
+	// is positive and the file is readable from disk.
+	Source []SourceLine `json:"source,omitempty"`
+}
+
+// SourceLine is a single line of source code surrounding a [StackFrame].
+type SourceLine struct {
+	Line    int    `json:"line"`
+	Text    string `json:"text"`
+	Current bool   `json:"current,omitempty"`
+}
+
+// StackOptions configures how a [StackTrace] is captured and rendered:
+// depth limits, package filters, path trimming, folding of repeated frames
+// (deep recursion) and optional source-code snippets for dev-mode output.
+type StackOptions struct {
+	// Depth is the maximum number of frames kept after filtering (0 means unlimited).
+	Depth int
+
+	// Include keeps only frames whose package path matches one of the patterns.
+	// A pattern is either a package path prefix ("github.com/acme/") or a
+	// wildcard pattern ("*/internal/*"). Empty means every package.
+	Include []string
+
+	// Exclude drops frames whose package path matches one of the patterns,
+	// using the same syntax as Include. Exclude wins over Include.
+	Exclude []string
+
+	// TrimPrefixes are stripped from the beginning of file paths.
+	TrimPrefixes []string
+
+	// TrimGOPATH strips the GOROOT, GOPATH and module-cache prefixes from file paths.
+	TrimGOPATH bool
+
+	// CollapseRepeats folds consecutive identical frames into one frame with a Count.
+	CollapseRepeats bool
+
+	// SourceLines is the number of lines of context read from disk before and
+	// after each frame (0 disables source snippets).
+	SourceLines int
+}
+
+// ErrorNode is one node of a structured error tree as emitted in the
+// `errors` section of the envelope. A node describes a single error of the
+// chain (its message, Go type, optional application code and optional stack
+// trace) and lists the errors it wraps as Causes; `errors.Join` trees and
+// `Unwrap() []error` implementations therefore produce several causes.
+//
+// ErrorNode itself implements `error` and `Unwrap() []error`, so a tree
+// parsed back on the client side (see [ParseErrorTree] and [UnwrapJSON])
+// can be inspected with `errors.Is` / `errors.As` and prints the same
+// message as the original error.
+type ErrorNode struct {
+	Message string       `json:"message"`
+	Type    string       `json:"type"`
+	Code    string       `json:"code,omitempty"`
+	Stack   []StackFrame `json:"stack,omitempty"`
+	Causes  []*ErrorNode `json:"causes,omitempty"`
+}
+
+// ErrorClassification is the classification metadata attached to an error
+// through [Classify] and its shorthands ([Retryable], [Temporary],
+// [Timeout], [Public]). Retry logic can query it with [IsRetryable],
+// [IsTemporary] and [IsTimeout] instead of guessing from status codes, and
+// [wrapper.WithErrorAck] derives the response status and public message from it.
+type ErrorClassification struct {
+	// Retryable reports that repeating the same operation may succeed.
+	Retryable bool `json:"retryable"`
+
+	// Temporary reports that the failure is transient (e.g. overload, maintenance).
+	Temporary bool `json:"temporary"`
+
+	// Timeout reports that the operation failed because a deadline was exceeded.
+	Timeout bool `json:"timeout"`
+
+	// PublicMessage is a message that is safe to expose to clients; the
+	// error's own message is considered internal detail.
+	PublicMessage string `json:"public_message,omitempty"`
+
+	// StatusCode is an explicit HTTP status for the error (0 means derive it
+	// from the flags above).
+	StatusCode int `json:"status_code,omitempty"`
+}
+
+// DiffOp is the kind of a structural difference reported by [Diff]. Its
+// values match the JSON Patch (RFC 6902) operation names.
+type DiffOp string
+
+// DiffEntry is a single structural difference between two envelopes.
+type DiffEntry struct {
+	// Op is the kind of difference: [DiffAdd], [DiffRemove] or [DiffReplace].
+	Op DiffOp `json:"op"`
+
+	// Path is the JSON Pointer (RFC 6901) of the differing value, e.g. "/meta/api_version".
+	Path string `json:"path"`
+
+	// From is the value in the first envelope (nil for additions).
+	From any `json:"from,omitempty"`
+
+	// To is the value in the second envelope (nil for removals).
+	To any `json:"to,omitempty"`
+}
+
+// Differences is the ordered list of structural differences returned by [Diff].
+type Differences []DiffEntry
+
+// DiffOption configures [Diff].
+type DiffOption func(*diffOptions)
+
+// EnvelopeTransform rewrites an envelope document from one layout version
+// to an adjacent one. It receives a private deep copy of the document and
+// may modify it in place.
+type EnvelopeTransform func(doc map[string]any) (map[string]any, error)
+
+// EnvelopeDetector inspects a decoded envelope document and reports its
+// layout version when it recognizes it.
+type EnvelopeDetector func(doc map[string]any) (version string, ok bool)
+
+// EnvelopeMigration describes the transforms between two envelope layout
+// versions. Up converts a document from From to To; Down, when set, converts
+// it back. Migrations registered with [RegisterEnvelopeMigration] compose, so
+// a document can travel across several versions in one call.
+type EnvelopeMigration struct {
+	From string
+	To   string
+	Up   EnvelopeTransform
+	Down EnvelopeTransform
+}
+
+// ResponsePolicy declares how responses of matching routes are shaped.
+//
+// A policy is selected by the request path (a [match] wildcard pattern such
+// as "/api/v1/*"), optionally narrowed to HTTP methods and deployment
+// environments. Zero-valued settings leave the response untouched, so a
+// policy only needs to list what it changes. Policies are usually loaded
+// from a configuration file with [LoadPolicies].
+type ResponsePolicy struct {
+	// Name identifies the policy in logs and errors.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+
+	// Route is the wildcard pattern matched against the request path.
+	Route string `json:"route" yaml:"route"`
+
+	// Methods restricts the policy to the given HTTP methods (any when empty).
+	Methods []string `json:"methods,omitempty" yaml:"methods,omitempty"`
+
+	// Environments restricts the policy to environments matching one of the
+	// wildcard patterns (any when empty).
+	Environments []string `json:"environments,omitempty" yaml:"environments,omitempty"`
+
+	// Debug controls debug visibility: false strips the debug section, true
+	// or nil keeps it.
+	Debug *bool `json:"debug,omitempty" yaml:"debug,omitempty"`
+
+	// CompressThreshold, when positive, compresses bodies larger than this
+	// many bytes with [wrapper.CompressSafe].
+	CompressThreshold int `json:"compress_threshold,omitempty" yaml:"compress_threshold,omitempty"`
+
+	// Redact lists field names (case-insensitive, any depth) whose values are
+	// replaced in data, debug and meta custom fields.
+	Redact []string `json:"redact,omitempty" yaml:"redact,omitempty"`
+
+	// RedactWith is the replacement for redacted values ("[REDACTED]" when empty).
+	RedactWith string `json:"redact_with,omitempty" yaml:"redact_with,omitempty"`
+
+	// APIVersion is the default meta.apiVersion. It replaces the package
+	// default but never a version set explicitly on the wrapper.
+	APIVersion string `json:"api_version,omitempty" yaml:"api_version,omitempty"`
+
+	// Locale is the default meta.locale. It replaces the package default but
+	// never a locale set explicitly on the wrapper.
+	Locale string `json:"locale,omitempty" yaml:"locale,omitempty"`
+}
+
+// PolicyConfig is the document format read by [LoadPolicies] and [ParsePolicies].
+type PolicyConfig struct {
+	// Environment is the deployment environment the policies are evaluated
+	// for. When empty, the REPLIFY_ENV environment variable is used.
+	Environment string `json:"environment,omitempty" yaml:"environment,omitempty"`
+
+	// Policies are evaluated in order; see [PolicySet.Resolve].
+	Policies []ResponsePolicy `json:"policies" yaml:"policies"`
+}
+
+// PolicyUnmarshalFunc decodes a configuration document, with the signature
+// of json.Unmarshal and of the common YAML libraries' Unmarshal functions.
+type PolicyUnmarshalFunc func(data []byte, v any) error
+
+// PolicySet is a reloadable, concurrency-safe set of [ResponsePolicy] values
+// bound to a deployment environment.
+type PolicySet struct {
+	mu          sync.RWMutex
+	environment string
+	policies    []ResponsePolicy
+}
+
+// HealthStatus is the outcome of a health check or of a whole [HealthReport].
+type HealthStatus string
+
+// HealthCheckKind selects the probe a [HealthCheck] takes part in.
+type HealthCheckKind string
+
+// HealthCheckFunc probes a component. It must honor ctx, which is cancelled
+// when the check times out, and returns nil when the component is healthy.
+type HealthCheckFunc func(ctx context.Context) error
+
+// HealthCheck is a named component check registered in a [HealthRegistry].
+type HealthCheck struct {
+	// Name identifies the check in reports; it must be unique in a registry.
+	Name string
+
+	// Check probes the component.
+	Check HealthCheckFunc
+
+	// Timeout bounds the check (the registry default when zero).
+	Timeout time.Duration
+
+	// Critical marks the check as essential: its failure turns the report
+	// down (503). A failing non-critical check only degrades it.
+	Critical bool
+
+	// Kinds lists the probes the check belongs to (all probes when empty).
+	Kinds []HealthCheckKind
+}
+
+// HealthCheckResult is the outcome of one [HealthCheck].
+type HealthCheckResult struct {
+	Name      string       `json:"name"`
+	Status    HealthStatus `json:"status"`
+	Critical  bool         `json:"critical"`
+	LatencyMs float64      `json:"latency_ms"`
+	Error     string       `json:"error,omitempty"`
+}
+
+// HealthSystemInfo describes the process serving a [HealthReport].
+type HealthSystemInfo struct {
+	Hostname     string `json:"hostname,omitempty"`
+	GoVersion    string `json:"go_version"`
+	PID          int    `json:"pid"`
+	NumCPU       int    `json:"num_cpu"`
+	NumGoroutine int    `json:"num_goroutine"`
+	HeapAlloc    uint64 `json:"heap_alloc"`
+	HeapSys      uint64 `json:"heap_sys"`
+	Sys          uint64 `json:"sys"`
+	NumGC        uint32 `json:"num_gc"`
+}
+
+// HealthReport aggregates the results of the checks run for a probe.
+type HealthReport struct {
+	Status     HealthStatus        `json:"status"`
+	Kind       HealthCheckKind     `json:"kind,omitempty"`
+	Checks     []HealthCheckResult `json:"checks"`
+	System     HealthSystemInfo    `json:"system"`
+	CheckedAt  time.Time           `json:"checked_at"`
+	DurationMs float64             `json:"duration_ms"`
+}
+
+// HealthRegistry holds the health checks of a service and runs them
+// concurrently for health, readiness and liveness probes.
+type HealthRegistry struct {
+	mu             sync.RWMutex
+	checks         []HealthCheck
+	defaultTimeout time.Duration
+}
+
+// Backoff describes an exponential backoff with jitter. The delay before
+// retry n (starting at 1) is Initial × Multiplier^(n-1), capped at Max, then
+// randomized by ±Jitter (a fraction between 0 and 1).
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Jitter     float64
+}
+
+// WebhookConfig configures a [WebhookDispatcher].
+type WebhookConfig struct {
+	// Secret is the HMAC-SHA256 key signing every delivery (unsigned when empty).
+	Secret string
+
+	// OutboxDir is the directory persisting undelivered and dead-lettered
+	// events across restarts (memory only when empty).
+	OutboxDir string
+
+	// MaxAttempts is the number of delivery attempts before an event is
+	// dead-lettered (5 when zero).
+	MaxAttempts int
+
+	// Backoff spaces the retries of an event.
+	Backoff Backoff
+
+	// Timeout bounds a single delivery attempt (10 seconds when zero).
+	Timeout time.Duration
+
+	// Client sends the deliveries (a client with Timeout when nil).
+	Client *http.Client
+}
+
+// WebhookEvent is a webhook delivery tracked by a [WebhookDispatcher].
+type WebhookEvent struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	URL         string          `json:"url"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"`
+	CreatedAt   time.Time       `json:"created_at"`
+	NextAttempt time.Time       `json:"next_attempt"`
+	LastStatus  int             `json:"last_status,omitempty"`
+	LastError   string          `json:"last_error,omitempty"`
+}
+
+// WebhookDelivery reports the outcome of one delivery attempt.
+type WebhookDelivery struct {
+	EventID      string    `json:"event_id"`
+	Type         string    `json:"type"`
+	URL          string    `json:"url"`
+	Attempt      int       `json:"attempt"`
+	StatusCode   int       `json:"status_code,omitempty"`
+	DurationMs   float64   `json:"duration_ms"`
+	Delivered    bool      `json:"delivered"`
+	DeadLettered bool      `json:"dead_lettered"`
+	NextAttempt  time.Time `json:"next_attempt,omitzero"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// WebhookDispatcher delivers replify envelopes as signed webhooks, retrying
+// with backoff and dead-lettering events that keep failing.
+type WebhookDispatcher struct {
+	config   WebhookConfig
+	client   *http.Client
+	mu       sync.Mutex
+	pending  map[string]*WebhookEvent
+	dead     map[string]*WebhookEvent
+	inflight map[string]struct{}
+	now      func() time.Time
+}
+
 // BufferPool for efficient buffer reuse
 type BufferPool struct {
 	buffers chan []byte
@@ -132,6 +487,15 @@ type StreamConfig struct {
 	// to limit bandwidth usage during streaming
 	// useful for avoiding network congestion
 	ThrottleRate int64 `json:"throttle_rate"`
+
+	// Framed enables the self-describing frame format of the chunked
+	// strategy: each chunk is written (or read) as a header plus payload,
+	// and the stream is closed by a trailer frame.
+	Framed bool `json:"framed"`
+
+	// Manifest enables the SHA-256 integrity manifest of each run,
+	// attached to the wrapper meta when the run completes.
+	Manifest bool `json:"manifest"`
 }
 
 // StreamProgress tracks streaming progress
@@ -163,6 +527,12 @@ type StreamProgress struct {
 
 	// LastUpdate time of last progress update
 	LastUpdate time.Time `json:"last_update,omitempty"`
+
+	// Retries number of chunk read or write attempts retried so far
+	Retries int64 `json:"retries"`
+
+	// Archive progress of an archive source, nil for other sources
+	Archive *ArchiveProgress `json:"archive,omitempty"`
 }
 
 // StreamingStats contains streaming statistics
@@ -206,6 +576,15 @@ type StreamingStats struct {
 
 	// List of errors encountered during streaming
 	Errors []error `json:"-"`
+
+	// Number of chunks sent or received as references to chunks the receiver holds
+	DedupChunks int64 `json:"dedup_chunks"`
+
+	// Bytes not transferred thanks to deduplication
+	DedupBytes int64 `json:"dedup_bytes"`
+
+	// Statistics of the fan-out destinations, by name
+	Destinations map[string]DestinationStats `json:"destinations,omitempty"`
 }
 
 // StreamingCallback function type for async notifications
@@ -219,25 +598,350 @@ type StreamingCallback func(progress *StreamProgress, err error)
 // encapsulated within the R type.
 type StreamingHook func(progress *StreamProgress, wrap *R)
 
+// CodecOptions holds the settings a [Codec] encodes and decodes with.
+type CodecOptions struct {
+	// Level is the compression level, in the codec's own scale; 0 selects the codec default
+	Level int `json:"level"`
+
+	// Dictionary is a preset dictionary, for codecs that support one
+	Dictionary []byte `json:"-"`
+}
+
+// Codec is a compression algorithm registered by name with [RegisterCodec].
+// Its name is the CompressionType of a [StreamConfig] and the HTTP
+// Content-Encoding token negotiated by [wrapper.ServeHTTP].
+type Codec interface {
+	// Name returns the registration name, e.g. "gzip"
+	Name() string
+
+	// NewWriter returns a writer compressing into w
+	NewWriter(w io.Writer, options CodecOptions) (io.WriteCloser, error)
+
+	// NewReader returns a reader decompressing r
+	NewReader(r io.Reader, options CodecOptions) (io.ReadCloser, error)
+}
+
+// ChunkOp names the stage at which a chunk failed.
+type ChunkOp string
+
+// ChunkRetryPolicy configures how failed chunk reads and writes are retried.
+//
+// A failed write is retried with the chunk retained in memory, resuming
+// after the bytes already accepted by the writer. A failed read is retried
+// when the source is an io.Seeker, after seeking back over any partial read.
+type ChunkRetryPolicy struct {
+	// MaxAttempts is the number of attempts per chunk, the first included (default: 3)
+	MaxAttempts int `json:"max_attempts"`
+
+	// Backoff computes the delay before each retry
+	Backoff Backoff `json:"backoff"`
+
+	// Retryable reports whether an error is worth retrying; nil retries
+	// every error except context cancellation
+	Retryable func(err error) bool `json:"-"`
+}
+
+// ChunkError is the structured error recorded for a chunk that failed.
+// It is reported in [StreamingWrapper.Errors] once the chunk has failed for
+// good and passed to the [StreamingCallback] before each retry.
+type ChunkError struct {
+	// Sequence number of the chunk
+	Sequence int64 `json:"sequence"`
+
+	// Op is the stage that failed
+	Op ChunkOp `json:"op"`
+
+	// Attempt is the number of the failed attempt, starting at 1
+	Attempt int `json:"attempt"`
+
+	// Retrying reports whether another attempt is scheduled
+	Retrying bool `json:"retrying"`
+
+	// Err is the underlying error
+	Err error `json:"-"`
+}
+
+// ArchiveFormat names the format of an archive source; see
+// [wrapper.WithArchiveStreaming].
+type ArchiveFormat string
+
+// ArchiveEntry is an entry of an archive source. Its content is opened when
+// the archive reaches it, and closed right after.
+type ArchiveEntry struct {
+	// Name is the slash-separated path of the entry in the archive
+	Name string `json:"name"`
+
+	// Size is the content size in bytes, -1 if unknown; tar entries need it
+	Size int64 `json:"size"`
+
+	// Mode is the file mode of the entry (default: 0644)
+	Mode fs.FileMode `json:"mode"`
+
+	// ModTime is the modification time of the entry (default: the time the archive is built)
+	ModTime time.Time `json:"mod_time,omitempty"`
+
+	// Open opens the content of the entry
+	Open func() (io.ReadCloser, error) `json:"-"`
+}
+
+// ArchiveProgress reports the progress of an archive source, measured on
+// the content of its entries.
+type ArchiveProgress struct {
+	// Entries is the number of entries of the archive
+	Entries int `json:"entries"`
+
+	// EntriesDone is the number of entries fully written
+	EntriesDone int `json:"entries_done"`
+
+	// CurrentEntry is the name of the entry being written
+	CurrentEntry string `json:"current_entry,omitempty"`
+
+	// Bytes is the content read from the entries so far
+	Bytes int64 `json:"bytes"`
+
+	// TotalBytes is the content size of all entries, -1 if an entry has an unknown size
+	TotalBytes int64 `json:"total_bytes"`
+}
+
+// ChunkIndex is the set of chunks, by the hex-encoded SHA-256 digest of
+// their content, that the receiver of a stream holds; see
+// [StreamingWrapper.WithChunkIndex]. Implementations must be safe for
+// concurrent use.
+type ChunkIndex interface {
+	// Has reports whether the receiver holds the chunk
+	Has(digest string) bool
+
+	// Add records that the receiver holds the chunk, once it has been sent
+	Add(digest string)
+}
+
+// ChunkStore holds chunk contents by the hex-encoded SHA-256 digest of
+// their content, so that a receiver can rebuild deduplicated chunks; see
+// [StreamingWrapper.WithChunkStore]. Implementations must be safe for
+// concurrent use.
+type ChunkStore interface {
+	// Get returns the content of a chunk; the caller does not modify it
+	Get(digest string) ([]byte, error)
+
+	// Put stores the content of a chunk; data is only valid during the call
+	Put(digest string, data []byte) error
+}
+
+// MemoryChunkStore is an in-memory [ChunkStore], which is also the
+// [ChunkIndex] of its chunks. It is safe for concurrent use.
+type MemoryChunkStore struct {
+	mu     sync.RWMutex
+	chunks map[string][]byte
+	bytes  int64
+}
+
+// CDCChunker splits a stream into content-defined chunks with the FastCDC
+// algorithm: a boundary is cut where a rolling gear hash of the data
+// matches a mask, so boundaries move with the content rather than with
+// offsets, and an insertion only changes the chunks around it. Chunks are
+// between a quarter and four times the average size.
+type CDCChunker struct {
+	r     io.Reader
+	min   int    // Smallest chunk, but for the last.
+	avg   int    // Normal chunk size, a power of two.
+	max   int    // Largest chunk.
+	maskS uint64 // Harder mask, used below the normal size.
+	maskL uint64 // Easier mask, used above the normal size.
+	buf   []byte // Data read ahead, of capacity max.
+	start int    // Start of the next chunk in buf.
+	end   int    // End of the data in buf.
+	eof   bool   // Set once r is drained.
+	err   error  // Read error, returned once buf is drained.
+}
+
+// DestinationPolicy selects how the failure of a [StreamDestination] is handled.
+type DestinationPolicy string
+
+// StreamDestination is a named destination of a fan-out stream; see
+// [StreamingWrapper.WithDestination].
+type StreamDestination struct {
+	// Name identifies the destination in errors and statistics; it must be unique
+	Name string `json:"name"`
+
+	// Writer receives the streamed data; it is not closed by the stream
+	Writer io.Writer `json:"-"`
+
+	// ThrottleRate caps the write rate of the destination in bytes per second; 0 means unlimited
+	ThrottleRate int64 `json:"throttle_rate"`
+
+	// Policy selects how a failure of the destination is handled (default: DestinationFailAll)
+	Policy DestinationPolicy `json:"policy"`
+}
+
+// DestinationStats holds the statistics of one [StreamDestination].
+type DestinationStats struct {
+	// Name of the destination
+	Name string `json:"name"`
+
+	// Policy of the destination
+	Policy DestinationPolicy `json:"policy"`
+
+	// Bytes written to the destination
+	BytesWritten int64 `json:"bytes_written"`
+
+	// Number of chunks written to the destination
+	Writes int64 `json:"writes"`
+
+	// Time spent writing to the destination
+	WriteTime time.Duration `json:"write_time,omitempty"`
+
+	// Time spent waiting for the throttle rate of the destination
+	ThrottleTime time.Duration `json:"throttle_time,omitempty"`
+
+	// Dropped reports whether the destination was dropped after a failure
+	Dropped bool `json:"dropped"`
+
+	// Err is the failure of the destination, if any
+	Err error `json:"-"`
+}
+
+// DestinationError is the error recorded for a failed [StreamDestination].
+type DestinationError struct {
+	// Name of the destination
+	Name string `json:"name"`
+
+	// Policy of the destination
+	Policy DestinationPolicy `json:"policy"`
+
+	// Err is the underlying error
+	Err error `json:"-"`
+}
+
+// StreamTrailer is the manifest carried by the trailer frame that closes a
+// framed stream. The receiver checks it against the frames it parsed, which
+// detects truncated streams and lost, duplicated or reordered frames.
+type StreamTrailer struct {
+	// Frames is the number of data frames of the stream.
+	Frames int64 `json:"frames"`
+
+	// PayloadBytes is the total size of the data frame payloads.
+	PayloadBytes int64 `json:"payload_bytes"`
+
+	// Checksum is the CRC-32 (IEEE) of the big-endian frame checksums, in sequence order.
+	Checksum uint32 `json:"checksum"`
+}
+
+// ByteRange is a satisfiable range of a resource, as requested by a Range
+// header; see [ParseRange].
+type ByteRange struct {
+	// Start is the offset of the first byte of the range.
+	Start int64 `json:"start"`
+
+	// Length is the number of bytes of the range.
+	Length int64 `json:"length"`
+}
+
+// ManifestChunk records one chunk of a [StreamManifest].
+type ManifestChunk struct {
+	// Sequence is the chunk sequence number.
+	Sequence int64 `json:"sequence"`
+
+	// Offset is the position of the chunk in the data, in bytes.
+	Offset int64 `json:"offset"`
+
+	// Size is the chunk size in bytes.
+	Size int64 `json:"size"`
+
+	// SHA256 is the hex-encoded SHA-256 digest of the chunk.
+	SHA256 string `json:"sha256"`
+}
+
+// StreamManifest is the integrity manifest of a streaming run: the SHA-256
+// digest of every chunk and the Merkle root over them. When sending it
+// describes the source data, when receiving the data written.
+type StreamManifest struct {
+	// Algorithm names the digest algorithm, "sha256".
+	Algorithm string `json:"algorithm"`
+
+	// TotalBytes is the total size of the chunks.
+	TotalBytes int64 `json:"total_bytes"`
+
+	// Chunks lists the chunks in sequence order.
+	Chunks []ManifestChunk `json:"chunks"`
+
+	// MerkleRoot is the hex-encoded Merkle root over the chunk digests.
+	MerkleRoot string `json:"merkle_root"`
+
+	// CreatedAt is when the manifest was built.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ManifestReport is the outcome of [VerifyManifest].
+type ManifestReport struct {
+	// Valid reports whether the data matches the manifest exactly.
+	Valid bool `json:"valid"`
+
+	// Corrupt lists the sequence numbers of the chunks whose digest differs.
+	Corrupt []int64 `json:"corrupt,omitempty"`
+
+	// Missing lists the sequence numbers of the chunks absent from truncated data.
+	Missing []int64 `json:"missing,omitempty"`
+
+	// ExtraBytes counts the bytes following the last chunk of the manifest.
+	ExtraBytes int64 `json:"extra_bytes,omitempty"`
+
+	// MerkleRoot is the hex-encoded Merkle root computed over the data.
+	MerkleRoot string `json:"merkle_root"`
+}
+
+// KeyProvider supplies the AES-256 keys, 32 bytes each, of sealed streams;
+// see [StreamingWrapper.WithEncryption]. Keys are named by an identifier
+// recorded in the stream, so that keys can be rotated while older streams
+// remain readable.
+type KeyProvider interface {
+	// CurrentKey returns the key new streams are sealed with, and its identifier.
+	CurrentKey() (id string, key []byte, err error)
+
+	// Key returns the key with the given identifier, to open a sealed stream.
+	Key(id string) ([]byte, error)
+}
+
+// StreamTransform is a user transformation applied to every chunk by the
+// worker pool of the chunked strategy. It receives the uncompressed chunk
+// data and may replace chunk.Data; returning an error fails the chunk.
+type StreamTransform func(chunk *StreamChunk) error
+
 // StreamingWrapper wraps response with streaming capabilities
 // BufferPool represents a pool of reusable byte buffers to optimize memory usage during streaming.
 type StreamingWrapper struct {
 	*wrapper
-	config         *StreamConfig      // Streaming configuration
-	reader         io.Reader          // Source of data to be streamed
-	writer         io.Writer          // Destination for streamed data
-	progress       *StreamProgress    // Progress tracking information
-	stats          *StreamingStats    // Streaming statistics and metrics
-	callback       StreamingCallback  // Callback for progress updates
-	hook           StreamingHook      // Callback with R wrapper for progress updates
-	ctx            context.Context    // Context for managing streaming lifecycle
-	cancel         context.CancelFunc // Function to cancel streaming
-	currentChunk   int64              // Current chunk being processed
-	errors         []error            // Errors encountered during streaming
-	mu             sync.RWMutex       // Mutex for synchronizing access to shared fields
-	isStreaming    bool               // Indicates if streaming is in progress
-	compressionBuf []byte             // Compression buffer for data compression
-	bufferPool     *BufferPool        // Pool of reusable buffers for efficient memory usage
+	config         *StreamConfig       // Streaming configuration
+	reader         io.Reader           // Source of data to be streamed
+	writer         io.Writer           // Destination for streamed data
+	progress       *StreamProgress     // Progress tracking information
+	stats          *StreamingStats     // Streaming statistics and metrics
+	callback       StreamingCallback   // Callback for progress updates
+	hook           StreamingHook       // Callback with R wrapper for progress updates
+	ctx            context.Context     // Context for managing streaming lifecycle
+	cancel         context.CancelFunc  // Function to cancel streaming
+	currentChunk   int64               // Current chunk being processed
+	errors         []error             // Errors encountered during streaming
+	mu             sync.RWMutex        // Mutex for synchronizing access to shared fields
+	isStreaming    bool                // Indicates if streaming is in progress
+	compressionBuf []byte              // Compression buffer for data compression
+	bufferPool     *BufferPool         // Pool of reusable buffers for efficient memory usage
+	resumeCh       chan struct{}       // Closed by Resume; non-nil while the stream is paused
+	checkpoint     *streamCheckpointer // Checkpoint of the acknowledged chunks, if enabled
+	transforms     []StreamTransform   // User transformations run by the chunk pipeline workers
+	retry          *ChunkRetryPolicy   // Retry policy of failed chunk reads and writes, if any
+	framer         *streamFramer       // Frame state of the current run, when framing is enabled
+	manifest       *manifestBuilder    // Chunk digests of the current run, when the manifest is enabled
+	keys           KeyProvider         // Keys of the AES-GCM sealing, when encryption is enabled
+	archive        *archiveSource      // Archive source, when the stream reads an archive built on the fly
+	index          ChunkIndex          // Chunks held by the receiver, when deduplication is enabled
+	store          ChunkStore          // Store of received chunks, when deduplication is enabled
+	cdc            *CDCChunker         // Content-defined chunker of the current run, when sending with StrategyContentDefined
+	id             string              // Stream ID, set explicitly or by a StreamRegistry
+	registry       *StreamRegistry     // Registry tracking the stream, if any
+	limiter        *BandwidthLimiter   // Shared bandwidth limiter, if any
+	limiterWeight  int                 // Weight of the stream in its bandwidth limiter
+	destinations   []StreamDestination // Fan-out destinations, in registration order
+	fanout         *streamFanout       // Fan-out writer of the current run, when destinations are set
 }
 
 // StreamChunk represents a single chunk of data
@@ -266,6 +970,12 @@ type StreamChunk struct {
 
 	// Error if any occurred during chunk processing
 	Error error `json:"-"`
+
+	// Digest is the hex-encoded SHA-256 of the chunk content, set when deduplication is enabled
+	Digest string `json:"digest,omitempty"`
+
+	// Deduplicated indicates the chunk travels as a reference to a chunk the receiver holds
+	Deduplicated bool `json:"deduplicated"`
 }
 
 // StreamingMetadata extends wrapper metadata for streaming context
@@ -299,6 +1009,34 @@ type StreamingMetadata struct {
 	IsResumable bool `json:"is_resumable"`
 }
 
+// StreamCheckpoint records how far a sending stream has progressed, so an
+// interrupted transfer can restart from it instead of from the beginning.
+//
+// Chunks are acknowledged in sequence order once they have been written;
+// the checkpoint always describes a contiguous prefix of the source.
+type StreamCheckpoint struct {
+	// Sequence number of the last acknowledged chunk; meaningful only when Offset > 0
+	Sequence int64 `json:"sequence"`
+
+	// Offset in the source of the first byte not yet acknowledged
+	Offset int64 `json:"offset"`
+
+	// Checksum is the CRC-32 (IEEE) of the source bytes [0, Offset)
+	Checksum uint32 `json:"checksum"`
+
+	// Size of each chunk in bytes when the checkpoint was taken
+	ChunkSize int64 `json:"chunk_size"`
+
+	// Streaming strategy used
+	Strategy StreamingStrategy `json:"strategy"`
+
+	// Compression algorithm used
+	Compression CompressionType `json:"compression"`
+
+	// Timestamp of the last acknowledgement
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
 // Dump is the thread-safe result returned by [wrapper.Dump] and
 // [wrapper.DumpTo]. It owns the serialized response payload as a
 // seekable, re-readable stream and guarantees that the backing temporary
@@ -318,6 +1056,8 @@ type Dump struct {
 	syr      *sysx.Resource
 	once     sync.Once
 	closeErr error
+	etag     string     // Entity tag of the wrapper the Dump was taken from.
+	mu       sync.Mutex // Serializes range reads of in-memory content.
 
 	// filepath is non-empty when DumpTo wrote a permanent on-disk copy.
 	// That file is NOT removed on Close.
@@ -332,10 +1072,332 @@ type HeaderType string
 // It is used to specify the format of the data being sent or received, such as "application/json", "text/html", or "image/png".
 type MediaType string
 
+// RateLimitDecision is the outcome of a single [RateLimiter] admission check.
+// It carries everything needed to populate the IETF RateLimit-* response
+// headers and, when the request is rejected, the Retry-After hint.
+type RateLimitDecision struct {
+	// Allowed reports whether the request may proceed.
+	Allowed bool `json:"allowed"`
+
+	// Limit is the quota size of the current window.
+	Limit int `json:"limit"`
+
+	// Remaining is the number of quota units left after this decision.
+	Remaining int `json:"remaining"`
+
+	// Reset is the time until the quota is fully replenished.
+	Reset time.Duration `json:"reset"`
+
+	// RetryAfter is the minimum time the client should wait before retrying.
+	// It is zero when Allowed is true.
+	RetryAfter time.Duration `json:"retry_after"`
+}
+
+// RateLimiter decides whether a request identified by key may proceed.
+// Implementations must be safe for concurrent use.
+type RateLimiter interface {
+	Allow(key string) RateLimitDecision
+}
+
+// RateLimitKeyFunc derives the rate-limit bucket key from an incoming request,
+// e.g. the client IP address, an API key or a tenant identifier.
+type RateLimitKeyFunc func(r *http.Request) string
+
+// TokenBucketLimiter is a per-key token-bucket [RateLimiter]. Each key owns a
+// bucket holding at most `limit` tokens that refills continuously at
+// limit/period tokens per second; every admitted request consumes one token.
+type TokenBucketLimiter struct {
+	limit   int
+	period  time.Duration
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	calls   int
+}
+
+// BandwidthLimiter is a token-bucket bandwidth limiter shared by streams;
+// see [StreamingWrapper.WithBandwidthLimiter]. Limiters form a hierarchy,
+// e.g. a global limiter with a child per tenant: bytes are granted by the
+// limiter a stream is attached to, then by each of its ancestors. At every
+// level, waiting flows (streams, or child limiters at the upper levels) are
+// served in weighted fair order. It is safe for concurrent use.
+type BandwidthLimiter struct {
+	name    string
+	parent  *BandwidthLimiter
+	mu      sync.Mutex
+	rate    int64              // Bytes per second; 0 means unlimited.
+	burst   int64              // Bucket capacity in bytes; 0 means one second of rate.
+	weight  int                // Share of the limiter among the flows of its parent.
+	tokens  float64            // Bytes available; negative after granting more than the burst.
+	last    time.Time          // Time of the last refill.
+	virtual float64            // Virtual time: finish tag of the last granted request.
+	finish  map[any]float64    // Finish tag of the last request of each flow.
+	queue   []*bandwidthWaiter // Waiting requests.
+	seq     uint64             // Arrival counter, breaking ties between finish tags.
+	timer   *time.Timer        // Wakes the queue once enough tokens are available.
+	stats   BandwidthLimiterStats
+}
+
+// BandwidthLimiterStats holds the statistics of a [BandwidthLimiter].
+type BandwidthLimiterStats struct {
+	// Name of the limiter
+	Name string `json:"name"`
+
+	// Current rate in bytes per second; 0 means unlimited
+	Rate int64 `json:"rate"`
+
+	// Current burst in bytes
+	Burst int64 `json:"burst"`
+
+	// Weight of the limiter among the flows of its parent
+	Weight int `json:"weight"`
+
+	// Bytes granted
+	BytesGranted int64 `json:"bytes_granted"`
+
+	// Number of requests
+	Requests int64 `json:"requests"`
+
+	// Number of requests that had to wait
+	Waits int64 `json:"waits"`
+
+	// Total time spent waiting by requests
+	WaitTime time.Duration `json:"wait_time"`
+
+	// Number of requests waiting
+	Queued int `json:"queued"`
+
+	// Number of flows known to the limiter
+	Flows int `json:"flows"`
+}
+
+// ProgressBar draws the progress of a stream, with its rate and ETA, to a
+// terminal or a log file; see [ProgressBar.Callback]. It is safe for
+// concurrent use.
+type ProgressBar struct {
+	mu       sync.Mutex
+	out      io.Writer
+	label    string
+	tty      bool          // Whether out is a terminal, redrawn in place.
+	interval time.Duration // Shortest time between two draws.
+	last     time.Time     // Time of the last draw.
+	drawn    bool          // Whether a line is pending on a terminal.
+	finished bool          // Whether Finish ended the bar; later progress is ignored.
+}
+
+// StreamRegistry tracks the running streams by ID, and serves their
+// progress to browsers as server-sent events; see [StreamRegistry.ServeHTTP].
+// It is safe for concurrent use.
+type StreamRegistry struct {
+	mu       sync.RWMutex
+	streams  map[string]*registeredStream
+	interval time.Duration // Time between two progress events.
+}
+
+// SlidingWindowLimiter is a per-key sliding-window-counter [RateLimiter]. It
+// admits at most `limit` requests within any rolling `window`, approximating
+// the rolling count by weighting the previous fixed window by its overlap.
+type SlidingWindowLimiter struct {
+	limit   int
+	window  time.Duration
+	mu      sync.Mutex
+	windows map[string]*slidingWindow
+	calls   int
+}
+
 // ///////////////////////////
 // Section unexported types
 // ///////////////////////////
 
+// envelopeRegistry holds the registered envelope migrations, detectors and
+// API-version bindings. It is safe for concurrent use.
+type envelopeRegistry struct {
+	mu         sync.RWMutex
+	migrations []EnvelopeMigration
+	detectors  []EnvelopeDetector
+	bindings   []envelopeBinding
+}
+
+// envelopeBinding maps API versions matching pattern to an envelope version.
+type envelopeBinding struct {
+	pattern string // Wildcard pattern matched against meta.apiVersion.
+	version string // Envelope layout version rendered for matching API versions.
+}
+
+// envelopeStep is one edge of an envelope migration path.
+type envelopeStep struct {
+	from      string            // Layout version the transform reads.
+	to        string            // Layout version the transform produces.
+	transform EnvelopeTransform // Transform converting from into to.
+}
+
+// bodyRedaction records the fields redacted by a [ResponsePolicy], so that
+// the elements of a body set with [wrapper.WithBodySeq], which only exist
+// while the envelope is encoded, are redacted as they are produced.
+type bodyRedaction struct {
+	fields      map[string]struct{} // Lowercased names of the redacted fields.
+	replacement any                 // Value written in place of a redacted field.
+}
+
+// streamCheckpointer tracks the acknowledged prefix of a sending stream and
+// persists it as a [StreamCheckpoint].
+type streamCheckpointer struct {
+	mu      sync.Mutex       // Guards the fields below.
+	path    string           // File the checkpoint is persisted to; empty keeps it in memory.
+	state   StreamCheckpoint // Acknowledged prefix of the source.
+	pending map[int64][]byte // Raw data of chunks written ahead of the acknowledged prefix.
+	halted  bool             // A chunk failed: the prefix can no longer grow in this run.
+}
+
+// streamFramer encodes or decodes the frames of one framed run and
+// accumulates the [StreamTrailer] of the frames seen so far. Frames are
+// encoded by the pipeline writer and decoded by the pipeline reader, both in
+// sequence order, so it needs no locking.
+type streamFramer struct {
+	frames  int64          // Data frames written or parsed.
+	payload int64          // Payload bytes written or parsed.
+	digest  hash.Hash32    // CRC-32 of the frame checksums.
+	trailer *StreamTrailer // Trailer parsed from the stream, once reached.
+	aead    cipher.AEAD    // Cipher of a sealed stream; nil when the stream is not sealed.
+}
+
+// streamFanout writes each chunk of a run to its destinations concurrently.
+type streamFanout struct {
+	mu      sync.Mutex              // Serializes writes, which the buffered strategy issues from several goroutines.
+	sw      *StreamingWrapper       // Stream whose statistics are updated.
+	ctx     context.Context         // Context of the run, bounding throttle waits.
+	cancel  context.CancelCauseFunc // Stops the run when a fail-all destination fails.
+	writer  io.Writer               // Writer set with WithWriter, restored after the run.
+	targets []*fanoutTarget         // Destinations in registration order.
+	err     error                   // Failure of a fail-all destination, once one failed.
+}
+
+// archiveSource is the reader of an archive written on the fly, by a
+// goroutine started on the first read, from a list of entries.
+type archiveSource struct {
+	format  ArchiveFormat  // Format of the archive.
+	entries []ArchiveEntry // Entries, in archive order.
+	total   int64          // Content size of all entries, -1 if unknown.
+	once    sync.Once      // Starts the writing goroutine.
+	pr      *io.PipeReader // Read side of the archive.
+	pw      *io.PipeWriter // Write side of the archive, owned by the goroutine.
+	bytes   atomic.Int64   // Content bytes read from the entries.
+	done    atomic.Int64   // Entries fully written.
+	current atomic.Value   // Name of the entry being written.
+}
+
+// registeredStream is a stream tracked by a [StreamRegistry].
+type registeredStream struct {
+	sw   *StreamingWrapper
+	done chan struct{} // Closed once the stream completes.
+}
+
+// archiveCounter counts the content bytes copied into an archive.
+type archiveCounter struct {
+	n *atomic.Int64
+}
+
+// releaseReader is a reader whose Close runs a release function.
+type releaseReader struct {
+	io.Reader
+	release func()
+}
+
+// chunkIndexSet is an in-memory [ChunkIndex].
+type chunkIndexSet struct {
+	mu      sync.RWMutex
+	digests map[string]struct{}
+}
+
+// bandwidthWaiter is a request waiting in a [BandwidthLimiter].
+type bandwidthWaiter struct {
+	n       int64         // Bytes requested.
+	finish  float64       // Virtual finish tag; requests are granted in increasing order.
+	seq     uint64        // Arrival order.
+	ready   chan struct{} // Closed once granted.
+	granted bool          // Set once granted.
+}
+
+// fanoutTarget is the run state of one [StreamDestination].
+type fanoutTarget struct {
+	dest  StreamDestination // The destination.
+	start time.Time         // Start of the run, the origin of the throttle schedule.
+	stats DestinationStats  // Statistics of the destination.
+}
+
+// staticKeyProvider is a [KeyProvider] holding a single key.
+type staticKeyProvider struct {
+	id  string
+	key []byte
+}
+
+// manifestBuilder collects the chunk digests of a run, in any order, and
+// builds its [StreamManifest].
+type manifestBuilder struct {
+	mu     sync.Mutex              // Guards the fields below.
+	first  int64                   // Sequence number of the first chunk of the run.
+	base   int64                   // Offset of the first chunk of the run.
+	chunks map[int64]ManifestChunk // Digested chunks by sequence number; offsets are set by build.
+	result *StreamManifest         // Manifest of the completed run.
+}
+
+// codecRegistry holds the registered codecs in registration order.
+// It is safe for concurrent use.
+type codecRegistry struct {
+	mu      sync.RWMutex
+	entries []codecEntry
+}
+
+// codecEntry is a registered codec with its default options.
+type codecEntry struct {
+	codec   Codec        // Codec implementation.
+	options CodecOptions // Options used when encoding and decoding.
+}
+
+// gzipCodec is the built-in "gzip" codec (RFC 1952).
+type gzipCodec struct{}
+
+// zlibCodec is the built-in "deflate" codec: the zlib format (RFC 1950),
+// which is what the HTTP "deflate" content coding denotes.
+type zlibCodec struct{}
+
+// flateCodec is the built-in "flate" codec: a raw DEFLATE stream (RFC 1951).
+type flateCodec struct{}
+
+// pipelineJob is a chunk travelling through the chunk pipeline.
+type pipelineJob struct {
+	chunk *StreamChunk  // Chunk processed by a worker.
+	raw   []byte        // Data as read from the source, before any processing.
+	buf   []byte        // Pooled buffer backing raw, released once the chunk is written.
+	done  chan struct{} // Closed by the worker when the chunk is processed.
+}
+
+// bandwidthMeter measures the throughput of a stream over fixed windows to
+// find its peak bandwidth.
+type bandwidthMeter struct {
+	window time.Duration // Length of a measurement window.
+	start  time.Time     // Start of the current window.
+	bytes  int64         // Bytes written in the current window.
+	peak   int64         // Highest bandwidth of a completed window, in bytes/second.
+}
+
+// diffOptions holds the settings applied by [DiffOption] values.
+type diffOptions struct {
+	ignore []string // JSON Pointer prefixes or wildcard patterns excluded from the diff.
+}
+
+// tokenBucket is the per-key state of a [TokenBucketLimiter].
+type tokenBucket struct {
+	tokens float64   // Tokens currently available.
+	last   time.Time // Time of the last refill.
+}
+
+// slidingWindow is the per-key state of a [SlidingWindowLimiter].
+type slidingWindow struct {
+	start    time.Time // Start of the current fixed window.
+	current  int       // Requests admitted in the current fixed window.
+	previous int       // Requests admitted in the previous fixed window.
+}
+
 // pagination represents pagination details for paginated API responses.
 type pagination struct {
 	page       int  // Current page number.
@@ -380,6 +1442,24 @@ type wrapper struct {
 	cachedWrap map[string]any // Cached response data for performance optimization.
 	cacheHash  string         // Hash of the cached response, used for cache validation.
 	cacheMutex sync.RWMutex   // Mutex for synchronizing access to the cached response data.
+
+	httpHeaders http.Header // Transport-level HTTP headers emitted by WriteHTTP (not part of the JSON body).
+
+	errorChain      bool // When true, the error chain is serialized into the `errors` section.
+	errorChainStack bool // When true, the serialized error chain includes stack frames.
+
+	envelopeVersion string // Envelope layout version to render (empty means resolve from meta.apiVersion).
+
+	canonical bool // When true, JSON output and hashes use the RFC 8785 canonical form.
+
+	policies *PolicySet // Response policies applied when written over HTTP (nil means the package default).
+
+	codec           string // Codec used by CompressSafe (empty means gzip).
+	contentEncoding bool   // When true, ServeHTTP negotiates a Content-Encoding with the client.
+	encodingMinSize int    // Smallest body, in bytes, encoded by the negotiated Content-Encoding.
+
+	bodySeq    iter.Seq[any]  // Body produced element by element, encoded by EncodeTo (nil means data is the body).
+	bodyRedact *bodyRedaction // Redaction applied by EncodeTo to each element of bodySeq (nil means none).
 }
 
 // stack represents a stack of program counters. It is a slice of `uintptr`
@@ -441,6 +1521,13 @@ type underlyingMessage struct {
 	msg   string // The message describing the additional context for the error
 }
 
+// classified decorates an error with [ErrorClassification] metadata without
+// changing its message; it is created by [Classify].
+type classified struct {
+	cause error               // The classified error
+	class ErrorClassification // The classification metadata
+}
+
 type tools struct{}
 
 var Toolbox tools = tools{}
@@ -1,11 +1,11 @@
 package replify
 
 import (
-	"bytes"
-	"compress/gzip"
 	"encoding/base64"
 	"encoding/json"
 	"io"
+	"math"
+	"time"
 
 	"github.com/sivaosorg/replify/pkg/conv"
 	"github.com/sivaosorg/replify/pkg/encoding"
@@ -24,50 +24,38 @@ func calculateSize(data any) int {
 	return len(_bytes)
 }
 
-// compress compresses the given data using gzip and encodes it in base64.
+// compress compresses the given data using the named codec and encodes it in base64.
 // It first marshals the data using encoding.Marshal, then compresses the resulting byte slice
-// using gzip. The compressed data is then encoded in base64 and returned as a string.
+// with the registered codec. The compressed data is then encoded in base64 and returned as a string.
 // If any error occurs during marshaling or compression, it returns an empty string.
-func compress(data any) string {
+func compress(data any, codec string) string {
 	_bytes, err := encoding.MarshalJSON(data)
 	if err != nil {
 		return ""
 	}
-	var buf bytes.Buffer
-	gz := gzip.NewWriter(&buf)
-	_, err = gz.Write(_bytes)
+	packed, err := CompressBytes(codec, _bytes)
 	if err != nil {
 		return ""
 	}
-	err = gz.Close()
-	if err != nil {
-		return ""
-	}
-	return base64.StdEncoding.EncodeToString(buf.Bytes())
+	return base64.StdEncoding.EncodeToString(packed)
 }
 
-// decompress decompresses the given data using gzip and decodes it from base64.
+// decompress decompresses the given data using the named codec and decodes it from base64.
 // It first decodes the base64 encoded data using base64.StdEncoding.DecodeString,
-// then decompresses the resulting byte slice using gzip. The decompressed data is
+// then decompresses the resulting byte slice with the registered codec. The decompressed data is
 // then unmarshaled using encoding.Unmarshal and returned as an interface{}.
 // If any error occurs during decoding or decompression, it returns nil.
-func decompress(data string) any {
+func decompress(data string, codec string) any {
 	_bytes, err := base64.StdEncoding.DecodeString(data)
 	if err != nil {
 		return nil
 	}
-	gz, err := gzip.NewReader(bytes.NewReader(_bytes))
-	if err != nil {
-		return nil
-	}
-	defer gz.Close()
-	var buf bytes.Buffer
-	_, err = buf.ReadFrom(gz)
+	unpacked, err := DecompressBytes(codec, _bytes)
 	if err != nil {
 		return nil
 	}
 	var result any
-	if err := encoding.UnmarshalJSON(buf.Bytes(), &result); err != nil {
+	if err := encoding.UnmarshalJSON(unpacked, &result); err != nil {
 		return nil
 	}
 	return result
@@ -259,6 +247,17 @@ func dumpJSON(payload []byte) (*sysx.Resource, error) {
 		})
 }
 
+// dumpEncoded creates a seekable in-process [sysx.Resource] backed by a
+// temporary file, into which the envelope of w is encoded with
+// [wrapper.EncodeTo], so that a body set with [wrapper.WithBodySeq] is
+// never held in memory.
+func dumpEncoded(w *wrapper) (*sysx.Resource, error) {
+	return sysx.NewResource().
+		WithName("replify-dump-*.json").
+		WithContentType(sysx.MimeJSON).
+		FromTempFile(w.EncodeTo)
+}
+
 // dumpBodyStream serializes body into a seekable [sysx.Resource] backed by a
 // spill buffer and writes output to a plain-text (.txt) file because the body
 // can carry any Go value — not only valid JSON.
@@ -336,3 +335,18 @@ func dumpBodyStream(body any) (*sysx.Resource, error) {
 	}
 	return res, nil
 }
+
+// ceilSeconds converts a duration to whole seconds, rounding up so that a
+// client honoring the value never retries too early. Negative durations
+// yield 0.
+func ceilSeconds(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// secondsToDuration converts a fractional number of seconds to a [time.Duration].
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
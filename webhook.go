@@ -0,0 +1,477 @@
+package replify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sivaosorg/replify/pkg/randn"
+	"github.com/sivaosorg/replify/pkg/strutil"
+	"github.com/sivaosorg/replify/pkg/sysx"
+)
+
+// Delay returns the wait before the given retry.
+//
+// Parameters:
+//   - `attempt`: The retry number, starting at 1.
+//
+// Returns:
+//   - The randomized delay, never negative.
+//
+// Example:
+//
+//	b := replify.NewBackoff()
+//	b.Delay(1) // ~500ms
+//	b.Delay(4) // ~4s
+func (b Backoff) Delay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	multiplier := b.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	d := float64(b.Initial) * math.Pow(multiplier, float64(attempt-1))
+	if b.Max > 0 && d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+	if jitter := min(max(b.Jitter, 0), 1); jitter > 0 {
+		d += d * jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(max(d, 0))
+}
+
+// Enqueue records a webhook event carrying the envelope and persists it in
+// the outbox. The event is sent by the next [WebhookDispatcher.Flush].
+//
+// Parameters:
+//   - `url`: The receiver endpoint.
+//   - `eventType`: The event type, sent in the Webhook-Event header.
+//   - `envelope`: The replify envelope used as payload.
+//
+// Returns:
+//   - The recorded [WebhookEvent].
+//   - An error if the envelope is unavailable or the event cannot be persisted.
+func (d *WebhookDispatcher) Enqueue(url, eventType string, envelope *wrapper) (WebhookEvent, error) {
+	if !envelope.Available() {
+		return WebhookEvent{}, NewError("Enqueue: envelope is not available")
+	}
+	if strutil.IsEmpty(url) {
+		return WebhookEvent{}, NewError("Enqueue: url is required")
+	}
+	now := d.now()
+	e := &WebhookEvent{
+		ID:          randn.NewXID().String(),
+		Type:        eventType,
+		URL:         url,
+		Payload:     json.RawMessage(envelope.JSON()),
+		CreatedAt:   now,
+		NextAttempt: now,
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.persist(webhookPendingDir, e); err != nil {
+		return WebhookEvent{}, err
+	}
+	d.pending[e.ID] = e
+	return *e, nil
+}
+
+// Deliver enqueues the envelope and attempts its delivery immediately.
+// A failed delivery stays in the outbox and is retried by later flushes.
+//
+// Parameters:
+//   - `ctx`: The context of the attempt.
+//   - `url`: The receiver endpoint.
+//   - `eventType`: The event type.
+//   - `envelope`: The replify envelope used as payload.
+//
+// Returns:
+//   - The delivery report (see [WebhookDispatcher.Flush]).
+//
+// Example:
+//
+//	report := hooks.Deliver(ctx, "https://example.com/hooks", "order.created", replify.WrapCreated("created", order))
+//	if report.IsError() {
+//	    log.Println(report.Message())
+//	}
+func (d *WebhookDispatcher) Deliver(ctx context.Context, url, eventType string, envelope *wrapper) *wrapper {
+	e, err := d.Enqueue(url, eventType, envelope)
+	if err != nil {
+		return WrapInternalServerError("Webhook could not be enqueued", nil).WithErrorAck(err)
+	}
+	return d.attempt(ctx, e.ID)
+}
+
+// Flush attempts the delivery of every pending event that is due, oldest
+// first, and reports each attempt as a [wrapper] whose data is a
+// [WebhookDelivery]:
+//
+//   - 200 OK when the receiver answered 2xx;
+//   - 202 Accepted when the attempt failed and a retry is scheduled;
+//   - 502 Bad Gateway when the event was dead-lettered, either because it
+//     reached MaxAttempts or because the receiver rejected it permanently
+//     (a 4xx status other than 408 and 429);
+//   - 409 Conflict when a concurrent delivery took the event meanwhile.
+//
+// Events in flight in a concurrent [WebhookDispatcher.Deliver] or flush
+// are skipped, so that an event is never delivered twice at once.
+//
+// Parameters:
+//   - `ctx`: The context of the attempts.
+//
+// Returns:
+//   - One report per attempted event.
+func (d *WebhookDispatcher) Flush(ctx context.Context) []*wrapper {
+	d.mu.Lock()
+	now := d.now()
+	due := make([]*WebhookEvent, 0, len(d.pending))
+	for id, e := range d.pending {
+		if _, busy := d.inflight[id]; !busy && !e.NextAttempt.After(now) {
+			due = append(due, e)
+		}
+	}
+	d.mu.Unlock()
+	sort.Slice(due, func(i, j int) bool { return due[i].CreatedAt.Before(due[j].CreatedAt) })
+
+	reports := make([]*wrapper, 0, len(due))
+	for _, e := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		reports = append(reports, d.attempt(ctx, e.ID))
+	}
+	return reports
+}
+
+// Run flushes the outbox every interval until ctx is cancelled. Reports are
+// passed to onReport when it is not nil.
+//
+// Parameters:
+//   - `ctx`: Stops the loop when cancelled.
+//   - `interval`: The flush period; defaults to one second if not positive.
+//   - `onReport`: Optional callback receiving every delivery report.
+func (d *WebhookDispatcher) Run(ctx context.Context, interval time.Duration, onReport func(*wrapper)) {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, report := range d.Flush(ctx) {
+			if onReport != nil {
+				onReport(report)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Pending returns the events awaiting delivery, oldest first.
+func (d *WebhookDispatcher) Pending() []WebhookEvent {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return sortedEvents(d.pending)
+}
+
+// DeadLetters returns the dead-lettered events, oldest first.
+func (d *WebhookDispatcher) DeadLetters() []WebhookEvent {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return sortedEvents(d.dead)
+}
+
+// Requeue moves a dead-lettered event back to the outbox with a fresh
+// attempt budget.
+//
+// Parameters:
+//   - `id`: The event identifier.
+//
+// Returns:
+//   - An error if the event is not dead-lettered or cannot be persisted.
+func (d *WebhookDispatcher) Requeue(id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.dead[id]
+	if !ok {
+		return NewErrorf("Requeue: no dead-lettered webhook event %q", id)
+	}
+	e.Attempts = 0
+	e.NextAttempt = d.now()
+	if err := d.persist(webhookPendingDir, e); err != nil {
+		return err
+	}
+	d.unpersist(webhookDeadDir, id)
+	delete(d.dead, id)
+	d.pending[id] = e
+	return nil
+}
+
+// SignWebhook computes the Webhook-Signature header value of a payload:
+// "t=<unix>,v1=<hex HMAC-SHA256 of "<unix>.<payload>">".
+//
+// Parameters:
+//   - `secret`: The shared signing key.
+//   - `timestamp`: The signing time.
+//   - `payload`: The request body.
+//
+// Returns:
+//   - The signature header value.
+func SignWebhook(secret string, timestamp time.Time, payload []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + t + ",v1=" + webhookMAC(secret, t, payload)
+}
+
+// VerifyWebhookSignature checks a Webhook-Signature header on the receiving
+// side, rejecting signatures older than the tolerance to prevent replays.
+//
+// Parameters:
+//   - `secret`: The shared signing key.
+//   - `header`: The Webhook-Signature header value.
+//   - `payload`: The raw request body.
+//   - `tolerance`: The maximum signature age; zero disables the check.
+//
+// Returns:
+//   - An error if the header is malformed, expired or does not match.
+//
+// Example:
+//
+//	body, _ := io.ReadAll(r.Body)
+//	err := replify.VerifyWebhookSignature(secret, r.Header.Get("Webhook-Signature"), body, 5*time.Minute)
+func VerifyWebhookSignature(secret, header string, payload []byte, tolerance time.Duration) error {
+	var t, sig string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			t = value
+		case "v1":
+			sig = value
+		}
+	}
+	unix, err := strconv.ParseInt(t, 10, 64)
+	if err != nil || strutil.IsEmpty(sig) {
+		return NewError("webhook signature is malformed")
+	}
+	if tolerance > 0 && time.Since(time.Unix(unix, 0)).Abs() > tolerance {
+		return NewError("webhook signature is expired")
+	}
+	if !hmac.Equal([]byte(sig), []byte(webhookMAC(secret, t, payload))) {
+		return NewError("webhook signature does not match")
+	}
+	return nil
+}
+
+// attempt sends one pending event and records the outcome. The event is
+// marked in flight meanwhile, so that concurrent flushes never deliver it
+// twice.
+func (d *WebhookDispatcher) attempt(ctx context.Context, id string) *wrapper {
+	d.mu.Lock()
+	e, ok := d.pending[id]
+	if !ok {
+		d.mu.Unlock()
+		return WrapNotFound("Webhook event is not pending", nil).WithDebuggingKV("event_id", id)
+	}
+	if _, busy := d.inflight[id]; busy {
+		d.mu.Unlock()
+		return WrapConflict("Webhook event is being delivered", nil).WithDebuggingKV("event_id", id)
+	}
+	d.inflight[id] = struct{}{}
+	e.Attempts++
+	snapshot := *e
+	d.mu.Unlock()
+
+	start := time.Now()
+	status, retryAfter, err := d.send(ctx, &snapshot)
+	delivery := WebhookDelivery{
+		EventID:    snapshot.ID,
+		Type:       snapshot.Type,
+		URL:        snapshot.URL,
+		Attempt:    snapshot.Attempts,
+		StatusCode: status,
+		DurationMs: durationMs(time.Since(start)),
+		Delivered:  err == nil,
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.inflight, id)
+	e.LastStatus = status
+	if err == nil {
+		e.LastError = ""
+		delete(d.pending, id)
+		d.unpersist(webhookPendingDir, id)
+		return WrapOk("Webhook delivered", delivery)
+	}
+	e.LastError = err.Error()
+	delivery.Error = e.LastError
+
+	if e.Attempts >= d.config.MaxAttempts || !retryableStatus(status) {
+		delete(d.pending, id)
+		d.dead[id] = e
+		// The pending copy is removed only once the dead letter is stored,
+		// so that a crash in between never loses the event.
+		perr := d.persist(webhookDeadDir, e)
+		if perr == nil {
+			d.unpersist(webhookPendingDir, id)
+		}
+		delivery.DeadLettered = true
+		w := WrapBadGateway("Webhook delivery failed, event dead-lettered", delivery)
+		if perr != nil {
+			w.WithDebuggingKV("outbox_error", perr.Error())
+		}
+		return w
+	}
+	e.NextAttempt = d.now().Add(max(d.config.Backoff.Delay(e.Attempts), retryAfter))
+	delivery.NextAttempt = e.NextAttempt
+	perr := d.persist(webhookPendingDir, e)
+	w := WrapAccepted("Webhook delivery failed, retry scheduled", delivery)
+	if perr != nil {
+		w.WithDebuggingKV("outbox_error", perr.Error())
+	}
+	return w
+}
+
+// send posts the event, returning the HTTP status, the Retry-After delay
+// requested by the receiver and an error unless the status is 2xx.
+func (d *WebhookDispatcher) send(ctx context.Context, e *WebhookEvent) (int, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.config.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(e.Payload))
+	if err != nil {
+		return 0, 0, err
+	}
+	now := d.now()
+	req.Header.Set(HeaderContentType.String(), string(MediaTypeApplicationJSON))
+	req.Header.Set(HeaderWebhookID.String(), e.ID)
+	req.Header.Set(HeaderWebhookTimestamp.String(), strconv.FormatInt(now.Unix(), 10))
+	if strutil.IsNotEmpty(e.Type) {
+		req.Header.Set(HeaderWebhookEvent.String(), e.Type)
+	}
+	if strutil.IsNotEmpty(d.config.Secret) {
+		req.Header.Set(HeaderWebhookSignature.String(), SignWebhook(d.config.Secret, now, e.Payload))
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	var retryAfter time.Duration
+	if seconds, err := strconv.Atoi(resp.Header.Get(HeaderRetryAfter.String())); err == nil && seconds > 0 {
+		retryAfter = time.Duration(seconds) * time.Second
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, retryAfter, NewErrorf("receiver answered %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	return resp.StatusCode, retryAfter, nil
+}
+
+// restore loads the events persisted in the outbox.
+func (d *WebhookDispatcher) restore() error {
+	if strutil.IsEmpty(d.config.OutboxDir) {
+		return nil
+	}
+	for sub, into := range map[string]map[string]*WebhookEvent{webhookPendingDir: d.pending, webhookDeadDir: d.dead} {
+		dir := filepath.Join(d.config.OutboxDir, sub)
+		if !sysx.DirExists(dir) {
+			continue
+		}
+		files, err := sysx.ListDirFiles(dir)
+		if err != nil {
+			return NewErrorf("restore webhook outbox: %v", err)
+		}
+		for _, file := range files {
+			if filepath.Ext(file) != webhookEventExt {
+				continue
+			}
+			data, err := sysx.ReadBytes(filepath.Join(dir, file))
+			if err != nil {
+				return NewErrorf("restore webhook outbox: %v", err)
+			}
+			var e WebhookEvent
+			if err := json.Unmarshal(data, &e); err != nil || strutil.IsEmpty(e.ID) {
+				continue // skip corrupted entries rather than blocking the outbox
+			}
+			into[e.ID] = &e
+		}
+	}
+	// An event found in both directories was dead-lettered before its
+	// pending copy could be removed.
+	for id := range d.dead {
+		if _, ok := d.pending[id]; ok {
+			delete(d.pending, id)
+			d.unpersist(webhookPendingDir, id)
+		}
+	}
+	return nil
+}
+
+// persist atomically writes the event to the outbox subdirectory.
+func (d *WebhookDispatcher) persist(sub string, e *WebhookEvent) error {
+	if strutil.IsEmpty(d.config.OutboxDir) {
+		return nil
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return sysx.AtomicWriteBytes(d.eventPath(sub, e.ID), data)
+}
+
+// unpersist removes the event from the outbox subdirectory.
+func (d *WebhookDispatcher) unpersist(sub, id string) {
+	if strutil.IsEmpty(d.config.OutboxDir) {
+		return
+	}
+	_ = os.Remove(d.eventPath(sub, id))
+}
+
+// eventPath returns the outbox file of an event.
+func (d *WebhookDispatcher) eventPath(sub, id string) string {
+	return filepath.Join(d.config.OutboxDir, sub, id+webhookEventExt)
+}
+
+// webhookMAC returns the hex HMAC-SHA256 of "<t>.<payload>".
+func webhookMAC(secret, t string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t))
+	mac.Write([]byte{'.'})
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryableStatus reports whether a failed delivery may succeed later:
+// transport errors (status 0), 408, 429 and 5xx.
+func retryableStatus(status int) bool {
+	return status == 0 || status == http.StatusRequestTimeout ||
+		status == http.StatusTooManyRequests || status >= 500
+}
+
+// sortedEvents copies the events ordered by creation time.
+func sortedEvents(events map[string]*WebhookEvent) []WebhookEvent {
+	out := make([]WebhookEvent, 0, len(events))
+	for _, e := range events {
+		out = append(out, *e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
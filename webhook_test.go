@@ -0,0 +1,156 @@
+package replify_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sivaosorg/replify"
+)
+
+func TestWebhookDispatcherRetriesAndRestores(t *testing.T) {
+	t.Parallel()
+
+	const secret = "s3cret"
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := replify.VerifyWebhookSignature(secret, r.Header.Get("Webhook-Signature"), body, time.Minute); err != nil {
+			t.Errorf("signature: %v", err)
+		}
+		if r.Header.Get("Webhook-Event") != "order.created" {
+			t.Errorf("event header = %q", r.Header.Get("Webhook-Event"))
+		}
+		if calls.Add(1) == 1 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	config := replify.WebhookConfig{
+		Secret:    secret,
+		OutboxDir: t.TempDir(),
+		Backoff:   replify.Backoff{Initial: time.Millisecond, Max: time.Millisecond, Multiplier: 1},
+	}
+	hooks, err := replify.NewWebhookDispatcher(config)
+	if err != nil {
+		t.Fatalf("NewWebhookDispatcher: %v", err)
+	}
+
+	report := hooks.Deliver(context.Background(), srv.URL, "order.created", replify.WrapCreated("created", map[string]any{"id": 7}))
+	if report.StatusCode() != http.StatusAccepted {
+		t.Fatalf("first report = %d, want 202: %s", report.StatusCode(), report.JSON())
+	}
+
+	// A new dispatcher on the same outbox picks the pending event up.
+	restored, err := replify.NewWebhookDispatcher(config)
+	if err != nil {
+		t.Fatalf("NewWebhookDispatcher: %v", err)
+	}
+	if n := len(restored.Pending()); n != 1 {
+		t.Fatalf("restored %d pending events, want 1", n)
+	}
+	time.Sleep(5 * time.Millisecond)
+	reports := restored.Flush(context.Background())
+	if len(reports) != 1 || reports[0].StatusCode() != http.StatusOK {
+		t.Fatalf("unexpected flush reports: %v", reports)
+	}
+	if n := len(restored.Pending()); n != 0 {
+		t.Errorf("%d events still pending after delivery", n)
+	}
+}
+
+func TestWebhookDispatcherDeadLetters(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusGone)
+	}))
+	defer srv.Close()
+
+	config := replify.WebhookConfig{OutboxDir: t.TempDir()}
+	hooks, err := replify.NewWebhookDispatcher(config)
+	if err != nil {
+		t.Fatalf("NewWebhookDispatcher: %v", err)
+	}
+	report := hooks.Deliver(context.Background(), srv.URL, "user.deleted", replify.WrapOk("deleted", nil))
+	if report.StatusCode() != http.StatusBadGateway {
+		t.Fatalf("report = %d, want 502: %s", report.StatusCode(), report.JSON())
+	}
+	dead := hooks.DeadLetters()
+	if len(dead) != 1 || dead[0].LastStatus != http.StatusGone {
+		t.Fatalf("unexpected dead letters: %+v", dead)
+	}
+	restored, err := replify.NewWebhookDispatcher(config)
+	if err != nil {
+		t.Fatalf("NewWebhookDispatcher: %v", err)
+	}
+	if len(restored.Pending()) != 0 || len(restored.DeadLetters()) != 1 {
+		t.Errorf("restored %d pending and %d dead events, want 0 and 1", len(restored.Pending()), len(restored.DeadLetters()))
+	}
+	if err := hooks.Requeue(dead[0].ID); err != nil {
+		t.Fatalf("Requeue: %v", err)
+	}
+	if len(hooks.Pending()) != 1 || len(hooks.DeadLetters()) != 0 {
+		t.Error("requeued event must move back to the outbox")
+	}
+}
+
+func TestWebhookDispatcherDeliversOnce(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			close(entered)
+			<-release
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	hooks, err := replify.NewWebhookDispatcher(replify.WebhookConfig{})
+	if err != nil {
+		t.Fatalf("NewWebhookDispatcher: %v", err)
+	}
+	delivered := make(chan replify.R, 1)
+	go func() {
+		delivered <- hooks.Deliver(context.Background(), srv.URL, "order.paid", replify.WrapOk("paid", nil)).Reply()
+	}()
+	<-entered
+	if reports := hooks.Flush(context.Background()); len(reports) != 0 {
+		t.Errorf("Flush attempted an event in flight: %v", reports)
+	}
+	close(release)
+	if report := <-delivered; report.StatusCode() != http.StatusOK {
+		t.Fatalf("Deliver = %d: %s", report.StatusCode(), report.JSON())
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("event delivered %d times, want 1", n)
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	t.Parallel()
+
+	b := replify.Backoff{Initial: 100 * time.Millisecond, Max: time.Second, Multiplier: 2}
+	for attempt, want := range map[int]time.Duration{1: 100 * time.Millisecond, 3: 400 * time.Millisecond, 10: time.Second} {
+		if got := b.Delay(attempt); got != want {
+			t.Errorf("Delay(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+	b.Jitter = 0.5
+	for range 100 {
+		if d := b.Delay(2); d < 100*time.Millisecond || d > 300*time.Millisecond {
+			t.Fatalf("jittered delay %s out of range", d)
+		}
+	}
+}